@@ -5,12 +5,33 @@ package ast
 import (
     "skibidi/token"
     "bytes"
+    "fmt"
+    "math/big"
     "strings"
+    "unicode"
 )
 
 type Node interface {
     TokenLiteral() string
     String() string // main purpose of this is to be able to print nodes for debugging and comparing node values
+    Pos() token.Position // position of the first character that belongs to the node
+    End() token.Position // position just after the last character that belongs to the node
+}
+
+// a node can be missing if the parser ran into an error while building it
+// in that case fall back to the given position so that Pos/End never blow up on a partial tree
+func posOf(n Node, fallback token.Position) token.Position {
+    if n == nil {
+        return fallback
+    }
+    return n.Pos()
+}
+
+func endOf(n Node, fallback token.Position) token.Position {
+    if n == nil {
+        return fallback
+    }
+    return n.End()
 }
 
 func (p *Program) String() string {
@@ -50,6 +71,13 @@ type InfixExpression struct {
 type IntegerLiteral struct {
     Token token.Token
     Value int64
+    Big   *big.Int // only set when the literal doesn't fit in an int64, Value is 0 then
+}
+
+// the literal is kept as it was written, so 1e3 prints as 1e3 rather than 1000
+type FloatLiteral struct {
+    Token token.Token
+    Value float64
 }
 
 // program node is going to be the root node of every AST our parser makes
@@ -65,6 +93,20 @@ func (p *Program) TokenLiteral() string {
     }
 }
 
+func (p *Program) Pos() token.Position {
+    if len(p.Statements) > 0 {
+        return p.Statements[0].Pos()
+    }
+    return token.Position{}
+}
+
+func (p *Program) End() token.Position {
+    if len(p.Statements) > 0 {
+        return p.Statements[len(p.Statements)-1].End()
+    }
+    return token.Position{}
+}
+
 func (il *IntegerLiteral) expressionNode()  {}
 
 func (il *IntegerLiteral) TokenLiteral() string {
@@ -75,6 +117,22 @@ func (il *IntegerLiteral) String() string {
     return il.Token.Literal
 }
 
+func (il *IntegerLiteral) Pos() token.Position { return il.Token.Pos }
+func (il *IntegerLiteral) End() token.Position { return il.Token.End }
+
+func (fl *FloatLiteral) expressionNode()  {}
+
+func (fl *FloatLiteral) TokenLiteral() string {
+    return fl.Token.Literal
+}
+
+func (fl *FloatLiteral) String() string {
+    return fl.Token.Literal
+}
+
+func (fl *FloatLiteral) Pos() token.Position { return fl.Token.Pos }
+func (fl *FloatLiteral) End() token.Position { return fl.Token.End }
+
 type ExpressionStatement struct {
     Token       token.Token // the first token of the expression
     Expression  Expression
@@ -127,18 +185,27 @@ func (rs *ReturnStatement) TokenLiteral() string {
     return rs.Token.Literal
 }
 
+func (rs *ReturnStatement) Pos() token.Position { return rs.Token.Pos }
+func (rs *ReturnStatement) End() token.Position { return endOf(rs.ReturnValue, rs.Token.End) }
+
 func (ls *LetStatement) statementNode() {}
 
 func (ls *LetStatement) TokenLiteral() string {
     return ls.Token.Literal
 }
 
+func (ls *LetStatement) Pos() token.Position { return ls.Token.Pos }
+func (ls *LetStatement) End() token.Position { return endOf(ls.Value, ls.Token.End) }
+
 func (es *ExpressionStatement) statementNode() {}
 
 func (es *ExpressionStatement) TokenLiteral() string {
     return es.Token.Literal
 }
 
+func (es *ExpressionStatement) Pos() token.Position { return posOf(es.Expression, es.Token.Pos) }
+func (es *ExpressionStatement) End() token.Position { return endOf(es.Expression, es.Token.End) }
+
 func (es *ExpressionStatement) String() string {
     if es.Expression != nil {
         return es.Expression.String()
@@ -157,6 +224,8 @@ type Identifier struct {
 func (i *Identifier) expressionNode()           {}
 func (i *Identifier) TokenLiteral() string      { return i.Token.Literal }
 func (i *Identifier) String() string { return i.Value }
+func (i *Identifier) Pos() token.Position { return i.Token.Pos }
+func (i *Identifier) End() token.Position { return i.Token.End }
 
 func (pe *PrefixExpression) expressionNode() {
 
@@ -166,6 +235,9 @@ func (pe *PrefixExpression) TokenLiteral() string {
     return pe.Token.Literal
 }
 
+func (pe *PrefixExpression) Pos() token.Position { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Position { return endOf(pe.Right, pe.Token.End) }
+
 func (pe *PrefixExpression) String() string {
     var out bytes.Buffer
 
@@ -187,6 +259,9 @@ func (ie *InfixExpression) TokenLiteral() string {
     return ie.Token.Literal
 }
 
+func (ie *InfixExpression) Pos() token.Position { return posOf(ie.Left, ie.Token.Pos) }
+func (ie *InfixExpression) End() token.Position { return endOf(ie.Right, ie.Token.End) }
+
 func (ie *InfixExpression) String() string {
     var out bytes.Buffer
 
@@ -201,6 +276,36 @@ func (ie *InfixExpression) String() string {
 
 }
 
+// x = 5, x += 1, arr[0] = 2
+// the target can only be an identifier or an index expression, the parser rejects anything else
+type AssignExpression struct {
+    Token       token.Token // the =, += etc token
+    Target      Expression
+    Operator    string
+    Value       Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+
+func (ae *AssignExpression) TokenLiteral() string {
+    return ae.Token.Literal
+}
+
+func (ae *AssignExpression) Pos() token.Position { return posOf(ae.Target, ae.Token.Pos) }
+func (ae *AssignExpression) End() token.Position { return endOf(ae.Value, ae.Token.End) }
+
+func (ae *AssignExpression) String() string {
+    var out bytes.Buffer
+
+    out.WriteString("(")
+    out.WriteString(ae.Target.String())
+    out.WriteString(" " + ae.Operator + " ")
+    out.WriteString(ae.Value.String())
+    out.WriteString(")")
+
+    return out.String()
+}
+
 // very easy implementation for a boolean value node for the ast
 type Boolean struct {
     Token   token.Token
@@ -219,6 +324,9 @@ func (b *Boolean) String() string {
     return b.Token.Literal
 }
 
+func (b *Boolean) Pos() token.Position { return b.Token.Pos }
+func (b *Boolean) End() token.Position { return b.Token.End }
+
 type IfExpression struct {
     Token       token.Token // the 'if' token
     Condition   Expression // holds the value of the if statement so to speak
@@ -234,6 +342,18 @@ func (fe *IfExpression) TokenLiteral() string {
     return fe.Token.Literal
 }
 
+func (fe *IfExpression) Pos() token.Position { return fe.Token.Pos }
+
+func (fe *IfExpression) End() token.Position {
+    if fe.Alternative != nil {
+        return fe.Alternative.End()
+    }
+    if fe.Consequence != nil {
+        return fe.Consequence.End()
+    }
+    return endOf(fe.Condition, fe.Token.End)
+}
+
 func (fe *IfExpression) String() string {
 
     var out bytes.Buffer
@@ -251,9 +371,113 @@ func (fe *IfExpression) String() string {
     return out.String()
 }
 
+// loops are statements rather than expressions, they don't give back a value
+type WhileStatement struct {
+    Token       token.Token // the 'while' token
+    Condition   Expression
+    Body        *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode() {}
+
+func (ws *WhileStatement) TokenLiteral() string {
+    return ws.Token.Literal
+}
+
+func (ws *WhileStatement) Pos() token.Position { return ws.Token.Pos }
+
+func (ws *WhileStatement) End() token.Position {
+    if ws.Body != nil {
+        return ws.Body.End()
+    }
+    return endOf(ws.Condition, ws.Token.End)
+}
+
+func (ws *WhileStatement) String() string {
+    var out bytes.Buffer
+
+    out.WriteString("while")
+    out.WriteString(ws.Condition.String())
+    out.WriteString(" ")
+    out.WriteString(ws.Body.String())
+
+    return out.String()
+}
+
+// for (x in iterable) { ... }, the body runs once for every element with x bound to it
+type ForStatement struct {
+    Token       token.Token // the 'for' token
+    Variable    *Identifier
+    Iterable    Expression
+    Body        *BlockStatement
+}
+
+func (fs *ForStatement) statementNode() {}
+
+func (fs *ForStatement) TokenLiteral() string {
+    return fs.Token.Literal
+}
+
+func (fs *ForStatement) Pos() token.Position { return fs.Token.Pos }
+
+func (fs *ForStatement) End() token.Position {
+    if fs.Body != nil {
+        return fs.Body.End()
+    }
+    return endOf(fs.Iterable, fs.Token.End)
+}
+
+func (fs *ForStatement) String() string {
+    var out bytes.Buffer
+
+    out.WriteString("for (")
+    out.WriteString(fs.Variable.String())
+    out.WriteString(" in ")
+    out.WriteString(fs.Iterable.String())
+    out.WriteString(") ")
+    out.WriteString(fs.Body.String())
+
+    return out.String()
+}
+
+type BreakStatement struct {
+    Token token.Token // the 'break' token
+}
+
+func (bs *BreakStatement) statementNode() {}
+
+func (bs *BreakStatement) TokenLiteral() string {
+    return bs.Token.Literal
+}
+
+func (bs *BreakStatement) String() string {
+    return bs.Token.Literal + ";"
+}
+
+func (bs *BreakStatement) Pos() token.Position { return bs.Token.Pos }
+func (bs *BreakStatement) End() token.Position { return bs.Token.End }
+
+type ContinueStatement struct {
+    Token token.Token // the 'continue' token
+}
+
+func (cs *ContinueStatement) statementNode() {}
+
+func (cs *ContinueStatement) TokenLiteral() string {
+    return cs.Token.Literal
+}
+
+func (cs *ContinueStatement) String() string {
+    return cs.Token.Literal + ";"
+}
+
+func (cs *ContinueStatement) Pos() token.Position { return cs.Token.Pos }
+func (cs *ContinueStatement) End() token.Position { return cs.Token.End }
+
 type BlockStatement struct {
     Token       token.Token // the { token
     Statements  []Statement
+    Rbrace      token.Token // the closing } token
 }
 
 func (bs *BlockStatement) statementNode() {
@@ -264,6 +488,9 @@ func (bs *BlockStatement) TokenLiteral() string {
     return bs.Token.Literal
 }
 
+func (bs *BlockStatement) Pos() token.Position { return bs.Token.Pos }
+func (bs *BlockStatement) End() token.Position { return bs.Rbrace.End }
+
 func (bs *BlockStatement) String() string {
     var out bytes.Buffer
 
@@ -277,7 +504,10 @@ func (bs *BlockStatement) String() string {
 type FunctionLiteral struct {
     Token       token.Token // the 'fn' token
     Parameters  []*Identifier
+    Defaults    []Expression // lines up with Parameters, nil for a parameter without a default value
+    Rest        *Identifier // the ...rest parameter that collects any extra arguments, nil if there isn't one
     Body        *BlockStatement
+    Name        string // the name it was bound to with let, empty for an anonymous function
 }
 
 func (fl *FunctionLiteral) expressionNode() {
@@ -288,14 +518,19 @@ func (fl *FunctionLiteral) TokenLiteral() string {
     return fl.Token.Literal
 }
 
+func (fl *FunctionLiteral) Pos() token.Position { return fl.Token.Pos }
+
+func (fl *FunctionLiteral) End() token.Position {
+    if fl.Body != nil {
+        return fl.Body.End()
+    }
+    return fl.Token.End
+}
+
 func (fl *FunctionLiteral) String() string {
     var out bytes.Buffer
 
-    params := []string{}
-
-    for _, p := range fl.Parameters {
-        params = append(params, p.String())
-    }
+    params := ParameterStrings(fl.Parameters, fl.Defaults, fl.Rest)
 
     // just putting the function name, and it's paramters in a string readable form to output (mainly for debugging purposes)
     out.WriteString(fl.TokenLiteral())
@@ -307,10 +542,31 @@ func (fl *FunctionLiteral) String() string {
     return out.String()
 }
 
+// the parameter list written out the way it was in the source, eg a, b = 2, ...rest
+// also used by the object package so a function value prints its parameters the same way
+func ParameterStrings(parameters []*Identifier, defaults []Expression, rest *Identifier) []string {
+    params := []string{}
+
+    for i, p := range parameters {
+        if i < len(defaults) && defaults[i] != nil {
+            params = append(params, p.String()+" = "+defaults[i].String())
+        } else {
+            params = append(params, p.String())
+        }
+    }
+
+    if rest != nil {
+        params = append(params, "..."+rest.String())
+    }
+
+    return params
+}
+
 type CallExpression struct {
     Token       token.Token // the '(' token
     Function    Expression // identifier or function literal
     Arguments   []Expression
+    Rparen      token.Token // the closing ')' token
 }
 
 func (ce *CallExpression) expressionNode(){
@@ -321,6 +577,9 @@ func (ce *CallExpression) TokenLiteral() string {
     return ce.Token.Literal
 }
 
+func (ce *CallExpression) Pos() token.Position { return posOf(ce.Function, ce.Token.Pos) }
+func (ce *CallExpression) End() token.Position { return ce.Rparen.End }
+
 func (ce *CallExpression) String() string {
     var out bytes.Buffer
 
@@ -338,3 +597,153 @@ func (ce *CallExpression) String() string {
 
 }
 
+// the token literal of a string already has its escape sequences resolved by the lexer
+// so String() puts the quotes and escapes back, that way the output can be read back in by the lexer
+type StringLiteral struct {
+    Token   token.Token
+    Value   string
+}
+
+func (sl *StringLiteral) expressionNode() {
+
+}
+
+func (sl *StringLiteral) TokenLiteral() string {
+    return sl.Token.Literal
+}
+
+func (sl *StringLiteral) Pos() token.Position { return sl.Token.Pos }
+func (sl *StringLiteral) End() token.Position { return sl.Token.End }
+
+func (sl *StringLiteral) String() string {
+    return QuoteString(sl.Value)
+}
+
+// turns a string value back into skibidi source form, using only the escapes the lexer understands
+func QuoteString(s string) string {
+    var out strings.Builder
+
+    out.WriteByte('"')
+    for _, r := range s {
+        switch {
+        case r == '"':
+            out.WriteString("\\\"")
+        case r == '\\':
+            out.WriteString("\\\\")
+        case r == '\n':
+            out.WriteString("\\n")
+        case r == '\t':
+            out.WriteString("\\t")
+        case r == unicode.ReplacementChar || !unicode.IsPrint(r):
+            out.WriteString(fmt.Sprintf("\\u{%X}", r))
+        default:
+            out.WriteRune(r)
+        }
+    }
+    out.WriteByte('"')
+
+    return out.String()
+}
+
+type ArrayLiteral struct {
+    Token       token.Token // the '[' token
+    Elements    []Expression
+    Rbracket    token.Token // the closing ']' token
+}
+
+func (al *ArrayLiteral) expressionNode() {
+
+}
+
+func (al *ArrayLiteral) TokenLiteral() string {
+    return al.Token.Literal
+}
+
+func (al *ArrayLiteral) Pos() token.Position { return al.Token.Pos }
+func (al *ArrayLiteral) End() token.Position { return al.Rbracket.End }
+
+func (al *ArrayLiteral) String() string {
+    var out bytes.Buffer
+
+    elements := []string{}
+    for _, el := range al.Elements {
+        elements = append(elements, el.String())
+    }
+
+    out.WriteString("[")
+    out.WriteString(strings.Join(elements, ", "))
+    out.WriteString("]")
+
+    return out.String()
+}
+
+// the 'myArray[1]' kind of expression, Left is whatever is being indexed into
+type IndexExpression struct {
+    Token       token.Token // the '[' token
+    Left        Expression
+    Index       Expression
+    Rbracket    token.Token // the closing ']' token
+}
+
+func (ie *IndexExpression) expressionNode() {
+
+}
+
+func (ie *IndexExpression) TokenLiteral() string {
+    return ie.Token.Literal
+}
+
+func (ie *IndexExpression) Pos() token.Position { return posOf(ie.Left, ie.Token.Pos) }
+func (ie *IndexExpression) End() token.Position { return ie.Rbracket.End }
+
+func (ie *IndexExpression) String() string {
+    var out bytes.Buffer
+
+    // same idea as the infix expression, the parentheses show what belongs to the index expression
+    out.WriteString("(")
+    out.WriteString(ie.Left.String())
+    out.WriteString("[")
+    out.WriteString(ie.Index.String())
+    out.WriteString("])")
+
+    return out.String()
+}
+
+// the pairs are kept in a slice as well as the order they were written in
+// this way String() always prints the same thing, a plain Go map would shuffle them around
+type HashLiteral struct {
+    Token   token.Token // the '{' token
+    Pairs   []HashPair
+    Rbrace  token.Token // the closing '}' token
+}
+
+type HashPair struct {
+    Key     Expression
+    Value   Expression
+}
+
+func (hl *HashLiteral) expressionNode() {
+
+}
+
+func (hl *HashLiteral) TokenLiteral() string {
+    return hl.Token.Literal
+}
+
+func (hl *HashLiteral) Pos() token.Position { return hl.Token.Pos }
+func (hl *HashLiteral) End() token.Position { return hl.Rbrace.End }
+
+func (hl *HashLiteral) String() string {
+    var out bytes.Buffer
+
+    pairs := []string{}
+    for _, pair := range hl.Pairs {
+        pairs = append(pairs, pair.Key.String() + ": " + pair.Value.String())
+    }
+
+    out.WriteString("{")
+    out.WriteString(strings.Join(pairs, ", "))
+    out.WriteString("}")
+
+    return out.String()
+}
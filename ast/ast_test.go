@@ -10,9 +10,9 @@ func TestString(t *testing.T) {
     program := &Program{
         Statements: []Statement{
             &LetStatement{
-                Token: token.Token(Type: token.LET, Literal: "let"),
+                Token: token.Token{Type: token.LET, Literal: "let"},
                 Name: &Identifier{
-                    Token: token.Token(Type: token.IDENT, Literal: "myVar"),
+                    Token: token.Token{Type: token.IDENT, Literal: "myVar"},
                     Value: "myVar",
                 },
             Value: &Identifier{
@@ -27,3 +27,31 @@ func TestString(t *testing.T) {
         t.Errorf("program.String() wrong, got: %q", program.String())
     }
 }
+
+func TestDump(t *testing.T) {
+    program := &Program{
+        Statements: []Statement{
+            &LetStatement{
+                Name:   &Identifier{Value: "x"},
+                Value:  &InfixExpression{
+                    Left:       &IntegerLiteral{Value: 1},
+                    Operator:   "+",
+                    Right:      &Boolean{Value: true},
+                },
+            },
+            &ReturnStatement{},
+        },
+    }
+
+    expected := `Program
+  Statements[0]: LetStatement
+    Name: Identifier (Value: "x")
+    Value: InfixExpression (Operator: "+")
+      Left: IntegerLiteral (Value: 1)
+      Right: Boolean (Value: true)
+  Statements[1]: ReturnStatement
+`
+    if Dump(program) != expected {
+        t.Errorf("Dump wrong, expected:\n%s\ngot:\n%s", expected, Dump(program))
+    }
+}
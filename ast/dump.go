@@ -0,0 +1,88 @@
+package ast
+
+import (
+    "fmt"
+    "math/big"
+    "reflect"
+    "skibidi/token"
+    "strings"
+)
+
+var (
+    tokenType   = reflect.TypeOf(token.Token{})
+    bigIntType  = reflect.TypeOf((*big.Int)(nil))
+)
+
+// a tree view of node for debugging (it's what the REPL's :ast command prints), one line per node with its children indented under it
+// the plain fields of a node (operators, names, literal values) go on the node's own line, tokens are left out
+//
+//  Program
+//    Statements[0]: ExpressionStatement
+//      Expression: InfixExpression (Operator: "+")
+//        Left: IntegerLiteral (Value: 1)
+//        Right: IntegerLiteral (Value: 2)
+func Dump(node Node) string {
+    var out strings.Builder
+    dump(&out, "", reflect.ValueOf(node), 0)
+    return out.String()
+}
+
+type dumpChild struct {
+    label   string
+    value   reflect.Value
+}
+
+// walks the struct fields with reflection, so a new kind of node shows up properly without having to be added here
+func dump(out *strings.Builder, label string, v reflect.Value, depth int) {
+    for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+        // a nil child (like the default of a parameter that doesn't have one) is just left out
+        if v.IsNil() {
+            return
+        }
+        v = v.Elem()
+    }
+
+    out.WriteString(strings.Repeat("  ", depth))
+    if label != "" {
+        out.WriteString(label + ": ")
+    }
+    out.WriteString(v.Type().Name())
+
+    fields := []string{}
+    children := []dumpChild{}
+    for i := 0; i < v.NumField(); i++ {
+        field := v.Type().Field(i)
+        value := v.Field(i)
+        if !field.IsExported() || field.Type == tokenType {
+            continue
+        }
+
+        switch {
+        case field.Type == bigIntType:
+            if !value.IsNil() {
+                fields = append(fields, fmt.Sprintf("%s: %s", field.Name, value.Interface()))
+            }
+        case value.Kind() == reflect.String:
+            if value.String() != "" {
+                fields = append(fields, fmt.Sprintf("%s: %q", field.Name, value.String()))
+            }
+        case value.Kind() == reflect.Bool, value.Kind() == reflect.Int64, value.Kind() == reflect.Float64:
+            fields = append(fields, fmt.Sprintf("%s: %v", field.Name, value.Interface()))
+        case value.Kind() == reflect.Slice:
+            for j := 0; j < value.Len(); j++ {
+                children = append(children, dumpChild{fmt.Sprintf("%s[%d]", field.Name, j), value.Index(j)})
+            }
+        default:
+            children = append(children, dumpChild{field.Name, value})
+        }
+    }
+
+    if len(fields) > 0 {
+        out.WriteString(" (" + strings.Join(fields, ", ") + ")")
+    }
+    out.WriteString("\n")
+
+    for _, child := range children {
+        dump(out, child.label, child.value, depth+1)
+    }
+}
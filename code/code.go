@@ -0,0 +1,230 @@
+// the code package defines the instruction set of the skibidi virtual machine
+// an instruction is a one byte opcode followed by its operands, all operands are big endian
+package code
+
+import (
+    "bytes"
+    "encoding/binary"
+    "fmt"
+)
+
+type Instructions []byte
+
+type Opcode byte
+
+const (
+    OpConstant Opcode = iota // push constants[operand]
+    OpPop // throw away the top of the stack
+
+    OpTrue
+    OpFalse
+    OpNull // push the NULL object (what an if without an else gives back)
+    OpNothing // push a Go nil, what the tree walker gives back for a program that ends in a let statement
+
+    // operators, all of them pop their operands and push the result
+    OpAdd
+    OpSub
+    OpMul
+    OpDiv
+    OpEqual
+    OpNotEqual
+    OpGreaterThan
+    OpLessThan
+    OpGreaterEqual
+    OpLessEqual
+    OpMinus
+    OpBang
+
+    OpJump // jump to the absolute offset in the operand
+    OpJumpNotTruthy // pop the condition, jump if it isn't truthy
+    OpJumpIfArgGiven // first operand is a parameter, jump to the second if the call passed an argument for it (so its default is skipped)
+
+    // break and continue can happen in the middle of an expression, so a loop remembers how high the stack was when it started
+    OpLoopEnter // remember the current stack height for the loop that is starting
+    OpLoopExit // forget it again once the loop is done
+    OpUnwind // throw away everything pushed since the innermost loop started
+    OpIter // pop a value and push an iterator over it
+    OpIterNext // push the next value of the iterator on top of the stack, or jump to the operand once there are none left
+
+    OpGetGlobal
+    OpSetGlobal // pops the value
+    OpGetLocal
+    OpSetLocal // pops the value
+    OpGetOuter // first operand is how many functions out the variable lives, second is its slot there
+
+    // assignment changes a binding that already exists, the value is left on the stack since an assignment is an expression
+    OpAssignGlobal
+    OpAssignLocal
+    OpAssignOuter // same operands as OpGetOuter
+
+    OpArray // operand is the number of elements on the stack
+    OpHash // operand is the number of key/value pairs on the stack
+    OpIndex
+    OpSetIndex // pops the collection, index and value, pushes the value back
+    OpDup2 // pushes a copy of the top two values, so arr[i] += 1 only works out arr and i once
+
+    OpCall // operand is the number of arguments, the function sits below them on the stack
+    OpReturnValue
+    OpClosure // turns constants[operand] (a compiled function) into a closure over the current locals
+)
+
+type Definition struct {
+    Name            string
+    OperandWidths   []int // number of bytes each operand takes up
+}
+
+var definitions = map[Opcode]*Definition{
+    OpConstant:         {"OpConstant", []int{2}},
+    OpPop:              {"OpPop", []int{}},
+    OpTrue:             {"OpTrue", []int{}},
+    OpFalse:            {"OpFalse", []int{}},
+    OpNull:             {"OpNull", []int{}},
+    OpNothing:          {"OpNothing", []int{}},
+    OpAdd:              {"OpAdd", []int{}},
+    OpSub:              {"OpSub", []int{}},
+    OpMul:              {"OpMul", []int{}},
+    OpDiv:              {"OpDiv", []int{}},
+    OpEqual:            {"OpEqual", []int{}},
+    OpNotEqual:         {"OpNotEqual", []int{}},
+    OpGreaterThan:      {"OpGreaterThan", []int{}},
+    OpLessThan:         {"OpLessThan", []int{}},
+    OpGreaterEqual:     {"OpGreaterEqual", []int{}},
+    OpLessEqual:        {"OpLessEqual", []int{}},
+    OpMinus:            {"OpMinus", []int{}},
+    OpBang:             {"OpBang", []int{}},
+    OpJump:             {"OpJump", []int{2}},
+    OpJumpNotTruthy:    {"OpJumpNotTruthy", []int{2}},
+    OpJumpIfArgGiven:   {"OpJumpIfArgGiven", []int{1, 2}},
+    OpLoopEnter:        {"OpLoopEnter", []int{}},
+    OpLoopExit:         {"OpLoopExit", []int{}},
+    OpUnwind:           {"OpUnwind", []int{}},
+    OpIter:             {"OpIter", []int{}},
+    OpIterNext:         {"OpIterNext", []int{2}},
+    OpGetGlobal:        {"OpGetGlobal", []int{2}},
+    OpSetGlobal:        {"OpSetGlobal", []int{2}},
+    OpGetLocal:         {"OpGetLocal", []int{2}},
+    OpSetLocal:         {"OpSetLocal", []int{2}},
+    OpGetOuter:         {"OpGetOuter", []int{1, 2}},
+    OpAssignGlobal:     {"OpAssignGlobal", []int{2}},
+    OpAssignLocal:      {"OpAssignLocal", []int{2}},
+    OpAssignOuter:      {"OpAssignOuter", []int{1, 2}},
+    OpArray:            {"OpArray", []int{2}},
+    OpHash:             {"OpHash", []int{2}},
+    OpIndex:            {"OpIndex", []int{}},
+    OpSetIndex:         {"OpSetIndex", []int{}},
+    OpDup2:             {"OpDup2", []int{}},
+    OpCall:             {"OpCall", []int{1}},
+    OpReturnValue:      {"OpReturnValue", []int{}},
+    OpClosure:          {"OpClosure", []int{2}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+    def, ok := definitions[Opcode(op)]
+    if !ok {
+        return nil, fmt.Errorf("opcode %d undefined", op)
+    }
+    return def, nil
+}
+
+// the largest value an operand width bytes wide can hold, Make cuts off anything bigger
+func MaxOperand(width int) int {
+    return 1<<(8*width) - 1
+}
+
+// builds a single instruction out of an opcode and its operands
+func Make(op Opcode, operands ...int) []byte {
+    def, ok := definitions[op]
+    if !ok {
+        return []byte{}
+    }
+
+    instructionLen := 1
+    for _, w := range def.OperandWidths {
+        instructionLen += w
+    }
+
+    instruction := make([]byte, instructionLen)
+    instruction[0] = byte(op)
+
+    offset := 1
+    for i, o := range operands {
+        width := def.OperandWidths[i]
+        switch width {
+        case 2:
+            binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+        case 1:
+            instruction[offset] = byte(o)
+        }
+        offset += width
+    }
+
+    return instruction
+}
+
+// the opposite of Make, gives back the operands and how many bytes they took up
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+    operands := make([]int, len(def.OperandWidths))
+    offset := 0
+
+    for i, width := range def.OperandWidths {
+        switch width {
+        case 2:
+            operands[i] = int(ReadUint16(ins[offset:]))
+        case 1:
+            operands[i] = int(ReadUint8(ins[offset:]))
+        }
+        offset += width
+    }
+
+    return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+    return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+    return uint8(ins[0])
+}
+
+// disassembles the instructions, one per line with its offset in front (mainly for debugging and tests)
+func (ins Instructions) String() string {
+    var out bytes.Buffer
+
+    i := 0
+    for i < len(ins) {
+        def, err := Lookup(ins[i])
+        if err != nil {
+            fmt.Fprintf(&out, "ERROR: %s\n", err)
+            i++
+            continue
+        }
+
+        operands, read := ReadOperands(def, ins[i+1:])
+
+        fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+
+        i += 1 + read
+    }
+
+    return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+    operandCount := len(def.OperandWidths)
+
+    if len(operands) != operandCount {
+        return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
+    }
+
+    switch operandCount {
+    case 0:
+        return def.Name
+    case 1:
+        return fmt.Sprintf("%s %d", def.Name, operands[0])
+    case 2:
+        return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+    }
+
+    return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
@@ -0,0 +1,644 @@
+// the compiler turns an ast.Program into bytecode for the vm package
+// the goal is to behave exactly like the tree walking evaluator, just faster
+package compiler
+
+import (
+    "fmt"
+    "skibidi/ast"
+    "skibidi/code"
+    "skibidi/object"
+    "skibidi/token"
+)
+
+// everything the vm needs to run a program
+type Bytecode struct {
+    Main        *object.CompiledFunction // the top level of the program, it has no locals of its own
+    Constants   []object.Object
+    GlobalNames []string // the name of each global slot
+}
+
+// the instructions being generated for one function (or the top level)
+type compilationScope struct {
+    instructions    code.Instructions
+    positions       []object.SourcePosition
+    callPositions   []object.SourcePosition
+    locals          *localTable // nil for the top level, where every let is a global
+    loops           []*loopContext // the innermost loop being compiled is last
+}
+
+// where break and continue inside a loop have to jump to
+type loopContext struct {
+    start   int // continue jumps back here
+    breaks  []int // jumps that need patching to the end of the loop once it's known
+}
+
+type Compiler struct {
+    constants   []object.Object
+    globals     *globalTable
+    scopes      []*compilationScope // the innermost function being compiled is last
+    err         error // the first operand that was too big for its instruction, see checkOperands
+}
+
+// returned by Compile when an operand doesn't fit in its instruction, see checkOperands
+type TooBigError struct {
+    Pos     token.Position // the zero value if it isn't known
+    Message string
+}
+
+func (e *TooBigError) Error() string {
+    if e.Pos.IsValid() {
+        return e.Pos.String() + ": " + e.Message
+    }
+    return e.Message
+}
+
+func New() *Compiler {
+    return &Compiler{
+        constants:  []object.Object{},
+        globals:    newGlobalTable(),
+        scopes:     []*compilationScope{{}},
+    }
+}
+
+func (c *Compiler) Compile(program *ast.Program) error {
+    if err := c.compileStatementsValue(program.Statements, code.OpNothing); err != nil {
+        return err
+    }
+    return c.err
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+    main := c.scopes[0]
+    bytecode := &Bytecode{
+        Main:           &object.CompiledFunction{Instructions: main.instructions, Positions: main.positions, CallPositions: main.callPositions},
+        Constants:      c.constants,
+        GlobalNames:    c.globals.names,
+    }
+
+    // a function can be called long after the program it came from is done (it's kept in a variable the next program uses),
+    // so each one carries the constant pool and global names with it
+    bytecode.Main.Constants, bytecode.Main.GlobalNames = c.constants, c.globals.names
+    for _, constant := range c.constants {
+        if fn, ok := constant.(*object.CompiledFunction); ok {
+            fn.Constants, fn.GlobalNames = c.constants, c.globals.names
+        }
+    }
+
+    return bytecode
+}
+
+// compiles a list of statements so that the value of the last one is left on the stack
+// empty is pushed when there isn't one, the tree walker gives back nothing (a Go nil) for a program that ends in a let and null for a block, so the same is done here
+func (c *Compiler) compileStatementsValue(stmts []ast.Statement, empty code.Opcode) error {
+    if len(stmts) == 0 {
+        c.emit(token.Position{}, empty)
+        return nil
+    }
+
+    for i, stmt := range stmts {
+        if err := c.compileStatement(stmt); err != nil {
+            return err
+        }
+
+        if _, ok := stmt.(*ast.ExpressionStatement); ok {
+            if i != len(stmts)-1 {
+                c.emit(token.Position{}, code.OpPop)
+            }
+        } else if i == len(stmts)-1 {
+            switch stmt.(type) {
+            case *ast.WhileStatement, *ast.ForStatement:
+                // a loop results in null wherever it is
+                c.emit(token.Position{}, code.OpNull)
+            default:
+                c.emit(token.Position{}, empty)
+            }
+        }
+    }
+
+    return nil
+}
+
+// expression statements leave their value on the stack, everything else leaves the stack alone
+func (c *Compiler) compileStatement(stmt ast.Statement) error {
+    switch stmt := stmt.(type) {
+    case *ast.ExpressionStatement:
+        return c.compileExpression(stmt.Expression)
+    case *ast.LetStatement:
+        if err := c.compileExpression(stmt.Value); err != nil {
+            return err
+        }
+        c.setVariable(stmt.Name)
+    case *ast.ReturnStatement:
+        if err := c.compileExpression(stmt.ReturnValue); err != nil {
+            return err
+        }
+        c.emit(stmt.Pos(), code.OpReturnValue)
+    case *ast.WhileStatement:
+        return c.compileWhileStatement(stmt)
+    case *ast.ForStatement:
+        return c.compileForStatement(stmt)
+    case *ast.BreakStatement:
+        loop := c.currentLoop()
+        if loop == nil {
+            return fmt.Errorf("%s: break outside of a loop", stmt.Pos())
+        }
+        c.emit(stmt.Pos(), code.OpUnwind)
+        loop.breaks = append(loop.breaks, c.emit(stmt.Pos(), code.OpJump, 9999))
+    case *ast.ContinueStatement:
+        loop := c.currentLoop()
+        if loop == nil {
+            return fmt.Errorf("%s: continue outside of a loop", stmt.Pos())
+        }
+        c.emit(stmt.Pos(), code.OpUnwind)
+        c.emit(stmt.Pos(), code.OpJump, loop.start)
+    default:
+        return fmt.Errorf("%s: can't compile statement %T", stmt.Pos(), stmt)
+    }
+    return nil
+}
+
+func (c *Compiler) compileExpression(node ast.Expression) error {
+    switch node := node.(type) {
+    case *ast.IntegerLiteral:
+        var integer object.Object = &object.Integer{Value: node.Value}
+        if node.Big != nil {
+            integer = &object.BigInt{Value: node.Big}
+        }
+        c.emit(node.Pos(), code.OpConstant, c.addConstant(integer))
+    case *ast.FloatLiteral:
+        c.emit(node.Pos(), code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
+    case *ast.StringLiteral:
+        c.emit(node.Pos(), code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+    case *ast.Boolean:
+        if node.Value {
+            c.emit(node.Pos(), code.OpTrue)
+        } else {
+            c.emit(node.Pos(), code.OpFalse)
+        }
+    case *ast.Identifier:
+        c.getVariable(node)
+    case *ast.PrefixExpression:
+        if err := c.compileExpression(node.Right); err != nil {
+            return err
+        }
+        switch node.Operator {
+        case "!":
+            c.emit(node.Pos(), code.OpBang)
+        case "-":
+            c.emit(node.Pos(), code.OpMinus)
+        default:
+            return fmt.Errorf("%s: unknown operator %s", node.Pos(), node.Operator)
+        }
+    case *ast.InfixExpression:
+        return c.compileInfixExpression(node)
+    case *ast.IfExpression:
+        return c.compileIfExpression(node)
+    case *ast.FunctionLiteral:
+        return c.compileFunctionLiteral(node)
+    case *ast.CallExpression:
+        if err := c.compileExpression(node.Function); err != nil {
+            return err
+        }
+        for _, arg := range node.Arguments {
+            if err := c.compileExpression(arg); err != nil {
+                return err
+            }
+        }
+        // an error in the call itself points at the start of the expression, a stack trace going through it points at the (
+        offset := c.emit(node.Pos(), code.OpCall, len(node.Arguments))
+        scope := c.currentScope()
+        scope.callPositions = append(scope.callPositions, object.SourcePosition{Offset: offset, Pos: node.Token.Pos})
+    case *ast.ArrayLiteral:
+        for _, el := range node.Elements {
+            if err := c.compileExpression(el); err != nil {
+                return err
+            }
+        }
+        c.emit(node.Pos(), code.OpArray, len(node.Elements))
+    case *ast.IndexExpression:
+        if err := c.compileExpression(node.Left); err != nil {
+            return err
+        }
+        if err := c.compileExpression(node.Index); err != nil {
+            return err
+        }
+        // errors point at the '[' just like in the evaluator
+        c.emit(node.Token.Pos, code.OpIndex)
+    case *ast.AssignExpression:
+        return c.compileAssignExpression(node)
+    case *ast.HashLiteral:
+        for _, pair := range node.Pairs {
+            if err := c.compileExpression(pair.Key); err != nil {
+                return err
+            }
+            if err := c.compileExpression(pair.Value); err != nil {
+                return err
+            }
+        }
+        c.emit(node.Pos(), code.OpHash, len(node.Pairs))
+    default:
+        return fmt.Errorf("can't compile expression %T", node)
+    }
+    return nil
+}
+
+var infixOpcodes = map[string]code.Opcode{
+    "+":    code.OpAdd,
+    "-":    code.OpSub,
+    "*":    code.OpMul,
+    "/":    code.OpDiv,
+    "==":   code.OpEqual,
+    "!=":   code.OpNotEqual,
+    ">":    code.OpGreaterThan,
+    "<":    code.OpLessThan,
+    ">=":   code.OpGreaterEqual,
+    "<=":   code.OpLessEqual,
+}
+
+func (c *Compiler) compileInfixExpression(node *ast.InfixExpression) error {
+    if node.Operator == "&&" || node.Operator == "||" {
+        return c.compileLogicalExpression(node)
+    }
+
+    op, ok := infixOpcodes[node.Operator]
+    if !ok {
+        return fmt.Errorf("%s: unknown operator %s", node.Token.Pos, node.Operator)
+    }
+
+    if err := c.compileExpression(node.Left); err != nil {
+        return err
+    }
+    if err := c.compileExpression(node.Right); err != nil {
+        return err
+    }
+
+    // the position of the operator itself, that's where a type mismatch gets reported
+    c.emit(node.Token.Pos, op)
+    return nil
+}
+
+// && and || are compiled into jumps so the right side is skipped when the left decides the answer
+// the result is always TRUE or FALSE, never one of the operands, same as the evaluator
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+    if err := c.compileExpression(node.Left); err != nil {
+        return err
+    }
+
+    var shortCircuitPos int
+    if node.Operator == "&&" {
+        // a falsy left side goes straight to FALSE
+        shortCircuitPos = c.emit(node.Token.Pos, code.OpJumpNotTruthy, 9999)
+    } else {
+        // a truthy left side is the answer, anything else moves on to the right side
+        rightPos := c.emit(node.Token.Pos, code.OpJumpNotTruthy, 9999)
+        c.emit(node.Token.Pos, code.OpTrue)
+        shortCircuitPos = c.emit(node.Token.Pos, code.OpJump, 9999)
+        c.changeOperand(rightPos, len(c.currentInstructions()))
+    }
+
+    if err := c.compileExpression(node.Right); err != nil {
+        return err
+    }
+
+    // turn the right side into a boolean
+    falsePos := c.emit(node.Token.Pos, code.OpJumpNotTruthy, 9999)
+    c.emit(node.Token.Pos, code.OpTrue)
+    endPos := c.emit(node.Token.Pos, code.OpJump, 9999)
+
+    c.changeOperand(falsePos, len(c.currentInstructions()))
+    if node.Operator == "&&" {
+        c.changeOperand(shortCircuitPos, len(c.currentInstructions()))
+    }
+    c.emit(node.Token.Pos, code.OpFalse)
+
+    c.changeOperand(endPos, len(c.currentInstructions()))
+    if node.Operator == "||" {
+        c.changeOperand(shortCircuitPos, len(c.currentInstructions()))
+    }
+    return nil
+}
+
+// same order as the evaluator: the target (and its current value for += and friends), then the new value
+// every instruction gets the position of the operator since that's where the evaluator reports any errors
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+    pos := node.Token.Pos
+
+    var op code.Opcode
+    if node.Operator != "=" {
+        var ok bool
+        op, ok = infixOpcodes[node.Operator[:len(node.Operator)-1]]
+        if !ok {
+            return fmt.Errorf("%s: unknown operator %s", pos, node.Operator)
+        }
+    }
+
+    switch target := node.Target.(type) {
+    case *ast.Identifier:
+        if node.Operator != "=" {
+            c.getVariableAt(target.Value, pos)
+        }
+        if err := c.compileExpression(node.Value); err != nil {
+            return err
+        }
+        if node.Operator != "=" {
+            c.emit(pos, op)
+        }
+
+        depth, idx := c.resolve(target.Value)
+        switch depth {
+        case -1:
+            c.emit(pos, code.OpAssignGlobal, idx)
+        case 0:
+            c.emit(pos, code.OpAssignLocal, idx)
+        default:
+            c.emit(pos, code.OpAssignOuter, depth, idx)
+        }
+
+    case *ast.IndexExpression:
+        if err := c.compileExpression(target.Left); err != nil {
+            return err
+        }
+        if err := c.compileExpression(target.Index); err != nil {
+            return err
+        }
+        if node.Operator != "=" {
+            c.emit(pos, code.OpDup2)
+            c.emit(pos, code.OpIndex)
+        }
+        if err := c.compileExpression(node.Value); err != nil {
+            return err
+        }
+        if node.Operator != "=" {
+            c.emit(pos, op)
+        }
+        c.emit(pos, code.OpSetIndex)
+
+    default:
+        return fmt.Errorf("%s: cannot assign to %s", pos, node.Target.String())
+    }
+    return nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+    if err := c.compileExpression(node.Condition); err != nil {
+        return err
+    }
+
+    // the jump targets aren't known yet, so they get a bogus value that is patched once the blocks are compiled
+    jumpNotTruthyPos := c.emit(node.Pos(), code.OpJumpNotTruthy, 9999)
+
+    if err := c.compileStatementsValue(node.Consequence.Statements, code.OpNull); err != nil {
+        return err
+    }
+
+    jumpPos := c.emit(node.Pos(), code.OpJump, 9999)
+
+    c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+    if node.Alternative == nil {
+        c.emit(node.Pos(), code.OpNull)
+    } else {
+        if err := c.compileStatementsValue(node.Alternative.Statements, code.OpNull); err != nil {
+            return err
+        }
+    }
+
+    c.changeOperand(jumpPos, len(c.currentInstructions()))
+    return nil
+}
+
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+    c.emit(node.Pos(), code.OpLoopEnter)
+    start := len(c.currentInstructions())
+
+    if err := c.compileExpression(node.Condition); err != nil {
+        return err
+    }
+    exitPos := c.emit(node.Pos(), code.OpJumpNotTruthy, 9999)
+
+    if err := c.compileLoopBody(node.Body, start); err != nil {
+        return err
+    }
+
+    c.changeOperand(exitPos, len(c.currentInstructions()))
+    c.emit(node.Pos(), code.OpLoopExit)
+    return nil
+}
+
+// the iterator lives on the stack for the whole loop, below the loop's mark so break doesn't throw it away
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+    if err := c.compileExpression(node.Iterable); err != nil {
+        return err
+    }
+    c.emit(node.Pos(), code.OpIter)
+    c.emit(node.Pos(), code.OpLoopEnter)
+
+    start := c.emit(node.Pos(), code.OpIterNext, 9999)
+    c.setVariable(node.Variable)
+
+    if err := c.compileLoopBody(node.Body, start); err != nil {
+        return err
+    }
+
+    c.changeOperand(start, len(c.currentInstructions()))
+    c.emit(node.Pos(), code.OpLoopExit)
+    c.emit(token.Position{}, code.OpPop)
+    return nil
+}
+
+// compiles the body followed by the jump back to the start, breaks end up just after that jump
+func (c *Compiler) compileLoopBody(body *ast.BlockStatement, start int) error {
+    scope := c.currentScope()
+    loop := &loopContext{start: start}
+    scope.loops = append(scope.loops, loop)
+
+    // the body's value isn't used, so nothing is left behind on the stack
+    for _, stmt := range body.Statements {
+        if err := c.compileStatement(stmt); err != nil {
+            return err
+        }
+        if _, ok := stmt.(*ast.ExpressionStatement); ok {
+            c.emit(token.Position{}, code.OpPop)
+        }
+    }
+    c.emit(token.Position{}, code.OpJump, start)
+
+    scope.loops = scope.loops[:len(scope.loops)-1]
+    for _, pos := range loop.breaks {
+        c.changeOperand(pos, len(c.currentInstructions()))
+    }
+    return nil
+}
+
+func (c *Compiler) currentLoop() *loopContext {
+    loops := c.currentScope().loops
+    if len(loops) == 0 {
+        return nil
+    }
+    return loops[len(loops)-1]
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
+    locals := newLocalTable()
+    for _, p := range node.Parameters {
+        locals.define(p.Value)
+    }
+    if node.Rest != nil {
+        locals.define(node.Rest.Value)
+    }
+    for _, def := range node.Defaults {
+        if def != nil {
+            collectLets([]ast.Statement{&ast.ExpressionStatement{Expression: def}}, locals)
+        }
+    }
+    collectLets(node.Body.Statements, locals)
+
+    c.enterScope(locals)
+
+    // the defaults are worked out inside the call, one after the other, so they can use the parameters before them
+    numRequired := len(node.Parameters)
+    for i, def := range node.Defaults {
+        if def == nil {
+            continue
+        }
+        if i < numRequired {
+            numRequired = i
+        }
+        skipPos := c.emit(def.Pos(), code.OpJumpIfArgGiven, i, 9999)
+        if err := c.compileExpression(def); err != nil {
+            return err
+        }
+        c.emit(def.Pos(), code.OpSetLocal, i)
+        copy(c.currentInstructions()[skipPos:], code.Make(code.OpJumpIfArgGiven, i, len(c.currentInstructions())))
+    }
+
+    if err := c.compileStatementsValue(node.Body.Statements, code.OpNull); err != nil {
+        return err
+    }
+    c.emit(node.Body.End(), code.OpReturnValue)
+
+    scope := c.leaveScope()
+
+    fn := &object.CompiledFunction{
+        Instructions:   scope.instructions,
+        Positions:      scope.positions,
+        CallPositions:  scope.callPositions,
+        NumLocals:      len(locals.names),
+        NumParameters:  len(node.Parameters),
+        NumRequired:    numRequired,
+        HasRest:        node.Rest != nil,
+        Name:           node.Name,
+        LocalNames:     locals.names,
+        Parameters:     node.Parameters,
+        Defaults:       node.Defaults,
+        Rest:           node.Rest,
+        Body:           node.Body,
+    }
+
+    c.emit(node.Pos(), code.OpClosure, c.addConstant(fn))
+    return nil
+}
+
+// finds the closest function that has a local with this name, failing that the name is a global
+// gives back how many functions out the local lives and its slot, or a depth of -1 for a global
+func (c *Compiler) resolve(name string) (int, int) {
+    for i := len(c.scopes) - 1; i > 0; i-- {
+        if idx, ok := c.scopes[i].locals.index[name]; ok {
+            return len(c.scopes) - 1 - i, idx
+        }
+    }
+    return -1, c.globals.resolve(name)
+}
+
+func (c *Compiler) getVariable(ident *ast.Identifier) {
+    c.getVariableAt(ident.Value, ident.Pos())
+}
+
+// pos is where an "identifier not found" gets reported
+func (c *Compiler) getVariableAt(name string, pos token.Position) {
+    depth, idx := c.resolve(name)
+    switch depth {
+    case -1:
+        c.emit(pos, code.OpGetGlobal, idx)
+    case 0:
+        c.emit(pos, code.OpGetLocal, idx)
+    default:
+        c.emit(pos, code.OpGetOuter, depth, idx)
+    }
+}
+
+// a let always binds in the function it is in (or the top level), never in an outer one
+func (c *Compiler) setVariable(ident *ast.Identifier) {
+    scope := c.currentScope()
+    if scope.locals == nil {
+        c.emit(ident.Pos(), code.OpSetGlobal, c.globals.resolve(ident.Value))
+        return
+    }
+    c.emit(ident.Pos(), code.OpSetLocal, scope.locals.define(ident.Value))
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+    c.constants = append(c.constants, obj)
+    return len(c.constants) - 1
+}
+
+// adds an instruction to the current scope and gives back where it starts
+func (c *Compiler) emit(pos token.Position, op code.Opcode, operands ...int) int {
+    scope := c.currentScope()
+    offset := len(scope.instructions)
+
+    c.checkOperands(pos, op, operands)
+    scope.instructions = append(scope.instructions, code.Make(op, operands...)...)
+    // instructions like OpPop can never fail, so there's no point remembering where they came from
+    if pos.IsValid() {
+        scope.positions = append(scope.positions, object.SourcePosition{Offset: offset, Pos: pos})
+    }
+
+    return offset
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+    ins := c.currentInstructions()
+    op := code.Opcode(ins[opPos])
+    c.checkOperands(token.Position{}, op, []int{operand})
+    copy(ins[opPos:], code.Make(op, operand))
+}
+
+// code.Make would quietly cut off an operand that doesn't fit (a call with 256 arguments, a jump over more than 64K of code)
+// and the program would do something else than the tree walker, so it's an error instead
+// emit can't give back an error without every caller checking it, so the first one is kept for Compile to return
+func (c *Compiler) checkOperands(pos token.Position, op code.Opcode, operands []int) {
+    if c.err != nil {
+        return
+    }
+
+    def, err := code.Lookup(byte(op))
+    if err != nil {
+        c.err = err
+        return
+    }
+    for i, operand := range operands {
+        if max := code.MaxOperand(def.OperandWidths[i]); operand > max {
+            c.err = &TooBigError{Pos: pos, Message: fmt.Sprintf("program is too big for the vm: %s needs %d, but at most %d fits", def.Name, operand, max)}
+            return
+        }
+    }
+}
+
+func (c *Compiler) currentScope() *compilationScope {
+    return c.scopes[len(c.scopes)-1]
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+    return c.currentScope().instructions
+}
+
+func (c *Compiler) enterScope(locals *localTable) {
+    c.scopes = append(c.scopes, &compilationScope{locals: locals})
+}
+
+func (c *Compiler) leaveScope() *compilationScope {
+    scope := c.currentScope()
+    c.scopes = c.scopes[:len(c.scopes)-1]
+    return scope
+}
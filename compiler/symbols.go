@@ -0,0 +1,126 @@
+package compiler
+
+import "skibidi/ast"
+
+// the tree walker looks names up in its environment at runtime, so a name can be used before the let that defines it runs
+// (for example a function calling another function that gets defined after it)
+// to get the same behaviour every name is given a slot up front, and a slot that hasn't been set yet counts as unbound
+
+// the globals are numbered in one table shared by the whole program, the vm keeps their values in an object.Environment
+// under their names so they're still there for the next program run with it (like the tree walker's)
+type globalTable struct {
+    index   map[string]int
+    names   []string
+}
+
+func newGlobalTable() *globalTable {
+    return &globalTable{index: make(map[string]int)}
+}
+
+// gives back the slot for the name, creating one if this is the first time the name has been seen
+func (g *globalTable) resolve(name string) int {
+    if idx, ok := g.index[name]; ok {
+        return idx
+    }
+    idx := len(g.names)
+    g.index[name] = idx
+    g.names = append(g.names, name)
+    return idx
+}
+
+// the locals of a single function, the parameters come first followed by every name bound by a let in the body
+type localTable struct {
+    index   map[string]int
+    names   []string
+}
+
+func newLocalTable() *localTable {
+    return &localTable{index: make(map[string]int)}
+}
+
+func (l *localTable) define(name string) int {
+    if idx, ok := l.index[name]; ok {
+        return idx
+    }
+    idx := len(l.names)
+    l.index[name] = idx
+    l.names = append(l.names, name)
+    return idx
+}
+
+// finds every let in a function body, blocks don't introduce a new scope so lets inside if/else and loops count too
+// the variable of a for loop is bound the same way a let is
+// nested function literals are skipped, their lets belong to them
+func collectLets(stmts []ast.Statement, locals *localTable) {
+    for _, stmt := range stmts {
+        switch stmt := stmt.(type) {
+        case *ast.LetStatement:
+            locals.define(stmt.Name.Value)
+        case *ast.WhileStatement:
+            collectLets(stmt.Body.Statements, locals)
+        case *ast.ForStatement:
+            locals.define(stmt.Variable.Value)
+            collectLets(stmt.Body.Statements, locals)
+        }
+        walkExpressions(stmt, func(exp ast.Expression) {
+            if ifExp, ok := exp.(*ast.IfExpression); ok {
+                collectLets(ifExp.Consequence.Statements, locals)
+                if ifExp.Alternative != nil {
+                    collectLets(ifExp.Alternative.Statements, locals)
+                }
+            }
+        })
+    }
+}
+
+// calls fn on every expression inside the statement that is still part of the same function
+func walkExpressions(stmt ast.Statement, fn func(ast.Expression)) {
+    switch stmt := stmt.(type) {
+    case *ast.LetStatement:
+        walkExpression(stmt.Value, fn)
+    case *ast.ReturnStatement:
+        walkExpression(stmt.ReturnValue, fn)
+    case *ast.ExpressionStatement:
+        walkExpression(stmt.Expression, fn)
+    case *ast.WhileStatement:
+        walkExpression(stmt.Condition, fn)
+    case *ast.ForStatement:
+        walkExpression(stmt.Iterable, fn)
+    }
+}
+
+func walkExpression(exp ast.Expression, fn func(ast.Expression)) {
+    if exp == nil {
+        return
+    }
+
+    fn(exp)
+
+    switch exp := exp.(type) {
+    case *ast.PrefixExpression:
+        walkExpression(exp.Right, fn)
+    case *ast.InfixExpression:
+        walkExpression(exp.Left, fn)
+        walkExpression(exp.Right, fn)
+    case *ast.IfExpression:
+        // the blocks are handled by whoever is interested in them, only the condition is walked here
+        walkExpression(exp.Condition, fn)
+    case *ast.CallExpression:
+        walkExpression(exp.Function, fn)
+        for _, arg := range exp.Arguments {
+            walkExpression(arg, fn)
+        }
+    case *ast.ArrayLiteral:
+        for _, el := range exp.Elements {
+            walkExpression(el, fn)
+        }
+    case *ast.IndexExpression:
+        walkExpression(exp.Left, fn)
+        walkExpression(exp.Index, fn)
+    case *ast.HashLiteral:
+        for _, pair := range exp.Pairs {
+            walkExpression(pair.Key, fn)
+            walkExpression(pair.Value, fn)
+        }
+    }
+}
@@ -0,0 +1,40 @@
+package diagnostic
+
+// every kind of problem gets a code that stays the same even if the wording of the message changes
+// P is for problems found while parsing, R for ones that happen while the program runs, L for a program hitting a limit
+type Code string
+
+const (
+    UnexpectedToken         Code = "P001" // the grammar needed a specific token and got something else
+    MissingExpression       Code = "P002" // a token that can't start an expression was found where one was needed
+    InvalidNumber           Code = "P003"
+    InvalidAssignTarget     Code = "P004"
+    LoopControlOutsideLoop  Code = "P005"
+    InvalidParameters       Code = "P006"
+    UnclosedBlock           Code = "P007"
+    InvalidEscape           Code = "P008" // found by the lexer, the string is still read up to its closing quote
+
+    Internal                Code = "R000" // something that should never happen, a bug in the interpreter
+    UnknownIdentifier       Code = "R001"
+    TypeMismatch            Code = "R002"
+    UnknownOperator         Code = "R003"
+    IndexNotSupported       Code = "R004"
+    IndexOutOfRange         Code = "R005"
+    UnhashableKey           Code = "R006"
+    UndeclaredAssignment    Code = "R007"
+    NotAFunction            Code = "R008"
+    WrongArgumentCount      Code = "R009"
+    InvalidArgument         Code = "R010"
+    NotIterable             Code = "R011"
+    HostError               Code = "R012" // a Go function the program was given (see the interp package) returned an error
+    DivisionByZero          Code = "R013"
+    IntegerOverflow         Code = "R014" // only when overflow is checked, see evaluator.ErrorOnOverflow
+    FloatNotFinite          Code = "R015" // a float that came out infinite or NaN, which the language has no way to write
+
+    // the program went over one of the limits it was run with (see evaluator.Limits), these are object.LimitError errors
+    CallDepthExceeded       Code = "L001"
+    StepLimitExceeded       Code = "L002"
+    AllocationLimitExceeded Code = "L003"
+    Cancelled               Code = "L004" // the context.Context the program was run with was cancelled or timed out
+    ProgramTooBig           Code = "L005" // only the vm has this one, the program has more of something than its instructions have room for
+)
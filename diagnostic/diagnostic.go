@@ -0,0 +1,152 @@
+// the diagnostic package is how the parser and the evaluator report problems with a program
+// a diagnostic is structured (a code, a span, notes...) so tools like an editor integration don't have to pick apart a message
+package diagnostic
+
+import (
+    "bytes"
+    "fmt"
+    "skibidi/token"
+    "strings"
+)
+
+type Severity int
+
+const (
+    Error Severity = iota
+    Warning
+    Hint
+)
+
+func (s Severity) String() string {
+    switch s {
+    case Error:
+        return "error"
+    case Warning:
+        return "warning"
+    case Hint:
+        return "hint"
+    default:
+        return fmt.Sprintf("Severity(%d)", int(s))
+    }
+}
+
+// the part of the source a diagnostic is about, End is the position just after the last character
+// End can be left as the zero value when only the start is known
+type Span struct {
+    Start   token.Position
+    End     token.Position
+}
+
+func TokenSpan(tok token.Token) Span {
+    return Span{Start: tok.Pos, End: tok.End}
+}
+
+func PosSpan(pos token.Position) Span {
+    return Span{Start: pos, End: pos}
+}
+
+// extra context, like where the thing that clashes with the mistake is, the span is optional
+type Note struct {
+    Message string
+    Span    Span
+}
+
+// a change to the source that would fix the problem, the span is replaced by Replacement
+// (an empty span is an insertion)
+type Fix struct {
+    Message     string
+    Span        Span
+    Replacement string
+}
+
+type Diagnostic struct {
+    Severity    Severity
+    Code        Code
+    Message     string
+    Span        Span
+    Notes       []Note
+    Fix         *Fix
+}
+
+// the one line form, file:line:col: message, which is also what gets printed when there's no source to show
+func (d Diagnostic) Error() string {
+    if !d.Span.Start.IsValid() && d.Span.Start.Filename == "" {
+        return d.Message
+    }
+    return d.Span.Start.String() + ": " + d.Message
+}
+
+// renders the diagnostic along with the line of source it points at, src is the whole source the positions refer to
+//
+//  error[P002]: no prefix parse function for ; found
+//   --> test.skb:2:13
+//    |
+//  2 | let y = 10 +;
+//    |             ^
+//    = help: ...
+func (d Diagnostic) Render(src string) string {
+    var out bytes.Buffer
+
+    out.WriteString(d.Severity.String())
+    if d.Code != "" {
+        out.WriteString("[" + string(d.Code) + "]")
+    }
+    out.WriteString(": " + d.Message + "\n")
+
+    start := d.Span.Start
+    gutter := strings.Repeat(" ", len(fmt.Sprint(start.Line)))
+
+    if start.IsValid() {
+        out.WriteString(gutter + "--> " + start.String() + "\n")
+        if line, ok := sourceLine(src, start.Line); ok {
+            out.WriteString(gutter + " |\n")
+            out.WriteString(fmt.Sprintf("%d | %s\n", start.Line, line))
+            out.WriteString(gutter + " | " + underline(line, d.Span) + "\n")
+        }
+    }
+
+    for _, note := range d.Notes {
+        out.WriteString(gutter + " = note: ")
+        if note.Span.Start.IsValid() {
+            out.WriteString(note.Span.Start.String() + ": ")
+        }
+        out.WriteString(note.Message + "\n")
+    }
+
+    if d.Fix != nil {
+        out.WriteString(gutter + " = help: " + d.Fix.Message + "\n")
+    }
+
+    return out.String()
+}
+
+// lines start at 1, a trailing \r is dropped so windows line endings don't mess up the excerpt
+func sourceLine(src string, n int) (string, bool) {
+    lines := strings.Split(src, "\n")
+    if n < 1 || n > len(lines) {
+        return "", false
+    }
+    return strings.TrimSuffix(lines[n-1], "\r"), true
+}
+
+// the carets under the span, the padding copies any tabs in the line so the carets still line up
+func underline(line string, span Span) string {
+    var out strings.Builder
+
+    runes := []rune(line)
+    for i := 0; i < span.Start.Column-1; i++ {
+        if i < len(runes) && runes[i] == '\t' {
+            out.WriteRune('\t')
+        } else {
+            out.WriteRune(' ')
+        }
+    }
+
+    width := 1
+    if span.End.Line == span.Start.Line && span.End.Column > span.Start.Column {
+        width = span.End.Column - span.Start.Column
+    }
+    out.WriteString(strings.Repeat("^", width))
+
+    return out.String()
+}
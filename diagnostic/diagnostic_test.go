@@ -0,0 +1,86 @@
+package diagnostic
+
+import (
+    "skibidi/token"
+    "testing"
+)
+
+func pos(line int, column int) token.Position {
+    return token.Position{Filename: "test.skb", Line: line, Column: column}
+}
+
+func TestError(t *testing.T) {
+    tests := []struct {
+        d           Diagnostic
+        expected    string
+    }{
+        {Diagnostic{Message: "oops", Span: PosSpan(pos(2, 3))}, "test.skb:2:3: oops"},
+        {Diagnostic{Message: "oops"}, "oops"},
+    }
+
+    for _, tt := range tests {
+        if tt.d.Error() != tt.expected {
+            t.Errorf("wrong Error, want: %q, got: %q", tt.expected, tt.d.Error())
+        }
+    }
+}
+
+func TestRender(t *testing.T) {
+    src := "let x = 1;\nlet y = x +;\n"
+
+    d := Diagnostic{
+        Severity:   Error,
+        Code:       MissingExpression,
+        Message:    "no prefix parse function for ; found",
+        Span:       Span{Start: pos(2, 12), End: pos(2, 13)},
+        Notes:      []Note{{Message: "x is defined here", Span: PosSpan(pos(1, 5))}, {Message: "no span"}},
+        Fix:        &Fix{Message: "finish the expression", Span: PosSpan(pos(2, 12)), Replacement: "1"},
+    }
+
+    expected := "error[P002]: no prefix parse function for ; found\n" +
+        " --> test.skb:2:12\n" +
+        "  |\n" +
+        "2 | let y = x +;\n" +
+        "  |            ^\n" +
+        "  = note: test.skb:1:5: x is defined here\n" +
+        "  = note: no span\n" +
+        "  = help: finish the expression\n"
+
+    if d.Render(src) != expected {
+        t.Errorf("wrong rendering, want:\n%s\ngot:\n%s", expected, d.Render(src))
+    }
+}
+
+func TestRenderUnderline(t *testing.T) {
+    tests := []struct {
+        line        string
+        span        Span
+        expected    string
+    }{
+        // the whole token is underlined
+        {"let abc = 1", Span{Start: pos(1, 5), End: pos(1, 8)}, "    ^^^"},
+        // tabs are kept so the caret still lines up
+        {"\t\tfoo", Span{Start: pos(1, 3), End: pos(1, 6)}, "\t\t^^^"},
+        // columns count characters, not bytes
+        {`"héllo" + x`, Span{Start: pos(1, 11), End: pos(1, 12)}, "          ^"},
+        // a span going over several lines only gets a caret at its start
+        {"foo(", Span{Start: pos(1, 4), End: pos(3, 1)}, "   ^"},
+    }
+
+    for _, tt := range tests {
+        got := underline(tt.line, tt.span)
+        if got != tt.expected {
+            t.Errorf("wrong underline for %q, want: %q, got: %q", tt.line, tt.expected, got)
+        }
+    }
+}
+
+// the position can point at a line that isn't in the source that was given, then there's just no excerpt
+func TestRenderWithoutSource(t *testing.T) {
+    d := Diagnostic{Severity: Error, Message: "oops", Span: PosSpan(pos(5, 1))}
+
+    expected := "error: oops\n --> test.skb:5:1\n"
+    if d.Render("one line") != expected {
+        t.Errorf("wrong rendering, want:\n%s\ngot:\n%s", expected, d.Render("one line"))
+    }
+}
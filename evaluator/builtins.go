@@ -0,0 +1,228 @@
+package evaluator
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "math"
+    "math/big"
+    "os"
+    "skibidi/diagnostic"
+    "skibidi/object"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+)
+
+// functions that are implemented in Go rather than skibidi
+// evalIdentifier only looks in here once the environment doesn't have a binding, so user code can shadow any of these
+var builtins = map[string]*object.Builtin{
+    "len":      {Name: "len", Fn: builtinLen},
+    "puts":     {Name: "puts", Fn: func(args ...object.Object) object.Object { return builtinPuts(os.Stdout, args...) }},
+    "first":    {Name: "first", Fn: builtinFirst},
+    "last":     {Name: "last", Fn: builtinLast},
+    "rest":     {Name: "rest", Fn: builtinRest},
+    "push":     {Name: "push", Fn: builtinPush},
+    "type":     {Name: "type", Fn: builtinType},
+    "str":      {Name: "str", Fn: builtinStr},
+    "int":      {Name: "int", Fn: builtinInt},
+    "float":    {Name: "float", Fn: builtinFloat},
+}
+
+func wrongNumberOfArgs(name string, got int, want int) *object.Error {
+    return newError(diagnostic.WrongArgumentCount, "wrong number of arguments to `%s`. got=%d, want=%d", name, got, want)
+}
+
+func unsupportedArg(name string, arg object.Object) *object.Error {
+    return newError(diagnostic.InvalidArgument, "argument to `%s` not supported, got %s", name, arg.Type())
+}
+
+// a string's length is in characters rather than bytes, the same count a for loop over it gives
+func builtinLen(args ...object.Object) object.Object {
+    if len(args) != 1 {
+        return wrongNumberOfArgs("len", len(args), 1)
+    }
+
+    switch arg := args[0].(type) {
+    case *object.String:
+        return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+    case *object.Array:
+        return &object.Integer{Value: int64(len(arg.Elements))}
+    case *object.Hash:
+        return &object.Integer{Value: int64(len(arg.Pairs))}
+    default:
+        return unsupportedArg("len", args[0])
+    }
+}
+
+// prints every argument on its own line, always gives back null
+// a program's puts goes to the Stdout of the Context it runs with (see callBuiltin), Fn itself only knows about os.Stdout
+func builtinPuts(out io.Writer, args ...object.Object) object.Object {
+    for _, arg := range args {
+        fmt.Fprintln(out, arg.Inspect())
+    }
+    return NULL
+}
+
+// puts is the one builtin that needs more than its arguments
+func callBuiltin(c *Context, fn *object.Builtin, args []object.Object) object.Object {
+    if fn == builtins["puts"] {
+        return builtinPuts(c.Stdout, args...)
+    }
+    return fn.Fn(args...)
+}
+
+func builtinFirst(args ...object.Object) object.Object {
+    if len(args) != 1 {
+        return wrongNumberOfArgs("first", len(args), 1)
+    }
+
+    arr, ok := args[0].(*object.Array)
+    if !ok {
+        return unsupportedArg("first", args[0])
+    }
+
+    if len(arr.Elements) > 0 {
+        return arr.Elements[0]
+    }
+    return NULL
+}
+
+func builtinLast(args ...object.Object) object.Object {
+    if len(args) != 1 {
+        return wrongNumberOfArgs("last", len(args), 1)
+    }
+
+    arr, ok := args[0].(*object.Array)
+    if !ok {
+        return unsupportedArg("last", args[0])
+    }
+
+    length := len(arr.Elements)
+    if length > 0 {
+        return arr.Elements[length-1]
+    }
+    return NULL
+}
+
+// everything but the first element, as a new array (the original is left alone)
+func builtinRest(args ...object.Object) object.Object {
+    if len(args) != 1 {
+        return wrongNumberOfArgs("rest", len(args), 1)
+    }
+
+    arr, ok := args[0].(*object.Array)
+    if !ok {
+        return unsupportedArg("rest", args[0])
+    }
+
+    length := len(arr.Elements)
+    if length > 0 {
+        newElements := make([]object.Object, length-1)
+        copy(newElements, arr.Elements[1:length])
+        return &object.Array{Elements: newElements}
+    }
+    return NULL
+}
+
+// push gives back a new array with the element added to the end, the original array is left alone
+func builtinPush(args ...object.Object) object.Object {
+    if len(args) != 2 {
+        return wrongNumberOfArgs("push", len(args), 2)
+    }
+
+    arr, ok := args[0].(*object.Array)
+    if !ok {
+        return unsupportedArg("push", args[0])
+    }
+
+    length := len(arr.Elements)
+    newElements := make([]object.Object, length+1)
+    copy(newElements, arr.Elements)
+    newElements[length] = args[1]
+
+    return &object.Array{Elements: newElements}
+}
+
+// gives back the name of the type as a string, eg type(1) is "INTEGER"
+func builtinType(args ...object.Object) object.Object {
+    if len(args) != 1 {
+        return wrongNumberOfArgs("type", len(args), 1)
+    }
+    return &object.String{Value: string(args[0].Type())}
+}
+
+func builtinStr(args ...object.Object) object.Object {
+    if len(args) != 1 {
+        return wrongNumberOfArgs("str", len(args), 1)
+    }
+
+    if str, ok := args[0].(*object.String); ok {
+        return str
+    }
+    return &object.String{Value: args[0].Inspect()}
+}
+
+// converts strings, booleans and floats to integers, integers are just handed back
+// floats are truncated towards zero, so int(-2.7) is -2, and one too big for an int64 becomes a big integer
+func builtinInt(args ...object.Object) object.Object {
+    if len(args) != 1 {
+        return wrongNumberOfArgs("int", len(args), 1)
+    }
+
+    switch arg := args[0].(type) {
+    case *object.Integer, *object.BigInt:
+        return arg
+    case *object.Float:
+        if arg.Value >= math.MaxInt64 || arg.Value < math.MinInt64 {
+            value, _ := big.NewFloat(arg.Value).Int(nil)
+            return object.IntegerFromBig(value)
+        }
+        return &object.Integer{Value: int64(arg.Value)}
+    case *object.Boolean:
+        if arg.Value {
+            return &object.Integer{Value: 1}
+        }
+        return &object.Integer{Value: 0}
+    case *object.String:
+        value, err := strconv.ParseInt(strings.TrimSpace(arg.Value), 10, 64)
+        if errors.Is(err, strconv.ErrRange) {
+            if n, ok := new(big.Int).SetString(strings.TrimSpace(arg.Value), 10); ok {
+                return object.IntegerFromBig(n)
+            }
+        }
+        if err != nil {
+            return newError(diagnostic.InvalidArgument, "could not parse %q as integer", arg.Value)
+        }
+        return &object.Integer{Value: value}
+    default:
+        return unsupportedArg("int", args[0])
+    }
+}
+
+// converts integers and strings to floats, floats are just handed back
+// floats are always finite, so float("inf") and an integer too big for a float64 are errors
+func builtinFloat(args ...object.Object) object.Object {
+    if len(args) != 1 {
+        return wrongNumberOfArgs("float", len(args), 1)
+    }
+
+    switch arg := args[0].(type) {
+    case *object.Float:
+        return arg
+    case *object.Integer, *object.BigInt:
+        value := toFloat(arg)
+        if math.IsInf(value, 0) {
+            return newError(diagnostic.FloatNotFinite, "%s is too big for a float", arg.Inspect())
+        }
+        return &object.Float{Value: value}
+    case *object.String:
+        value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+        if err != nil || math.IsInf(value, 0) || math.IsNaN(value) {
+            return newError(diagnostic.InvalidArgument, "could not parse %q as float", arg.Value)
+        }
+        return &object.Float{Value: value}
+    default:
+        return unsupportedArg("float", args[0])
+    }
+}
@@ -0,0 +1,154 @@
+package evaluator
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "os"
+    "skibidi/ast"
+    "skibidi/diagnostic"
+    "skibidi/object"
+)
+
+// limits for running programs that can't be trusted, a zero field means no limit
+type Limits struct {
+    MaxDepth        int // how deeply function calls can nest
+    MaxSteps        int64 // how many nodes can be evaluated in total
+    MaxAllocation   int64 // roughly how many bytes of integers, floats, strings, arrays and hashes the program can create
+}
+
+// plain Eval still limits the call depth, running out of Go stack can't be recovered from and would take the whole process down
+var DefaultLimits = Limits{MaxDepth: 10000}
+
+// what integer arithmetic does when the result doesn't fit in an int64
+type Overflow int
+
+const (
+    PromoteOnOverflow Overflow = iota // the result becomes an object.BigInt, the default
+    ErrorOnOverflow   // stops with an error, for programs whose numbers have to fit in an int64 (to be stored somewhere that expects one for example)
+)
+
+// how often the context.Context is checked, checking it on every step would slow everything down
+const cancelCheckInterval = 1024
+
+// everything a run of the evaluator keeps track of for its limits, the counts add up over every Eval it's used for
+// a Context is only meant to be used by one goroutine at a time
+type Context struct {
+    ctx         context.Context
+    limits      Limits
+
+    Overflow    Overflow
+    Stdout      io.Writer // where puts writes to, os.Stdout unless it's set to something else
+
+    depth       int
+    steps       int64
+    allocated   int64
+}
+
+// a nil ctx is the same as context.Background()
+func NewContext(ctx context.Context, limits Limits) *Context {
+    if ctx == nil {
+        ctx = context.Background()
+    }
+    return &Context{ctx: ctx, limits: limits, Stdout: os.Stdout}
+}
+
+// how many nodes have been evaluated so far
+func (c *Context) Steps() int64 {
+    return c.steps
+}
+
+// the estimate of how many bytes have been allocated so far, only counted when there's an allocation limit
+func (c *Context) Allocated() int64 {
+    return c.allocated
+}
+
+// the evaluator with no limits other than DefaultLimits, see EvalContext
+func Eval(node ast.Node, env *object.Environment) object.Object {
+    return EvalContext(NewContext(context.Background(), DefaultLimits), node, env)
+}
+
+// evaluates node, stopping with an object.LimitError error as soon as any of the limits of c is hit
+// or c's context.Context is done
+func EvalContext(c *Context, node ast.Node, env *object.Environment) object.Object {
+    return eval(c, node, env)
+}
+
+func newLimitError(code diagnostic.Code, format string, a ...interface{}) *object.Error {
+    return &object.Error{Kind: object.LimitError, Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+func (c *Context) step() *object.Error {
+    c.steps++
+
+    if c.limits.MaxSteps > 0 && c.steps > c.limits.MaxSteps {
+        return newLimitError(diagnostic.StepLimitExceeded, "step limit exceeded (%d steps)", c.limits.MaxSteps)
+    }
+
+    if c.steps%cancelCheckInterval == 0 {
+        if err := c.ctx.Err(); err != nil {
+            return newLimitError(diagnostic.Cancelled, "evaluation stopped: %s", err)
+        }
+    }
+
+    return nil
+}
+
+func (c *Context) enterCall() *object.Error {
+    if c.limits.MaxDepth > 0 && c.depth >= c.limits.MaxDepth {
+        return CallDepthError(c.limits.MaxDepth)
+    }
+    c.depth++
+    return nil
+}
+
+func (c *Context) leaveCall() {
+    c.depth--
+}
+
+// only the nodes that always make a new object are counted (plus what builtins give back, see applyFunction)
+// everything else gives back an object that already exists, like a variable's value or an element of an array
+func (c *Context) account(node ast.Node, result object.Object) *object.Error {
+    if c.limits.MaxAllocation <= 0 {
+        return nil
+    }
+
+    switch node := node.(type) {
+    case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.ArrayLiteral, *ast.HashLiteral,
+        *ast.PrefixExpression, *ast.InfixExpression, *ast.FunctionLiteral:
+        return c.allocate(result)
+    case *ast.AssignExpression:
+        // x += y makes a new value, plain x = y just stores one that was already counted
+        if node.Operator != "=" {
+            return c.allocate(result)
+        }
+    }
+    return nil
+}
+
+// the sizes are estimates of what the Go objects take up, close enough to stop a program that builds up a huge string or array
+func (c *Context) allocate(obj object.Object) *object.Error {
+    if c.limits.MaxAllocation <= 0 {
+        return nil
+    }
+
+    switch obj := obj.(type) {
+    case *object.Integer, *object.Float:
+        c.allocated += 16
+    case *object.BigInt:
+        c.allocated += 32 + int64(len(obj.Value.Bits()))*8
+    case *object.String:
+        c.allocated += 16 + int64(len(obj.Value))
+    case *object.Array:
+        c.allocated += 24 + 16*int64(len(obj.Elements))
+    case *object.Hash:
+        c.allocated += 48 + 64*int64(len(obj.Pairs))
+    case *object.Function, *object.Closure:
+        c.allocated += 64
+    }
+
+    if c.allocated > c.limits.MaxAllocation {
+        return newLimitError(diagnostic.AllocationLimitExceeded, "allocation limit exceeded (%d bytes)", c.limits.MaxAllocation)
+    }
+    return nil
+}
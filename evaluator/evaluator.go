@@ -2,84 +2,168 @@ package evaluator
 
 import (
     "skibidi/ast"
+    "skibidi/diagnostic"
     "skibidi/object"
+    "skibidi/token"
     "fmt"
+    "math"
+    "math/big"
+    "strings"
 )
 
 var (
     NULL  = &object.Null{}
     TRUE  = &object.Boolean{Value: true}
     FALSE = &object.Boolean{Value: false}
+
+    BREAK    = &object.Break{}
+    CONTINUE = &object.Continue{}
 )
 
 // we use object.Objects as a generic type which is then evaluated to the right type by the object.go file
 
-func Eval(node ast.Node, env *object.Environment) object.Object {
+func eval(c *Context, node ast.Node, env *object.Environment) object.Object {
+    if err := c.step(); err != nil {
+        return err
+    }
+
+    result := evalNode(c, node, env)
+    if err := c.account(node, result); err != nil {
+        result = err
+    }
+
+    // errors bubble up through every node above the one that caused them
+    // so only the first (innermost) node gets to stamp its position on the error
+    if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+        err.Pos = errorPosition(node)
+    }
+
+    return result
+}
+
+// for operators the error is pointed at the operator itself rather than the start of the left operand
+func errorPosition(node ast.Node) token.Position {
+    switch node := node.(type) {
+    case *ast.InfixExpression:
+        return node.Token.Pos
+    case *ast.IndexExpression:
+        return node.Token.Pos
+    case *ast.AssignExpression:
+        return node.Token.Pos
+    default:
+        return node.Pos()
+    }
+}
+
+func evalNode(c *Context, node ast.Node, env *object.Environment) object.Object {
     switch node := node.(type) {
         
     // statements
     case *ast.Identifier:
         return evalIdentifier(node, env)
     case *ast.Program:
-        return evalProgram(node, env)
+        return evalProgram(c, node, env)
     case *ast.ExpressionStatement:
-        return Eval(node.Expression, env) // recursive evaluation call
+        return eval(c, node.Expression, env) // recursive evaluation call
     case *ast.BlockStatement:
-        return evalBlockStatement(node, env)
+        return evalBlockStatement(c, node, env)
     case *ast.ReturnStatement:
-        val := Eval(node.ReturnValue, env)
+        val := eval(c, node.ReturnValue, env)
         if isError(val) {
             return val
         }
         return &object.ReturnValue{Value: val}
     case *ast.LetStatement:
-        val := Eval(node.Value, env)
+        val := valueOf(eval(c, node.Value, env))
         if isError(val) {
             return val
         }
         // adding associations to the environment when evaluating let statements
         env.Set(node.Name.Value, val)
+    case *ast.WhileStatement:
+        return evalWhileStatement(c, node, env)
+    case *ast.ForStatement:
+        return evalForStatement(c, node, env)
+    case *ast.BreakStatement:
+        return BREAK
+    case *ast.ContinueStatement:
+        return CONTINUE
 
     // expressions
     case *ast.CallExpression:
-        function := Eval(node.Function, env)
+        function := eval(c, node.Function, env)
         if isError(function) {
             return function
         }
         // the arguments are 'simplified' by being evaluated individually before being evaluated in the function
         // for example, if the call is "add(2 + 2, 3 + 3);" then we want the actual function call to be "add(4, 6);"
-        args := evalExpressions(node.Arguments, env)
+        args := evalExpressions(c, node.Arguments, env)
         if len(args) == 1 && isError(args[0]) {
             return args[0]
         }
-        return applyFunction(function, args)
+        return applyFunction(c, function, args, node.Token.Pos)
     case *ast.IntegerLiteral:
+        if node.Big != nil {
+            return &object.BigInt{Value: node.Big}
+        }
         return &object.Integer{Value: node.Value}
+    case *ast.FloatLiteral:
+        return &object.Float{Value: node.Value}
+    case *ast.StringLiteral:
+        return &object.String{Value: node.Value}
     case *ast.Boolean:
         return boolToBooleanObj(node.Value)
     case *ast.PrefixExpression:
-        right := Eval(node.Right, env)
+        right := eval(c, node.Right, env)
         if isError(right) {
             return right
         }
-        return evalPrefixExpression(node.Operator, right)
+        return evalPrefixExpression(node.Operator, right, c.Overflow)
     case *ast.InfixExpression:
+        if node.Operator == "&&" || node.Operator == "||" {
+            return evalLogicalExpression(c, node, env)
+        }
         // in the case an error is encountered, stop evaluation then, no point in continuing with an error
-        left := Eval(node.Left, env)
+        left := eval(c, node.Left, env)
         if isError(left) {
             return left
         }
-        right := Eval(node.Right, env)
+        right := eval(c, node.Right, env)
         if isError(right) {
             return right
         }
-        return evalInfixExpression(node.Operator, left, right)
+        return evalInfixExpression(node.Operator, left, right, c.Overflow)
     case *ast.IfExpression:
-        return evalIfExpression(node, env)
+        return evalIfExpression(c, node, env)
     case *ast.FunctionLiteral:
-        params := node.Parameters
-        body := node.Body
-        return &object.Function{Parameters: params, Env: env, Body: body}
+        return &object.Function{
+            Parameters: node.Parameters,
+            Defaults:   node.Defaults,
+            Rest:       node.Rest,
+            Env:        env,
+            Body:       node.Body,
+            Name:       node.Name,
+        }
+    case *ast.ArrayLiteral:
+        elements := evalExpressions(c, node.Elements, env)
+        if len(elements) == 1 && isError(elements[0]) {
+            return elements[0]
+        }
+        return &object.Array{Elements: elements}
+    case *ast.IndexExpression:
+        left := eval(c, node.Left, env)
+        if isError(left) {
+            return left
+        }
+        index := eval(c, node.Index, env)
+        if isError(index) {
+            return index
+        }
+        return evalIndexExpression(left, index)
+    case *ast.HashLiteral:
+        return evalHashLiteral(c, node, env)
+    case *ast.AssignExpression:
+        return evalAssignExpression(c, node, env)
 
     }
     return nil
@@ -106,80 +190,463 @@ func evalBangOperatorExpression(right object.Object) object.Object {
     }
 }
 
-func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-    // if the value to the right of the minus is not an integer then just return null
-    if right.Type() != object.INTEGER_OBJ {
-        return newError("unknown operator: -%s", right.Type())
+func evalMinusPrefixOperatorExpression(right object.Object, overflow Overflow) object.Object {
+    switch right := right.(type) {
+    case *object.Integer:
+        // the smallest int64 has no positive counterpart
+        if right.Value == math.MinInt64 {
+            return negateBigInteger(right, overflow)
+        }
+        return &object.Integer{Value: -right.Value}
+    case *object.BigInt:
+        return negateBigInteger(right, overflow)
+    case *object.Float:
+        return &object.Float{Value: -right.Value}
+    default:
+        return newError(diagnostic.UnknownOperator, "unknown operator: -%s", right.Type())
     }
-    value := right.(*object.Integer).Value
-    return &object.Integer{Value: -value}
 }
 
-func evalPrefixExpression(operator string, right object.Object) object.Object {
+func negateBigInteger(right object.Object, overflow Overflow) object.Object {
+    result := new(big.Int).Neg(toBigInt(right))
+    if overflow == ErrorOnOverflow && !result.IsInt64() {
+        return newError(diagnostic.IntegerOverflow, "integer overflow: -(%s)", right.Inspect())
+    }
+    return object.IntegerFromBig(result)
+}
+
+func evalPrefixExpression(operator string, right object.Object, overflow Overflow) object.Object {
     switch operator {
     case "!":
         return evalBangOperatorExpression(right)
     case "-":
-        return evalMinusPrefixOperatorExpression(right)
+        return evalMinusPrefixOperatorExpression(right, overflow)
     default:
-        return newError("unknown operator: %s%s", operator, right.Type())
+        return newError(diagnostic.UnknownOperator, "unknown operator: %s%s", operator, right.Type())
     }
 }
 
-func evalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+func evalInfixExpression(operator string, left object.Object, right object.Object, overflow Overflow) object.Object {
     switch {
     case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
-        return evalIntegerInfixExpression(operator, left, right)
+        return evalIntegerInfixExpression(operator, left, right, overflow)
+    // an integer mixed with a float is promoted to a float, so 1 + 2.5 is 3.5
+    case isNumber(left) && isNumber(right):
+        return evalFloatInfixExpression(operator, left, right)
+    case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+        return evalStringInfixExpression(operator, left, right)
     case operator == "==":
         return boolToBooleanObj(left == right)
     case operator == "!=":
         return boolToBooleanObj(left != right)
     case left.Type() != right.Type():
-        return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+        return newError(diagnostic.TypeMismatch, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
     default:
-        return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+        return newError(diagnostic.UnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+    }
+}
+
+// && and || can't go through evalInfixExpression since the right side mustn't be evaluated when the left already decides the answer
+// both sides are judged by truthiness (same as an if condition) and the result is always a boolean
+func evalLogicalExpression(c *Context, node *ast.InfixExpression, env *object.Environment) object.Object {
+    left := eval(c, node.Left, env)
+    if isError(left) {
+        return left
+    }
+
+    if node.Operator == "&&" && !isTruthy(left) {
+        return FALSE
+    }
+    if node.Operator == "||" && isTruthy(left) {
+        return TRUE
+    }
+
+    right := eval(c, node.Right, env)
+    if isError(right) {
+        return right
     }
+    return boolToBooleanObj(isTruthy(right))
 }
 
-func evalIntegerInfixExpression(operator string, left object.Object, right object.Object) object.Object {
-    leftVal := left.(*object.Integer).Value
-    rightVal := right.(*object.Integer).Value
+func evalIntegerInfixExpression(operator string, left object.Object, right object.Object, overflow Overflow) object.Object {
+    leftInt, leftOk := left.(*object.Integer)
+    rightInt, rightOk := right.(*object.Integer)
+    if !leftOk || !rightOk {
+        return evalBigIntegerInfixExpression(operator, left, right, overflow)
+    }
+    leftVal := leftInt.Value
+    rightVal := rightInt.Value
+
+    switch operator {
+    case "+", "-", "*", "/":
+        // go would panic and take the whole program (or REPL) down with it
+        if operator == "/" && rightVal == 0 {
+            return newError(diagnostic.DivisionByZero, "division by zero: %d %s %d", leftVal, operator, rightVal)
+        }
+        result, ok := integerArithmetic(operator, leftVal, rightVal)
+        if !ok {
+            return evalBigIntegerInfixExpression(operator, left, right, overflow)
+        }
+        return &object.Integer{Value: result}
+    case "<":
+        return boolToBooleanObj(leftVal < rightVal)
+    case ">":
+        return boolToBooleanObj(leftVal > rightVal)
+    case "<=":
+        return boolToBooleanObj(leftVal <= rightVal)
+    case ">=":
+        return boolToBooleanObj(leftVal >= rightVal)
+    case "==":
+        return boolToBooleanObj(leftVal == rightVal)
+    case "!=":
+        return boolToBooleanObj(leftVal != rightVal)
+    default:
+        return newError(diagnostic.UnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+    }
+
+}
 
+// the slow path, used once either side or the result doesn't fit in an int64
+// the result goes back to being an Integer if it fits in one
+func evalBigIntegerInfixExpression(operator string, left object.Object, right object.Object, overflow Overflow) object.Object {
+    leftVal := toBigInt(left)
+    rightVal := toBigInt(right)
 
+    var result *big.Int
     switch operator {
     case "+":
-        return &object.Integer{Value: leftVal + rightVal}
+        result = new(big.Int).Add(leftVal, rightVal)
     case "-":
-        return &object.Integer{Value: leftVal - rightVal}
+        result = new(big.Int).Sub(leftVal, rightVal)
     case "*":
-        return &object.Integer{Value: leftVal * rightVal}
+        result = new(big.Int).Mul(leftVal, rightVal)
     case "/":
-        return &object.Integer{Value: leftVal / rightVal}
+        if rightVal.Sign() == 0 {
+            return newError(diagnostic.DivisionByZero, "division by zero: %s %s %s", left.Inspect(), operator, right.Inspect())
+        }
+        // Quo rounds towards zero like int64 division does, Div wouldn't for negative numbers
+        result = new(big.Int).Quo(leftVal, rightVal)
+    case "<":
+        return boolToBooleanObj(leftVal.Cmp(rightVal) < 0)
+    case ">":
+        return boolToBooleanObj(leftVal.Cmp(rightVal) > 0)
+    case "<=":
+        return boolToBooleanObj(leftVal.Cmp(rightVal) <= 0)
+    case ">=":
+        return boolToBooleanObj(leftVal.Cmp(rightVal) >= 0)
+    case "==":
+        return boolToBooleanObj(leftVal.Cmp(rightVal) == 0)
+    case "!=":
+        return boolToBooleanObj(leftVal.Cmp(rightVal) != 0)
+    default:
+        return newError(diagnostic.UnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+    }
+
+    if overflow == ErrorOnOverflow && !result.IsInt64() {
+        return newError(diagnostic.IntegerOverflow, "integer overflow: %s %s %s", left.Inspect(), operator, right.Inspect())
+    }
+    return object.IntegerFromBig(result)
+}
+
+// only called on an integer, a BigInt's value is handed back as it is so it mustn't be changed
+func toBigInt(obj object.Object) *big.Int {
+    if i, ok := obj.(*object.Integer); ok {
+        return big.NewInt(i.Value)
+    }
+    return obj.(*object.BigInt).Value
+}
+
+// the result wrapped around like go does it, ok is false if it didn't fit in an int64
+func integerArithmetic(operator string, a int64, b int64) (result int64, ok bool) {
+    switch operator {
+    case "+":
+        result = a + b
+        return result, (b >= 0) == (result >= a)
+    case "-":
+        result = a - b
+        return result, (b >= 0) == (result <= a)
+    case "*":
+        result = a * b
+        // the division undoes the multiplication unless it wrapped, except for -1 * MinInt64 which wraps to itself
+        return result, a == 0 || result/a == b && !(a == -1 && b == math.MinInt64)
+    default: // "/", the only way to overflow is MinInt64 / -1
+        return a / b, !(a == math.MinInt64 && b == -1)
+    }
+}
+
+func evalFloatInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+    leftVal := toFloat(left)
+    rightVal := toFloat(right)
+
+    switch operator {
+    case "+":
+        return finiteFloat(leftVal + rightVal, left, operator, right)
+    case "-":
+        return finiteFloat(leftVal - rightVal, left, operator, right)
+    case "*":
+        return finiteFloat(leftVal * rightVal, left, operator, right)
+    case "/":
+        if rightVal == 0 {
+            return newError(diagnostic.DivisionByZero, "division by zero: %s %s %s", left.Inspect(), operator, right.Inspect())
+        }
+        return finiteFloat(leftVal / rightVal, left, operator, right)
     case "<":
         return boolToBooleanObj(leftVal < rightVal)
     case ">":
         return boolToBooleanObj(leftVal > rightVal)
+    case "<=":
+        return boolToBooleanObj(leftVal <= rightVal)
+    case ">=":
+        return boolToBooleanObj(leftVal >= rightVal)
     case "==":
         return boolToBooleanObj(leftVal == rightVal)
     case "!=":
         return boolToBooleanObj(leftVal != rightVal)
     default:
-        return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+        return newError(diagnostic.UnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+    }
+}
+
+// the language has no way to write an infinity or a NaN, so a float that overflows into one is an error
+// rather than a value whose Inspect() couldn't be read back
+func finiteFloat(value float64, left object.Object, operator string, right object.Object) object.Object {
+    if math.IsInf(value, 0) || math.IsNaN(value) {
+        return newError(diagnostic.FloatNotFinite, "float overflow: %s %s %s", left.Inspect(), operator, right.Inspect())
     }
+    return &object.Float{Value: value}
+}
 
+func isNumber(obj object.Object) bool {
+    return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-    condition := Eval(ie.Condition, env)
+// only called on something isNumber already said yes to
+func toFloat(obj object.Object) float64 {
+    switch n := obj.(type) {
+    case *object.Integer:
+        return float64(n.Value)
+    case *object.BigInt:
+        f, _ := new(big.Float).SetInt(n.Value).Float64()
+        return f
+    default:
+        return obj.(*object.Float).Value
+    }
+}
+
+// strings are compared by value, unlike booleans and null which are compared by pointer since there is only ever one of each
+func evalStringInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+    leftVal := left.(*object.String).Value
+    rightVal := right.(*object.String).Value
+
+    switch operator {
+    case "+":
+        return &object.String{Value: leftVal + rightVal}
+    case "==":
+        return boolToBooleanObj(leftVal == rightVal)
+    case "!=":
+        return boolToBooleanObj(leftVal != rightVal)
+    default:
+        return newError(diagnostic.UnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+    }
+}
+
+func evalIndexExpression(left object.Object, index object.Object) object.Object {
+    switch {
+    case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+        return evalArrayIndexExpression(left, index)
+    case left.Type() == object.HASH_OBJ:
+        return evalHashIndexExpression(left, index)
+    default:
+        return newError(diagnostic.IndexNotSupported, "index operator not supported: %s[%s]", left.Type(), index.Type())
+    }
+}
+
+// negative indices count back from the end of the array, so arr[-1] is the last element
+// anything outside of the array is an error rather than null, that way typos in indices don't go unnoticed
+func evalArrayIndexExpression(array object.Object, index object.Object) object.Object {
+    arrayObject := array.(*object.Array)
+    length := int64(len(arrayObject.Elements))
+
+    // a BigInt is always out of range
+    i, ok := index.(*object.Integer)
+    if !ok {
+        return newError(diagnostic.IndexOutOfRange, "index out of range: %s (array length %d)", index.Inspect(), length)
+    }
+
+    idx := i.Value
+    if idx < 0 {
+        idx += length
+    }
+
+    if idx < 0 || idx >= length {
+        return newError(diagnostic.IndexOutOfRange, "index out of range: %d (array length %d)", i.Value, length)
+    }
+
+    return arrayObject.Elements[idx]
+}
+
+// the value of an assignment is the value that was assigned, so a = b = 1 works
+func evalAssignExpression(c *Context, node *ast.AssignExpression, env *object.Environment) object.Object {
+    switch target := node.Target.(type) {
+    case *ast.Identifier:
+        var current object.Object
+        if node.Operator != "=" {
+            current = evalIdentifier(target, env)
+            if isError(current) {
+                return current
+            }
+        }
+
+        val := eval(c, node.Value, env)
+        if isError(val) {
+            return val
+        }
+
+        val = applyAssignOperator(node.Operator, current, val, c.Overflow)
+        if isError(val) {
+            return val
+        }
+
+        if !env.Assign(target.Value, val) {
+            return newError(diagnostic.UndeclaredAssignment, "assignment to undeclared variable: %s", target.Value)
+        }
+        return val
+
+    case *ast.IndexExpression:
+        left := eval(c, target.Left, env)
+        if isError(left) {
+            return left
+        }
+        index := eval(c, target.Index, env)
+        if isError(index) {
+            return index
+        }
+
+        var current object.Object
+        if node.Operator != "=" {
+            current = evalIndexExpression(left, index)
+            if isError(current) {
+                return current
+            }
+        }
+
+        val := eval(c, node.Value, env)
+        if isError(val) {
+            return val
+        }
+
+        val = applyAssignOperator(node.Operator, current, val, c.Overflow)
+        if isError(val) {
+            return val
+        }
+
+        return evalSetIndex(left, index, val)
+
+    default:
+        return newError(diagnostic.InvalidAssignTarget, "cannot assign to %s", node.Target.String())
+    }
+}
+
+// x += y is worked out as x + y, a plain = just gives back the new value
+func applyAssignOperator(operator string, current object.Object, val object.Object, overflow Overflow) object.Object {
+    if operator == "=" {
+        return val
+    }
+    return evalInfixExpression(strings.TrimSuffix(operator, "="), current, val, overflow)
+}
+
+// arrays and hashes are changed in place, so every variable holding the same array sees the change
+// an array can't grow this way (the index has to exist already), a hash gets a new key if it doesn't have it yet
+func evalSetIndex(left object.Object, index object.Object, val object.Object) object.Object {
+    switch left := left.(type) {
+    case *object.Array:
+        length := int64(len(left.Elements))
+        if _, isBig := index.(*object.BigInt); isBig {
+            return newError(diagnostic.IndexOutOfRange, "index out of range: %s (array length %d)", index.Inspect(), length)
+        }
+        i, ok := index.(*object.Integer)
+        if !ok {
+            return newError(diagnostic.IndexNotSupported, "index operator not supported: %s[%s]", left.Type(), index.Type())
+        }
+
+        idx := i.Value
+        if idx < 0 {
+            idx += length
+        }
+        if idx < 0 || idx >= length {
+            return newError(diagnostic.IndexOutOfRange, "index out of range: %d (array length %d)", i.Value, length)
+        }
+
+        left.Elements[idx] = val
+        return val
+
+    case *object.Hash:
+        key, ok := index.(object.Hashable)
+        if !ok {
+            return newError(diagnostic.UnhashableKey, "unusable as hash key: %s", index.Type())
+        }
+
+        left.Pairs[key.HashKey()] = object.HashPair{Key: index, Value: val}
+        return val
+
+    default:
+        return newError(diagnostic.IndexNotSupported, "index assignment not supported: %s[%s]", left.Type(), index.Type())
+    }
+}
+
+func evalHashLiteral(c *Context, node *ast.HashLiteral, env *object.Environment) object.Object {
+    pairs := make(map[object.HashKey]object.HashPair)
+
+    for _, pair := range node.Pairs {
+        key := valueOf(eval(c, pair.Key, env))
+        if isError(key) {
+            return key
+        }
+
+        hashKey, ok := key.(object.Hashable)
+        if !ok {
+            return newError(diagnostic.UnhashableKey, "unusable as hash key: %s", key.Type())
+        }
+
+        value := valueOf(eval(c, pair.Value, env))
+        if isError(value) {
+            return value
+        }
+
+        pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+    }
+
+    return &object.Hash{Pairs: pairs}
+}
+
+// unlike arrays, a missing key is not an error, it just gives back null
+func evalHashIndexExpression(hash object.Object, index object.Object) object.Object {
+    hashObject := hash.(*object.Hash)
+
+    key, ok := index.(object.Hashable)
+    if !ok {
+        return newError(diagnostic.UnhashableKey, "unusable as hash key: %s", index.Type())
+    }
+
+    pair, ok := hashObject.Pairs[key.HashKey()]
+    if !ok {
+        return NULL
+    }
+
+    return pair.Value
+}
+
+func evalIfExpression(c *Context, ie *ast.IfExpression, env *object.Environment) object.Object {
+    condition := eval(c, ie.Condition, env)
 
     if isError(condition) {
         return condition
     }
 
     if isTruthy(condition) {
-        return Eval(ie.Consequence, env)
+        return eval(c, ie.Consequence, env)
     } else if (ie.Alternative != nil) {
-        return Eval(ie.Alternative, env)
+        return eval(c, ie.Alternative, env)
     } else{
         return NULL
     }
@@ -199,11 +666,11 @@ func isTruthy(obj object.Object) bool {
 }
 
 // a more specialized fn to evaluate block statements
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+func evalProgram(c *Context, program *ast.Program, env *object.Environment) object.Object {
     var result object.Object
     
     for _, stmt := range program.Statements {
-        result = Eval(stmt, env)
+        result = eval(c, stmt, env)
 
         switch result := result.(type) {
         case *object.ReturnValue:
@@ -217,25 +684,123 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 }
 
 // so the inner most blocked return statement is returned, and the rest of the block is not evaluated
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func evalBlockStatement(c *Context, block *ast.BlockStatement, env *object.Environment) object.Object {
     var result object.Object
 
     for _, stmt := range block.Statements {
-        result = Eval(stmt, env)
+        result = eval(c, stmt, env)
 
         if result != nil{
             rt := result.Type()
-            if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+            if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ || rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
                 return result
             }
         }
 
     }
+    // a block is used for its value (a function's result or an if's), so one that ends without a value gives null
+    if result == nil {
+        return NULL
+    }
+    return result
+}
+
+// loops don't give back a value, so a loop that finishes normally (or is left with a break) results in null
+func evalWhileStatement(c *Context, node *ast.WhileStatement, env *object.Environment) object.Object {
+    for {
+        condition := eval(c, node.Condition, env)
+        if isError(condition) {
+            return condition
+        }
+        if !isTruthy(condition) {
+            return NULL
+        }
+
+        if result := evalLoopBody(c, node.Body, env); result != nil {
+            return unwrapBreak(result)
+        }
+    }
+}
+
+// the loop variable is bound in the surrounding environment (blocks don't get their own), so it's still around after the loop
+func evalForStatement(c *Context, node *ast.ForStatement, env *object.Environment) object.Object {
+    iterable := eval(c, node.Iterable, env)
+    if isError(iterable) {
+        return iterable
+    }
+
+    elements, err := iterableElements(iterable)
+    if err != nil {
+        return err
+    }
+
+    for _, element := range elements {
+        env.Set(node.Variable.Value, element)
+
+        if result := evalLoopBody(c, node.Body, env); result != nil {
+            return unwrapBreak(result)
+        }
+    }
+    return NULL
+}
+
+// runs one iteration, gives back nil if the loop should carry on
+// otherwise the break, return or error that stops it
+func evalLoopBody(c *Context, body *ast.BlockStatement, env *object.Environment) object.Object {
+    result := evalBlockStatement(c, body, env)
+    if result == nil {
+        return nil
+    }
+
+    switch result.Type() {
+    case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ:
+        return result
+    default:
+        // continue, or just the value of the last statement
+        return nil
+    }
+}
+
+// a break only stops the loop it's in, returns and errors keep going up
+func unwrapBreak(result object.Object) object.Object {
+    if result == BREAK {
+        return NULL
+    }
     return result
 }
 
-func newError(format string, a ...interface{}) *object.Error {
-    return &object.Error{Message: fmt.Sprintf(format, a...)}
+// what a for loop walks over: the elements of an array, the characters of a string, or the keys of a hash
+func iterableElements(obj object.Object) ([]object.Object, *object.Error) {
+    switch obj := obj.(type) {
+    case *object.Array:
+        return obj.Elements, nil
+    case *object.String:
+        elements := []object.Object{}
+        for _, r := range obj.Value {
+            elements = append(elements, &object.String{Value: string(r)})
+        }
+        return elements, nil
+    case *object.Hash:
+        elements := []object.Object{}
+        for _, pair := range obj.SortedPairs() {
+            elements = append(elements, pair.Key)
+        }
+        return elements, nil
+    default:
+        return nil, newError(diagnostic.NotIterable, "cannot iterate over %s", obj.Type())
+    }
+}
+
+func newError(code diagnostic.Code, format string, a ...interface{}) *object.Error {
+    return &object.Error{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+// something that gives back nothing (a Go nil) is null once it's used as a value, so it never ends up in a variable, an array, a hash or a builtin's arguments
+func valueOf(obj object.Object) object.Object {
+    if obj == nil {
+        return NULL
+    }
+    return obj
 }
 
 func isError(obj object.Object) bool {
@@ -246,19 +811,23 @@ func isError(obj object.Object) bool {
 }
 
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
-    val, ok := env.Get(node.Value)
-    if !ok {
-        return newError("identifier not found: " + node.Value)
+    if val, ok := env.Get(node.Value); ok {
+        return val
     }
-    return val
+
+    if builtin, ok := builtins[node.Value]; ok {
+        return builtin
+    }
+
+    return newError(diagnostic.UnknownIdentifier, "identifier not found: " + node.Value)
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+func evalExpressions(c *Context, exps []ast.Expression, env *object.Environment) []object.Object {
     // iterate over a list of ast.Expressions and evaluate them in the context of the current environment
     var result []object.Object
 
     for _, e := range exps {
-        evaluated := Eval(e, env)
+        evaluated := valueOf(eval(c, e, env))
         if isError(evaluated) {
             return []object.Object{evaluated}
         }
@@ -267,25 +836,114 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
     return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
-    function, ok := fn.(*object.Function)
-    if !ok {
-        return newError("not a function: %s", fn.Type())
+// callPos is where the call was made from, it ends up in the stack trace of any error that comes out of the function
+func applyFunction(c *Context, fn object.Object, args []object.Object, callPos token.Position) object.Object {
+    switch function := fn.(type) {
+    case *object.Function:
+        // the wrong number of arguments is the caller's mistake, so that error doesn't get a frame for the function
+        if err := checkArity(function, len(args)); err != nil {
+            return err
+        }
+
+        if err := c.enterCall(); err != nil {
+            return err
+        }
+        defer c.leaveCall()
+
+        extendedEnv, err := extendFunctionEnv(c, function, args)
+        if err != nil {
+            return addStackFrame(err, function, callPos)
+        }
+        evaluated := eval(c, function.Body, extendedEnv)
+        return addStackFrame(unwrapReturnValue(evaluated), function, callPos)
+    case *object.Builtin:
+        result := callBuiltin(c, function, args)
+        if err := c.allocate(result); err != nil {
+            return err
+        }
+        return result
+    default:
+        return newError(diagnostic.NotAFunction, "not a function: %s", fn.Type())
     }
+}
 
-    extendedEnv := extendFunctionEnv(function, args)
-    evaluated := Eval(function.Body, extendedEnv)
-    return unwrapReturnValue(evaluated)
+// errors unwind through every call they happen inside of, each one adds itself on the way out
+// so the stack ends up innermost first
+func addStackFrame(result object.Object, fn *object.Function, callPos token.Position) object.Object {
+    if err, ok := result.(*object.Error); ok {
+        err.Stack = append(err.Stack, object.StackFrame{Function: fn.Name, CallPos: callPos})
+    }
+    return result
+}
+
+func checkArity(fn *object.Function, got int) *object.Error {
+    required := requiredParameters(fn.Defaults, len(fn.Parameters))
+    max := len(fn.Parameters)
+    if fn.Rest != nil {
+        max = -1
+    }
+    if got < required || max >= 0 && got > max {
+        return arityError(fn.Name, got, required, max)
+    }
+    return nil
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// binds the arguments to the parameters in a new environment for the call, the arity has already been checked
+// the arguments that were passed are bound first (along with the rest parameter), then any missing ones get their default
+// a default is evaluated inside the call, so it can use the parameters before it
+func extendFunctionEnv(c *Context, fn *object.Function, args []object.Object) (*object.Environment, object.Object) {
     env := object.NewEnclosedEnvironment(fn.Env)
 
     for paramIdx, param := range fn.Parameters {
-        env.Set(param.Value, args[paramIdx])
+        if paramIdx < len(args) {
+            env.Set(param.Value, args[paramIdx])
+        }
+    }
+
+    if fn.Rest != nil {
+        rest := []object.Object{}
+        if len(args) > len(fn.Parameters) {
+            rest = append(rest, args[len(fn.Parameters):]...)
+        }
+        env.Set(fn.Rest.Value, &object.Array{Elements: rest})
+    }
+
+    for paramIdx := len(args); paramIdx < len(fn.Parameters); paramIdx++ {
+        val := eval(c, fn.Defaults[paramIdx], env)
+        if isError(val) {
+            return nil, val
+        }
+        env.Set(fn.Parameters[paramIdx].Value, val)
+    }
+
+    return env, nil
+}
+
+// the parameters without a default all come first
+func requiredParameters(defaults []ast.Expression, numParameters int) int {
+    for i := 0; i < numParameters; i++ {
+        if i < len(defaults) && defaults[i] != nil {
+            return i
+        }
+    }
+    return numParameters
+}
+
+// max is -1 when there's no limit (the function has a rest parameter)
+func arityError(name string, got int, required int, max int) *object.Error {
+    want := fmt.Sprintf("%d", required)
+    if max < 0 {
+        want = fmt.Sprintf("at least %d", required)
+    } else if max != required {
+        want = fmt.Sprintf("%d to %d", required, max)
+    }
+
+    function := "anonymous function"
+    if name != "" {
+        function = "`" + name + "`"
     }
 
-    return env
+    return newError(diagnostic.WrongArgumentCount, "wrong number of arguments to %s. got=%d, want=%s", function, got, want)
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
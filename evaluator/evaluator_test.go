@@ -1,10 +1,17 @@
 package evaluator
 
 import (
+    "context"
+    "math"
+    "os"
+    "skibidi/ast"
+    "skibidi/diagnostic"
     "skibidi/lexer"
     "skibidi/object"
     "skibidi/parser"
+    "strings"
     "testing"
+    "time"
 )
 
 // just making sure the input text of an integer literal evaluates to its corresponding value
@@ -28,22 +35,72 @@ func TestEvalIntegerExpression(t *testing.T) {
         {"3 * 3 * 3 + 10", 37},
         {"3 * (3 * 3) + 10", 37},
         {"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+        {"7 / 2", 3},
     }
 
     for _, tt := range tests {
-        evaluated := testEval(tt.input)
+        evaluated := testEval(t, tt.input)
         testIntegerObject(t, evaluated, tt.expected)
     }
 }
 
-func testEval(input string) object.Object {
+// other backends that have to give back exactly the same results as Eval, every test that goes through testEval checks them too
+// the vm registers itself from vm_backend_test.go, it can't be imported here since it imports this package
+var testBackends = map[string]func(program *ast.Program) object.Object{}
+
+func RegisterTestBackend(name string, run func(program *ast.Program) object.Object) {
+    testBackends[name] = run
+}
+
+func testEval(t *testing.T, input string) object.Object {
+    return testEvalFile(t, "", input)
+}
+
+func testEvalFile(t *testing.T, filename string, input string) object.Object {
+    t.Helper()
+
     // we define all new everything on every differnt call to testEval because we don't want bugs to persist between calls (for example bugs in the environment to persist)
-    l := lexer.New(input)
+    l := lexer.NewFile(filename, input)
     p := parser.New(l)
     program := p.ParseProgram()
     env := object.NewEnvironment()
 
-    return Eval(program, env)
+    evaluated := Eval(program, env)
+
+    for name, run := range testBackends {
+        other := run(parser.New(lexer.NewFile(filename, input)).ParseProgram())
+        if !sameResult(evaluated, other) {
+            t.Errorf("%s backend disagrees on %q, evaluator: %s, %s: %s", name, input, describeTraceback(evaluated), name, describeTraceback(other))
+        }
+    }
+
+    return evaluated
+}
+
+func sameResult(a object.Object, b object.Object) bool {
+    if a == nil || b == nil {
+        return a == b
+    }
+    // an error has to come out of the same calls too
+    if errA, ok := a.(*object.Error); ok {
+        errB, ok := b.(*object.Error)
+        return ok && errA.Traceback() == errB.Traceback() && errA.Code == errB.Code
+    }
+    return a.Type() == b.Type() && a.Inspect() == b.Inspect()
+}
+
+func describeTraceback(obj object.Object) string {
+    if err, ok := obj.(*object.Error); ok {
+        return err.Traceback()
+    }
+    return describe(obj)
+}
+
+func describe(obj object.Object) string {
+    if obj == nil {
+        return "nil"
+    }
+    return string(obj.Type()) + " " + obj.Inspect()
 }
 
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
@@ -63,6 +120,48 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 
 }
 
+// any integer mixed in with a float gets promoted, integers on their own stay integers
+func TestEvalFloatExpression(t *testing.T) {
+    tests := []struct {
+        input       string
+        expected    float64
+    }{
+        {"3.14", 3.14},
+        {".5", 0.5},
+        {"1e3", 1000},
+        {"2.5e-3", 0.0025},
+        {"-1.5", -1.5},
+        {"1 + 2.5", 3.5},
+        {"2.5 + 1", 3.5},
+        {"0.1 + 0.2", 0.30000000000000004},
+        {"5 - 0.5", 4.5},
+        {"1.5 * 2", 3},
+        {"7 / 2.0", 3.5},
+        {"-(1 + .5) * 2", -3},
+    }
+
+    for _, tt := range tests {
+        evaluated := testEval(t, tt.input)
+        testFloatObject(t, evaluated, tt.expected)
+    }
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+    result, ok := obj.(*object.Float)
+
+    if !ok {
+        t.Errorf("object is not Float, got: %T (%+v)", obj, obj)
+        return false
+    }
+
+    if result.Value != expected {
+        t.Errorf("object has wrong value, got: %g, wanted: %g", result.Value, expected)
+        return false
+    }
+
+    return true
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
     tests := []struct {
         input       string
@@ -86,14 +185,58 @@ func TestEvalBooleanExpression(t *testing.T) {
         {"(1 < 2) == false", false},
         {"(1 > 2) == true", false},
         {"(1 > 2) == false", true},
+        {"1 <= 2", true},
+        {"2 <= 2", true},
+        {"3 <= 2", false},
+        {"1 >= 2", false},
+        {"2 >= 2", true},
+        {"3 >= 2", true},
+        {"true && true", true},
+        {"true && false", false},
+        {"false || true", true},
+        {"false || false", false},
+        {"1 && 0", true},
+        {"1 <= 2 && 2 <= 3", true},
+        {"1 > 2 || 2 > 3", false},
+        {"1 == 1.0", true},
+        {"1.5 != 1.5", false},
+        {"1 < 1.5", true},
+        {"2.5 >= 3", false},
+        {"0.5 <= .5", true},
     }
 
     for _, tt := range tests {
-        evaluated := testEval(tt.input)
+        evaluated := testEval(t, tt.input)
         testBooleanObject(t, evaluated, tt.expected)
     }
 }
 
+// the right side of && and || must not run when the left side already decides the result
+// if it did, the error from the undefined name would come back instead of a boolean
+func TestLogicalShortCircuit(t *testing.T) {
+    tests := []struct {
+        input       string
+        expected    bool
+    }{
+        {"false && nope", false},
+        {"true || nope", true},
+        {"let f = fn() { false }; f() && nope()", false},
+        {"let n = 0; (1 > 2 && nope) || n == 0", true},
+    }
+
+    for _, tt := range tests {
+        evaluated := testEval(t, tt.input)
+        testBooleanObject(t, evaluated, tt.expected)
+    }
+
+    // and it does run when it has to
+    evaluated := testEval(t, "true && nope")
+    errObj, ok := evaluated.(*object.Error)
+    if !ok || errObj.Message != "identifier not found: nope" {
+        t.Errorf("expected the right side to be evaluated, got: %T (%+v)", evaluated, evaluated)
+    }
+}
+
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
     result, ok := obj.(*object.Boolean)
     
@@ -125,7 +268,7 @@ func TestBangOperator(t *testing.T) {
     }
 
     for _, tt := range tests {
-        evaluated := testEval(tt.input)
+        evaluated := testEval(t, tt.input)
         testBooleanObject(t, evaluated, tt.expected)
     }
 }
@@ -146,7 +289,7 @@ func TestIfElseExpressions(t *testing.T) {
     }
 
     for _, tt := range tests {
-        evaluated := testEval(tt.input)
+        evaluated := testEval(t, tt.input)
         integer, ok := tt.expected.(int)
         if ok {
             testIntegerObject(t, evaluated, int64(integer))
@@ -178,7 +321,7 @@ func TestReturnStatements(t *testing.T) {
     }
 
     for _, tt := range tests {
-        evaluated := testEval(tt.input)
+        evaluated := testEval(t, tt.input)
         testIntegerObject(t, evaluated, tt.expected)
     }
 }
@@ -221,10 +364,26 @@ func TestErrorHandling(t *testing.T) {
             "foobar",
             "identifier not found: foobar",
         },
+        {
+            `"Hello" - "World"`,
+            "unknown operator: STRING - STRING",
+        },
+        {
+            `"Hello" + 1`,
+            "type mismatch: STRING + INTEGER",
+        },
+        {
+            `{"name": "skibidi"}[fn(x) { x }];`,
+            "unusable as hash key: FUNCTION",
+        },
+        {
+            `{fn(x) { x }: 1};`,
+            "unusable as hash key: FUNCTION",
+        },
     }
 
     for _, tt := range tests {
-        evaluated := testEval(tt.input)
+        evaluated := testEval(t, tt.input)
 
         errObj, ok := evaluated.(*object.Error)
         if !ok {
@@ -251,7 +410,7 @@ func TestLetStatements(t *testing.T) {
     }
 
     for _, tt := range tests {
-        testIntegerObject(t, testEval(tt.input), tt.expected)
+        testIntegerObject(t, testEval(t, tt.input), tt.expected)
     }
 }
 
@@ -259,7 +418,7 @@ func TestLetStatements(t *testing.T) {
 func TestFunctionObject(t *testing.T) {
     input := "fn(x) { x + 2; };"
 
-    evaluated := testEval(input)
+    evaluated := testEval(t, input)
     fn, ok := evaluated.(*object.Function)
     if !ok {
         t.Fatalf("Expected object to be a function, got: %T (%+v)", evaluated, evaluated)
@@ -294,7 +453,906 @@ func TestFunctionApplication(t *testing.T) {
     }
 
     for _, tt := range tests {
-        testIntegerObject(t, testEval(tt.input), tt.expected)
+        testIntegerObject(t, testEval(t, tt.input), tt.expected)
+    }
+}
+
+func TestFunctionArity(t *testing.T) {
+    tests := []struct {
+        input           string
+        expectedMessage string
+    }{
+        {"let add = fn(a, b) { a + b }; add(1);", "wrong number of arguments to `add`. got=1, want=2"},
+        {"let add = fn(a, b) { a + b }; add(1, 2, 3);", "wrong number of arguments to `add`. got=3, want=2"},
+        {"fn(x) { x }();", "wrong number of arguments to anonymous function. got=0, want=1"},
+        {"let f = fn(a, b = 2) { a }; f();", "wrong number of arguments to `f`. got=0, want=1 to 2"},
+        {"let f = fn(a, b = 2) { a }; f(1, 2, 3);", "wrong number of arguments to `f`. got=3, want=1 to 2"},
+        {"let f = fn(a, ...rest) { a }; f();", "wrong number of arguments to `f`. got=0, want=at least 1"},
+    }
+
+    for _, tt := range tests {
+        evaluated := testEval(t, tt.input)
+        errObj, ok := evaluated.(*object.Error)
+        if !ok {
+            t.Errorf("no error object returned for %q, got: %T (%+v)", tt.input, evaluated, evaluated)
+            continue
+        }
+
+        if errObj.Message != tt.expectedMessage {
+            t.Errorf("wrong error message for %q, want: %q, got: %q", tt.input, tt.expectedMessage, errObj.Message)
+        }
+    }
+}
+
+func TestDefaultAndRestParameters(t *testing.T) {
+    tests := []struct {
+        input       string
+        expected    interface{}
+    }{
+        {"let f = fn(a, b = 2) { a + b }; f(1);", 3},
+        {"let f = fn(a, b = 2) { a + b }; f(1, 5);", 6},
+        // a default can use the parameters before it
+        {"let f = fn(a, b = a * 10) { a + b }; f(2);", 22},
+        {"let f = fn(a = 1, b = a + 1) { [a, b] }; f();", []int64{1, 2}},
+        {"let f = fn(a = 1, b = a + 1) { [a, b] }; f(5);", []int64{5, 6}},
+        // defaults are worked out on every call, not once
+        {"let n = 0; let f = fn(a = n) { a }; n = 4; f();", 4},
+        {"let f = fn(...rest) { rest }; f();", []int64{}},
+        {"let f = fn(...rest) { rest }; f(1, 2, 3);", []int64{1, 2, 3}},
+        {"let f = fn(a, ...rest) { len(rest) }; f(1);", 0},
+        {"let f = fn(a, b = 2, ...rest) { [a, b, len(rest)] }; f(1);", []int64{1, 2, 0}},
+        {"let f = fn(a, b = 2, ...rest) { rest }; f(1, 2, 3, 4);", []int64{3, 4}},
+        // changing the rest array doesn't touch anything the caller has
+        {"let f = fn(...rest) { rest[0] = 9; rest[0] }; f(1);", 9},
+        {"let f = fn(a, b = undefined) { a }; f(1);", "identifier not found: undefined"},
+    }
+
+    for _, tt := range tests {
+        evaluated := testEval(t, tt.input)
+        switch expected := tt.expected.(type) {
+        case int:
+            testIntegerObject(t, evaluated, int64(expected))
+        case string:
+            errObj, ok := evaluated.(*object.Error)
+            if !ok {
+                t.Errorf("no error object returned for %q, got: %T (%+v)", tt.input, evaluated, evaluated)
+                continue
+            }
+            if errObj.Message != expected {
+                t.Errorf("wrong error message for %q, want: %q, got: %q", tt.input, expected, errObj.Message)
+            }
+        case []int64:
+            array, ok := evaluated.(*object.Array)
+            if !ok {
+                t.Errorf("object is not Array for %q, got: %T (%+v)", tt.input, evaluated, evaluated)
+                continue
+            }
+            if len(array.Elements) != len(expected) {
+                t.Errorf("wrong number of elements for %q, want: %d, got: %d", tt.input, len(expected), len(array.Elements))
+                continue
+            }
+            for i, want := range expected {
+                testIntegerObject(t, array.Elements[i], want)
+            }
+        }
+    }
+}
+
+func TestFunctionInspect(t *testing.T) {
+    evaluated := testEval(t, "fn(a, b = 2, ...rest) { a }")
+    expected := "fn(a, b = 2, ...rest) {\na\n}"
+    if evaluated.Inspect() != expected {
+        t.Errorf("wrong Inspect, want: %q, got: %q", expected, evaluated.Inspect())
+    }
+}
+
+
+func TestStringLiteral(t *testing.T) {
+    input := `"Hello World!"`
+
+    evaluated := testEval(t, input)
+    str, ok := evaluated.(*object.String)
+    if !ok {
+        t.Fatalf("object is not String, got: %T (%+v)", evaluated, evaluated)
+    }
+
+    if str.Value != "Hello World!" {
+        t.Errorf("String has wrong value, got: %q", str.Value)
+    }
+}
+
+func TestStringConcatenation(t *testing.T) {
+    input := `"Hello" + " " + "World!\n"`
+
+    evaluated := testEval(t, input)
+    str, ok := evaluated.(*object.String)
+    if !ok {
+        t.Fatalf("object is not String, got: %T (%+v)", evaluated, evaluated)
+    }
+
+    if str.Value != "Hello World!\n" {
+        t.Errorf("String has wrong value, got: %q", str.Value)
+    }
+}
+
+func TestStringComparison(t *testing.T) {
+    tests := []struct {
+        input       string
+        expected    bool
+    }{
+        {`"a" == "a"`, true},
+        {`"a" == "b"`, false},
+        {`"a" != "b"`, true},
+        {`"a" != "a"`, false},
+        {`"a" + "b" == "ab"`, true},
+    }
+
+    for _, tt := range tests {
+        testBooleanObject(t, testEval(t, tt.input), tt.expected)
+    }
+}
+
+func TestArrayLiterals(t *testing.T) {
+    input := "[1, 2 * 2, 3 + 3]"
+
+    evaluated := testEval(t, input)
+    result, ok := evaluated.(*object.Array)
+    if !ok {
+        t.Fatalf("object is not Array, got: %T (%+v)", evaluated, evaluated)
+    }
+
+    if len(result.Elements) != 3 {
+        t.Fatalf("array has wrong number of elements, got: %d", len(result.Elements))
+    }
+
+    testIntegerObject(t, result.Elements[0], 1)
+    testIntegerObject(t, result.Elements[1], 4)
+    testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+    tests := []struct {
+        input       string
+        expected    interface{}
+    }{
+        {"[1, 2, 3][0]", 1},
+        {"[1, 2, 3][1]", 2},
+        {"[1, 2, 3][2]", 3},
+        {"let i = 0; [1][i];", 1},
+        {"[1, 2, 3][1 + 1];", 3},
+        {"let myArray = [1, 2, 3]; myArray[2];", 3},
+        {"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+        {"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]", 2},
+        {"[1, 2, 3][-1]", 3},
+        {"[1, 2, 3][-3]", 1},
+        {"[[1, 2], [3, 4]][1][0]", 3},
+        {"[1, 2, 3][3]", "index out of range: 3 (array length 3)"},
+        {"[1, 2, 3][-4]", "index out of range: -4 (array length 3)"},
+        {"[][0]", "index out of range: 0 (array length 0)"},
+        {`[1, 2, 3]["a"]`, "index operator not supported: ARRAY[STRING]"},
+        {"1[0]", "index operator not supported: INTEGER[INTEGER]"},
+    }
+
+    for _, tt := range tests {
+        evaluated := testEval(t, tt.input)
+        switch expected := tt.expected.(type) {
+        case int:
+            testIntegerObject(t, evaluated, int64(expected))
+        case string:
+            errObj, ok := evaluated.(*object.Error)
+            if !ok {
+                t.Errorf("expected error object, got: %T(%+v)", evaluated, evaluated)
+                continue
+            }
+            if errObj.Message != expected {
+                t.Errorf("wrong error message, expected %q, got %q", expected, errObj.Message)
+            }
+        }
+    }
+}
+
+func TestHashLiterals(t *testing.T) {
+    input := `let two = "two";
+    {
+        "one": 10 - 9,
+        two: 1 + 1,
+        "thr" + "ee": 6 / 2,
+        4: 4,
+        true: 5,
+        false: 6
+    }`
+
+    evaluated := testEval(t, input)
+    result, ok := evaluated.(*object.Hash)
+    if !ok {
+        t.Fatalf("Eval didn't return Hash, got: %T (%+v)", evaluated, evaluated)
+    }
+
+    expected := map[object.HashKey]int64{
+        (&object.String{Value: "one"}).HashKey():   1,
+        (&object.String{Value: "two"}).HashKey():   2,
+        (&object.String{Value: "three"}).HashKey(): 3,
+        (&object.Integer{Value: 4}).HashKey():      4,
+        TRUE.HashKey():                             5,
+        FALSE.HashKey():                            6,
+    }
+
+    if len(result.Pairs) != len(expected) {
+        t.Fatalf("Hash has wrong number of pairs, got: %d", len(result.Pairs))
+    }
+
+    for expectedKey, expectedValue := range expected {
+        pair, ok := result.Pairs[expectedKey]
+        if !ok {
+            t.Errorf("no pair for given key in Pairs")
+        }
+
+        testIntegerObject(t, pair.Value, expectedValue)
+    }
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+    tests := []struct {
+        input       string
+        expected    interface{}
+    }{
+        {`{"foo": 5}["foo"]`, 5},
+        {`{"foo": 5}["bar"]`, nil},
+        {`let key = "foo"; {"foo": 5}[key]`, 5},
+        {`{}["foo"]`, nil},
+        {`{5: 5}[5]`, 5},
+        {`{true: 5}[true]`, 5},
+        {`{false: 5}[false]`, 5},
+        {`{1: 5}[true]`, nil},
+    }
+
+    for _, tt := range tests {
+        evaluated := testEval(t, tt.input)
+        integer, ok := tt.expected.(int)
+        if ok {
+            testIntegerObject(t, evaluated, int64(integer))
+        } else {
+            testNullObject(t, evaluated)
+        }
+    }
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+    tests := []struct {
+        input       string
+        expected    interface{}
+    }{
+        {`len("")`, 0},
+        {`len("four")`, 4},
+        {`len("hello world")`, 11},
+        {`len("héllo")`, 5},
+        {`len("\u{1F480}!")`, 2},
+        {`let n = 0; for (c in "héllo") { n += 1 }; [n, len("héllo")]`, []int{5, 5}},
+        {`len([1, 2, 3])`, 3},
+        {`len([])`, 0},
+        {`len({"a": 1, "b": 2})`, 2},
+        {`len(1)`, "argument to `len` not supported, got INTEGER"},
+        {`len("one", "two")`, "wrong number of arguments to `len`. got=2, want=1"},
+        {`first([1, 2, 3])`, 1},
+        {`first([])`, nil},
+        {`first(1)`, "argument to `first` not supported, got INTEGER"},
+        {`last([1, 2, 3])`, 3},
+        {`last([])`, nil},
+        {`last(1)`, "argument to `last` not supported, got INTEGER"},
+        {`rest([1, 2, 3])`, []int{2, 3}},
+        {`rest([1])`, []int{}},
+        {`rest([])`, nil},
+        {`push([], 1)`, []int{1}},
+        {`let a = [1]; push(a, 2); a`, []int{1}},
+        {`push(1, 1)`, "argument to `push` not supported, got INTEGER"},
+        {`push([1])`, "wrong number of arguments to `push`. got=1, want=2"},
+        {`puts("hello", 1)`, nil},
+        {`type(1)`, "INTEGER"},
+        {`type("a")`, "STRING"},
+        {`type([])`, "ARRAY"},
+        {`type(len)`, "BUILTIN"},
+        {`type()`, "wrong number of arguments to `type`. got=0, want=1"},
+        {`str(12)`, "12"},
+        {`str("12")`, "12"},
+        {`str([1, "a"])`, "[1, a]"},
+        {`int("42")`, 42},
+        {`int(" -7 ")`, -7},
+        {`int(true)`, 1},
+        {`int(false)`, 0},
+        {`int(5)`, 5},
+        {`int("abc")`, `could not parse "abc" as integer`},
+        {`int([])`, "argument to `int` not supported, got ARRAY"},
+        {`int(2.7)`, 2},
+        {`int(-2.7)`, -2},
+        {`float(2)`, 2.0},
+        {`float(2.5)`, 2.5},
+        {`float(" 1e-3 ")`, 0.001},
+        {`float("abc")`, `could not parse "abc" as float`},
+        {`float("inf")`, `could not parse "inf" as float`},
+        {`float("NaN")`, `could not parse "NaN" as float`},
+        {`float("1e400")`, `could not parse "1e400" as float`},
+        {`float(` + strings.Repeat("9", 400) + `)`, strings.Repeat("9", 400) + " is too big for a float"},
+        {`float(true)`, "argument to `float` not supported, got BOOLEAN"},
+        {`type(1.5)`, "FLOAT"},
+        {`str(2.0)`, "2.0"},
+        {`let len = fn(x) { 42 }; len("a")`, 42},
+        // a function with an empty body gives back null, wherever its result ends up
+        {`let f = fn() {}; puts(f())`, nil},
+        {`let f = fn() {}; len(f())`, "argument to `len` not supported, got NULL"},
+        {`let f = fn() {}; str(f())`, "null"},
+        {`let f = fn() {}; type(f())`, "NULL"},
+        {`let f = fn() { let x = 1; }; type([f()][0])`, "NULL"},
+        {`let f = fn() {}; type({"a": f()}["a"])`, "NULL"},
+        {`let f = fn() {}; str([f(), {"a": f()}])`, "[null, {a: null}]"},
+    }
+
+    for _, tt := range tests {
+        evaluated := testEval(t, tt.input)
+
+        switch expected := tt.expected.(type) {
+        case int:
+            testIntegerObject(t, evaluated, int64(expected))
+        case float64:
+            testFloatObject(t, evaluated, expected)
+        case nil:
+            testNullObject(t, evaluated)
+        case string:
+            switch obj := evaluated.(type) {
+            case *object.Error:
+                if obj.Message != expected {
+                    t.Errorf("wrong error message, expected %q, got %q", expected, obj.Message)
+                }
+            case *object.String:
+                if obj.Value != expected {
+                    t.Errorf("wrong string value, expected %q, got %q", expected, obj.Value)
+                }
+            default:
+                t.Errorf("object is not Error or String, got: %T (%+v)", evaluated, evaluated)
+            }
+        case []int:
+            array, ok := evaluated.(*object.Array)
+            if !ok {
+                t.Errorf("object is not Array, got: %T (%+v)", evaluated, evaluated)
+                continue
+            }
+
+            if len(array.Elements) != len(expected) {
+                t.Errorf("wrong number of elements, want: %d, got: %d", len(expected), len(array.Elements))
+                continue
+            }
+
+            for i, expectedElem := range expected {
+                testIntegerObject(t, array.Elements[i], int64(expectedElem))
+            }
+        }
     }
 }
 
+// index assignment changes arrays and hashes in place, so one can end up holding itself
+func TestCyclicValues(t *testing.T) {
+    tests := []struct {
+        input       string
+        expected    string
+    }{
+        {"let a = [1]; a[0] = a; str(a)", "[[...]]"},
+        {"let h = {}; h[\"self\"] = h; str(h)", "{self: {...}}"},
+        {"let a = [1]; let h = {\"a\": a}; a[0] = h; str(a)", "[{a: [...]}]"},
+    }
+
+    for _, tt := range tests {
+        evaluated := testEval(t, tt.input)
+        str, ok := evaluated.(*object.String)
+        if !ok {
+            t.Errorf("object is not String, got: %T (%+v)", evaluated, evaluated)
+            continue
+        }
+        if str.Value != tt.expected {
+            t.Errorf("wrong string for %q, expected %q, got %q", tt.input, tt.expected, str.Value)
+        }
+    }
+}
+
+func TestPutsWritesToStdout(t *testing.T) {
+    var out strings.Builder
+    c := NewContext(context.Background(), DefaultLimits)
+    c.Stdout = &out
+
+    program := parser.New(lexer.New(`puts(1, "a"); let f = fn(x) { puts([x]) }; f(2.5)`)).ParseProgram()
+    evaluated := EvalContext(c, program, object.NewEnvironment())
+    if evaluated != NULL {
+        t.Errorf("puts should give back null, got %s", evaluated.Inspect())
+    }
+    if out.String() != "1\na\n[2.5]\n" {
+        t.Errorf("wrong output, want: %q, got: %q", "1\na\n[2.5]\n", out.String())
+    }
+
+    if c := NewContext(context.Background(), DefaultLimits); c.Stdout != os.Stdout {
+        t.Errorf("expected a new Context to write to os.Stdout")
+    }
+}
+
+func TestLoops(t *testing.T) {
+    tests := []struct {
+        input       string
+        expected    interface{}
+    }{
+        {"let count = fn(n) { let i = 0; let total = 0; while (i < n) { let total = total + i; let i = i + 1; } total }; count(5)", 10},
+        {"let i = 0; while (i < 10) { let i = i + 1; if (i == 3) { break; } } i", 3},
+        {"let i = 0; let odd = 0; while (i < 10) { let i = i + 1; if (i / 2 * 2 == i) { continue } let odd = odd + 1; } odd", 5},
+        {"let total = 0; for (x in [1, 2, 3]) { let total = total + x; } total", 6},
+        {"let s = \"\"; for (c in \"abc\") { let s = c + s; } s", "cba"},
+        {"let out = []; for (k in {\"b\": 2, \"a\": 1}) { let out = push(out, k); } out", []string{"a", "b"}},
+        {"let last = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { break } let last = x; } last", 2},
+        {"for (x in [1, 2, 3]) { } x", 3},
+        // break only leaves the innermost loop
+        {"let n = 0; for (a in [1, 2, 3]) { for (b in [1, 2, 3]) { if (b == 2) { break } let n = n + 1; } } n", 3},
+        // a return inside a loop leaves the whole function
+        {"let find = fn(arr, want) { for (x in arr) { if (x == want) { return true; } } false }; find([1, 2, 3], 2)", true},
+        {"let find = fn(arr, want) { for (x in arr) { if (x == want) { return true; } } false }; find([1, 2, 3], 5)", false},
+        // a loop on its own results in null
+        {"while (false) { 1 }", nil},
+        {"for (x in [1, 2]) { if (x == 1) { break } }", nil},
+        {"let f = fn() { for (x in [1]) { x } }; f()", nil},
+        {"let out = []; for (k in {10: 0, 9: 0, -1: 0, 100000000000000000000: 0}) { let out = push(out, k); } out", []string{"-1", "9", "10", "100000000000000000000"}},
+        {"for (x in 5) { }", "cannot iterate over INTEGER"},
+        {"let i = 0; while (i < 3) { let i = i + 1; i + true; }", "type mismatch: INTEGER + BOOLEAN"},
+    }
+
+    for _, tt := range tests {
+        evaluated := testEval(t, tt.input)
+
+        switch expected := tt.expected.(type) {
+        case int:
+            testIntegerObject(t, evaluated, int64(expected))
+        case bool:
+            testBooleanObject(t, evaluated, expected)
+        case nil:
+            testNullObject(t, evaluated)
+        case string:
+            switch obj := evaluated.(type) {
+            case *object.Error:
+                if obj.Message != expected {
+                    t.Errorf("wrong error message, expected %q, got %q", expected, obj.Message)
+                }
+            case *object.String:
+                if obj.Value != expected {
+                    t.Errorf("wrong string value, expected %q, got %q", expected, obj.Value)
+                }
+            default:
+                t.Errorf("object is not Error or String, got: %T (%+v)", evaluated, evaluated)
+            }
+        case []string:
+            if evaluated == nil || evaluated.Inspect() != "["+strings.Join(expected, ", ")+"]" {
+                t.Errorf("wrong array, expected %v, got: %T (%+v)", expected, evaluated, evaluated)
+            }
+        }
+    }
+}
+
+func TestAssignment(t *testing.T) {
+    tests := []struct {
+        input       string
+        expected    interface{}
+    }{
+        {"let x = 1; x = 2; x", 2},
+        {"let x = 1; x = x + 1", 2},
+        {"let a = 1; let b = 2; a = b = 5; a + b", 10},
+        {"let x = 10; x += 5; x", 15},
+        {"let x = 10; x -= 5; x", 5},
+        {"let x = 10; x *= 5; x", 50},
+        {"let x = 10; x /= 5; x", 2},
+        {"let x = 1; x += 0.5; x", 1.5},
+        {`let s = "a"; s += "b"; s`, "ab"},
+        // a closure updating a variable it captured
+        {"let counter = fn() { let n = 0; fn() { n += 1 } }; let c = counter(); c(); c(); c()", 3},
+        {"let total = 0; let add = fn(x) { total = total + x; }; add(2); add(3); total", 5},
+        // the binding is found where it was made, the function doesn't get its own
+        {"let x = 1; let f = fn() { x = 2; let x = 3; x }; f() + x", 5},
+        {"let i = 0; let sum = 0; while (i < 5) { i += 1; sum += i; } sum", 15},
+        {"let arr = [1, 2, 3]; arr[0] = 10; arr[-1] *= 2; arr", "[10, 2, 6]"},
+        {"let a = [1, 2]; let b = a; b[0] = 5; a", "[5, 2]"},
+        {`let h = {"a": 1}; h["a"] += 1; h["b"] = 3; h`, "{a: 2, b: 3}"},
+        {"let arr = [0, 0]; let i = 0; arr[i] = i = 1; arr", "[1, 0]"},
+        {"y = 5", "assignment to undeclared variable: y"},
+        {"len = 5", "assignment to undeclared variable: len"},
+        {"y += 5", "identifier not found: y"},
+        {"let f = fn() { z = 1 }; f()", "assignment to undeclared variable: z"},
+        {"let x = true; x += 1", "type mismatch: BOOLEAN + INTEGER"},
+        {"let arr = [1]; arr[1] = 2", "index out of range: 1 (array length 1)"},
+        {`let arr = [1]; arr["a"] = 2`, "index operator not supported: ARRAY[STRING]"},
+        {"let h = {}; h[[]] = 1", "unusable as hash key: ARRAY"},
+        {`let s = "abc"; s[0] = "x"`, "index assignment not supported: STRING[INTEGER]"},
+    }
+
+    for _, tt := range tests {
+        evaluated := testEval(t, tt.input)
+
+        switch expected := tt.expected.(type) {
+        case int:
+            testIntegerObject(t, evaluated, int64(expected))
+        case float64:
+            testFloatObject(t, evaluated, expected)
+        case string:
+            switch obj := evaluated.(type) {
+            case *object.Error:
+                if obj.Message != expected {
+                    t.Errorf("wrong error message for %q, expected %q, got %q", tt.input, expected, obj.Message)
+                }
+            default:
+                if evaluated == nil || evaluated.Inspect() != expected {
+                    t.Errorf("wrong result for %q, expected %q, got: %T (%+v)", tt.input, expected, evaluated, evaluated)
+                }
+            }
+        }
+    }
+}
+
+// deep enough that doing the same thing with recursion would be a problem
+func TestLongLoop(t *testing.T) {
+    evaluated := testEval(t, "let i = 0; while (i < 100000) { let i = i + 1; } i")
+    testIntegerObject(t, evaluated, 100000)
+}
+
+func TestErrorPositions(t *testing.T) {
+    tests := []struct {
+        input       string
+        expected    string
+    }{
+        {"5 + true;", "ERROR: test.skb:1:3: type mismatch: INTEGER + BOOLEAN"},
+        {"let x = 1;\n  foobar", "ERROR: test.skb:2:3: identifier not found: foobar"},
+        {"let f = fn() {\n  -true\n};\nf()", "ERROR: test.skb:2:3: unknown operator: -BOOLEAN"},
+        {"[1, 2][5]", "ERROR: test.skb:1:7: index out of range: 5 (array length 2)"},
+        {"len(1, 2)", "ERROR: test.skb:1:1: wrong number of arguments to `len`. got=2, want=1"},
+        {"let x = 1;\nmissing = x", "ERROR: test.skb:2:9: assignment to undeclared variable: missing"},
+        {"let x = 1;\nx += true", "ERROR: test.skb:2:3: type mismatch: INTEGER + BOOLEAN"},
+    }
+
+    for _, tt := range tests {
+        evaluated := testEvalFile(t, "test.skb", tt.input)
+
+        errObj, ok := evaluated.(*object.Error)
+        if !ok {
+            t.Errorf("expected error object, got: %T(%+v)", evaluated, evaluated)
+            continue
+        }
+
+        if errObj.Inspect() != tt.expected {
+            t.Errorf("wrong error, expected %q, got %q", tt.expected, errObj.Inspect())
+        }
+    }
+}
+
+func TestStackTrace(t *testing.T) {
+    tests := []struct {
+        input       string
+        expected    string
+    }{
+        {
+            "let inner = fn(x) {\n  x + missing\n};\nlet outer = fn() { inner(1) };\nouter()",
+            "ERROR: test.skb:2:7: identifier not found: missing\n" +
+                "stack trace (most recent call first):\n" +
+                "    in inner, called at test.skb:4:25\n" +
+                "    in outer, called at test.skb:5:6",
+        },
+        {
+            "let apply = fn(f) { f() };\napply(fn() { -true })",
+            "ERROR: test.skb:2:14: unknown operator: -BOOLEAN\n" +
+                "stack trace (most recent call first):\n" +
+                "    in anonymous function, called at test.skb:1:22\n" +
+                "    in apply, called at test.skb:2:6",
+        },
+        // the wrong number of arguments is reported from the caller, not from inside the function
+        {
+            "let f = fn(a) { a };\nlet g = fn() { f() };\ng()",
+            "ERROR: test.skb:2:16: wrong number of arguments to `f`. got=0, want=1\n" +
+                "stack trace (most recent call first):\n" +
+                "    in g, called at test.skb:3:2",
+        },
+        // a default value is worked out inside the call
+        {
+            "let f = fn(a = missing) { a };\nf()",
+            "ERROR: test.skb:1:16: identifier not found: missing\n" +
+                "stack trace (most recent call first):\n" +
+                "    in f, called at test.skb:2:2",
+        },
+        {
+            "let count = fn(n) { if (n == 0) { missing } else { count(n - 1) } };\ncount(3)",
+            "ERROR: test.skb:1:35: identifier not found: missing\n" +
+                "stack trace (most recent call first):\n" +
+                "    in count, called at test.skb:1:57\n" +
+                "    [the line above is repeated 2 more times]\n" +
+                "    in count, called at test.skb:2:6",
+        },
+        {"5 + true", "ERROR: test.skb:1:3: type mismatch: INTEGER + BOOLEAN"},
+    }
+
+    for _, tt := range tests {
+        evaluated := testEvalFile(t, "test.skb", tt.input)
+
+        errObj, ok := evaluated.(*object.Error)
+        if !ok {
+            t.Errorf("expected error object, got: %T(%+v)", evaluated, evaluated)
+            continue
+        }
+
+        if errObj.Traceback() != tt.expected {
+            t.Errorf("wrong traceback for %q, expected:\n%s\ngot:\n%s", tt.input, tt.expected, errObj.Traceback())
+        }
+    }
+}
+
+func TestErrorDiagnostic(t *testing.T) {
+    tests := []struct {
+        input       string
+        code        diagnostic.Code
+    }{
+        {"missing", diagnostic.UnknownIdentifier},
+        {"1 + true", diagnostic.TypeMismatch},
+        {"-true", diagnostic.UnknownOperator},
+        {"[1][5]", diagnostic.IndexOutOfRange},
+        {"1[0]", diagnostic.IndexNotSupported},
+        {"{[1]: 2}", diagnostic.UnhashableKey},
+        {"x = 1", diagnostic.UndeclaredAssignment},
+        {"1()", diagnostic.NotAFunction},
+        {"len()", diagnostic.WrongArgumentCount},
+        {"fn(a) { a }()", diagnostic.WrongArgumentCount},
+        {"len(1)", diagnostic.InvalidArgument},
+        {"for (x in 1) { x }", diagnostic.NotIterable},
+    }
+
+    for _, tt := range tests {
+        errObj, ok := testEval(t, tt.input).(*object.Error)
+        if !ok {
+            t.Errorf("expected error object for %q", tt.input)
+            continue
+        }
+        if errObj.Diagnostic().Code != tt.code {
+            t.Errorf("wrong code for %q, want: %s, got: %s", tt.input, tt.code, errObj.Diagnostic().Code)
+        }
+    }
+
+    // the calls the error came out of become notes
+    errObj := testEvalFile(t, "test.skb", "let f = fn() { missing };\nf()").(*object.Error)
+    d := errObj.Diagnostic()
+    if d.Error() != "test.skb:1:16: identifier not found: missing" {
+        t.Errorf("wrong diagnostic, got: %q", d.Error())
+    }
+    if len(d.Notes) != 1 || d.Notes[0].Message != "called f from here" || d.Notes[0].Span.Start.String() != "test.skb:2:2" {
+        t.Errorf("wrong notes, got: %+v", d.Notes)
+    }
+}
+
+func TestCallDepthLimit(t *testing.T) {
+    evaluated := testEvalFile(t, "test.skb", "let f = fn(x) { f(x) };\nf(1)")
+
+    errObj, ok := evaluated.(*object.Error)
+    if !ok {
+        t.Fatalf("expected error object, got: %T(%+v)", evaluated, evaluated)
+    }
+    if errObj.Kind != object.LimitError || errObj.Code != diagnostic.CallDepthExceeded {
+        t.Errorf("wrong error, got: %s (%s)", errObj.Inspect(), errObj.Code)
+    }
+    if errObj.Message != "maximum call depth exceeded (10000 calls)" {
+        t.Errorf("wrong message, got: %q", errObj.Message)
+    }
+    // ten thousand calls to f collapse into a couple of notes
+    if notes := errObj.Diagnostic().Notes; len(notes) != 3 {
+        t.Errorf("expected 3 notes, got %d", len(notes))
+    }
+}
+
+func testEvalLimited(ctx context.Context, limits Limits, input string) object.Object {
+    program := parser.New(lexer.New(input)).ParseProgram()
+    return EvalContext(NewContext(ctx, limits), program, object.NewEnvironment())
+}
+
+func TestLimits(t *testing.T) {
+    timeout, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+    defer cancel()
+    cancelled, cancelNow := context.WithCancel(context.Background())
+    cancelNow()
+
+    tests := []struct {
+        ctx         context.Context
+        limits      Limits
+        input       string
+        code        diagnostic.Code // empty when the program should finish
+    }{
+        {context.Background(), Limits{MaxDepth: 3}, "let f = fn(n) { if (n > 0) { f(n - 1) } }; f(2)", ""},
+        {context.Background(), Limits{MaxDepth: 3}, "let f = fn(n) { if (n > 0) { f(n - 1) } }; f(3)", diagnostic.CallDepthExceeded},
+        {context.Background(), Limits{MaxSteps: 1000}, "let i = 0; while (i < 10) { i += 1 }", ""},
+        {context.Background(), Limits{MaxSteps: 1000}, "while (true) {}", diagnostic.StepLimitExceeded},
+        {context.Background(), Limits{MaxAllocation: 1000}, "let s = \"\"; for (i in [1, 2, 3]) { s += \"ab\" } s", ""},
+        {context.Background(), Limits{MaxAllocation: 1000}, "let s = \"ab\"; while (true) { s += s }", diagnostic.AllocationLimitExceeded},
+        {context.Background(), Limits{MaxAllocation: 10000}, "let a = []; while (true) { a = push(a, 1) }", diagnostic.AllocationLimitExceeded},
+        {timeout, Limits{}, "while (true) {}", diagnostic.Cancelled},
+        {cancelled, Limits{}, "let f = fn() { f() }; f()", diagnostic.Cancelled},
+    }
+
+    for _, tt := range tests {
+        evaluated := testEvalLimited(tt.ctx, tt.limits, tt.input)
+
+        errObj, isErr := evaluated.(*object.Error)
+        if tt.code == "" {
+            if isErr {
+                t.Errorf("unexpected error for %q: %s", tt.input, errObj.Inspect())
+            }
+            continue
+        }
+        if !isErr {
+            t.Errorf("expected error for %q, got: %T(%+v)", tt.input, evaluated, evaluated)
+            continue
+        }
+        if errObj.Kind != object.LimitError || errObj.Code != tt.code {
+            t.Errorf("wrong error for %q, want: %s, got: %s (%s)", tt.input, tt.code, errObj.Inspect(), errObj.Code)
+        }
+    }
+}
+
+func TestIntegerArithmeticEdgeCases(t *testing.T) {
+    tests := []struct {
+        input       string
+        expected    interface{}
+    }{
+        {"1 / 0", "division by zero: 1 / 0"},
+        {"let x = 5; x /= 0", "division by zero: 5 / 0"},
+        {"let f = fn(n) { 10 / n }; f(0)", "division by zero: 10 / 0"},
+        // floats too, there is no way to write the +Inf or NaN it would give
+        {"1.0 / 0", "division by zero: 1.0 / 0"},
+        {"0 / 0.0", "division by zero: 0 / 0.0"},
+    }
+
+    for _, tt := range tests {
+        evaluated := testEval(t, tt.input)
+        switch expected := tt.expected.(type) {
+        case int64:
+            testIntegerObject(t, evaluated, expected)
+        case string:
+            if errObj, ok := evaluated.(*object.Error); ok {
+                if errObj.Message != expected || errObj.Code != diagnostic.DivisionByZero {
+                    t.Errorf("wrong error for %q, expected %q, got %q (%s)", tt.input, expected, errObj.Message, errObj.Code)
+                }
+            } else if evaluated.Inspect() != expected {
+                t.Errorf("wrong result for %q, expected %q, got %s", tt.input, expected, evaluated.Inspect())
+            }
+        }
+    }
+}
+
+// whatever float a program makes, printing it and running the printed text gives back the exact same float
+func TestFloatRoundTrip(t *testing.T) {
+    tests := []string{
+        "0.1 + 0.2",
+        "1.0 / 3",
+        "2.5 * 4",
+        "-0.0",
+        "1e308 * 1.5",
+        "5e-324 / 2",
+        "float(" + strings.Repeat("9", 300) + ")",
+        "float(\"1e-320\")",
+    }
+
+    for _, input := range tests {
+        evaluated := testEval(t, input)
+        f, ok := evaluated.(*object.Float)
+        if !ok {
+            t.Errorf("%q didn't give a float, got %s", input, evaluated.Inspect())
+            continue
+        }
+        back := testEval(t, f.Inspect())
+        if b, ok := back.(*object.Float); !ok || math.Float64bits(b.Value) != math.Float64bits(f.Value) {
+            t.Errorf("%q printed as %q, which reads back as %s", input, f.Inspect(), back.Inspect())
+        }
+    }
+
+    // the ones that would have to print as +Inf or NaN are errors instead
+    errors := []string{
+        "1e308 * 10",
+        "-1e308 - 1e308",
+        "let x = 1e308; x += x",
+        strings.Repeat("9", 400) + " * 1.0",
+    }
+
+    for _, input := range errors {
+        evaluated := testEval(t, input)
+        errObj, ok := evaluated.(*object.Error)
+        if !ok || errObj.Code != diagnostic.FloatNotFinite {
+            t.Errorf("%q should be a %s error, got %s", input, diagnostic.FloatNotFinite, evaluated.Inspect())
+        }
+    }
+}
+
+func TestCheckedArithmetic(t *testing.T) {
+    tests := []struct {
+        input       string
+        expected    interface{}
+    }{
+        {"9223372036854775807 + 1", "integer overflow: 9223372036854775807 + 1"},
+        {"-9223372036854775807 - 2", "integer overflow: -9223372036854775807 - 2"},
+        {"4611686018427387904 * 2", "integer overflow: 4611686018427387904 * 2"},
+        {"let m = -9223372036854775807 - 1; m * -1", "integer overflow: -9223372036854775808 * -1"},
+        {"let m = -9223372036854775807 - 1; -1 * m", "integer overflow: -1 * -9223372036854775808"},
+        {"let m = -9223372036854775807 - 1; m / -1", "integer overflow: -9223372036854775808 / -1"},
+        {"let m = -9223372036854775807 - 1; -m", "integer overflow: -(-9223372036854775808)"},
+        {"let x = 9223372036854775807; x += 1", "integer overflow: 9223372036854775807 + 1"},
+        {"9223372036854775808 + 0", "integer overflow: 9223372036854775808 + 0"},
+        // right up to the edge is fine
+        {"9223372036854775806 + 1", int64(9223372036854775807)},
+        {"-9223372036854775807 - 1", int64(-9223372036854775808)},
+        {"3037000499 * 3037000499", int64(9223372030926249001)},
+        {"-4611686018427387904 * 2", int64(-9223372036854775808)},
+        {"7 / -2", int64(-3)},
+        {"9223372036854775808 - 1", int64(9223372036854775807)},
+    }
+
+    for _, tt := range tests {
+        c := NewContext(context.Background(), DefaultLimits)
+        c.Overflow = ErrorOnOverflow
+        evaluated := EvalContext(c, parser.New(lexer.New(tt.input)).ParseProgram(), object.NewEnvironment())
+
+        switch expected := tt.expected.(type) {
+        case int64:
+            testIntegerObject(t, evaluated, expected)
+        case string:
+            errObj, ok := evaluated.(*object.Error)
+            if !ok {
+                t.Errorf("expected error for %q, got: %T(%+v)", tt.input, evaluated, evaluated)
+                continue
+            }
+            if errObj.Message != expected || errObj.Code != diagnostic.IntegerOverflow {
+                t.Errorf("wrong error for %q, expected %q, got %q (%s)", tt.input, expected, errObj.Message, errObj.Code)
+            }
+        }
+    }
+}
+
+func TestBigIntegers(t *testing.T) {
+    tests := []struct {
+        input       string
+        expected    string
+    }{
+        {"9223372036854775807 + 1", "9223372036854775808"},
+        {"-9223372036854775807 - 2", "-9223372036854775809"},
+        {"4611686018427387904 * 4", "18446744073709551616"},
+        {"123456789012345678901234567890", "123456789012345678901234567890"},
+        {"123456789012345678901234567890 * 10 / 10", "123456789012345678901234567890"},
+        {"-123456789012345678901234567890 / 1000000000000000000000", "-123456789"},
+        // back to a regular integer as soon as it fits, the smallest int64 doesn't fit as a positive literal
+        {"9223372036854775808 - 1", "9223372036854775807"},
+        {"-9223372036854775808", "-9223372036854775808"},
+        {"let m = -9223372036854775808; -m", "9223372036854775808"},
+        {"let m = -9223372036854775808; m / -1", "9223372036854775808"},
+        {"let f = fn(n) { if (n == 0) { 1 } else { n * f(n - 1) } }; f(25)", "15511210043330985984000000"},
+        {"let x = 9223372036854775807; x += x; x", "18446744073709551614"},
+        {"9223372036854775808 > 9223372036854775807", "true"},
+        {"9223372036854775808 < 1", "false"},
+        {"9223372036854775808 == 9223372036854775808", "true"},
+        {"9223372036854775808 != 9223372036854775809", "true"},
+        {"9223372036854775808 >= -9223372036854775809", "true"},
+        {"18446744073709551616 <= 18446744073709551616", "true"},
+        {"9223372036854775808 + 0.5", "9.223372036854776e+18"},
+        {"type(9223372036854775808)", "INTEGER"},
+        {"int(\"100000000000000000000\")", "100000000000000000000"},
+        {"int(1e20)", "100000000000000000000"},
+        {"float(18446744073709551616)", "1.8446744073709552e+19"},
+        {"{9223372036854775808: \"big\", 1: \"small\"}[9223372036854775807 + 1]", "big"},
+        // a big integer key used to share its hash key with this integer
+        {"let h = {18446744073709551616: \"big\"}; h[554774489934347788]", "null"},
+        {"let h = {18446744073709551616: \"big\"}; h[2 * 9223372036854775808]", "big"},
+        {"9223372036854775808 / 0", "division by zero: 9223372036854775808 / 0"},
+        {"[1, 2][9223372036854775808]", "index out of range: 9223372036854775808 (array length 2)"},
+        {"-true * 9223372036854775808", "unknown operator: -BOOLEAN"},
+        {"9223372036854775808 + \"a\"", "type mismatch: INTEGER + STRING"},
+    }
+
+    for _, tt := range tests {
+        evaluated := testEval(t, tt.input)
+        got := evaluated.Inspect()
+        if errObj, ok := evaluated.(*object.Error); ok {
+            got = errObj.Message
+        }
+        if got != tt.expected {
+            t.Errorf("wrong result for %q, expected %q, got %q", tt.input, tt.expected, got)
+        }
+    }
+}
@@ -0,0 +1,93 @@
+package evaluator
+
+import (
+    "context"
+    "skibidi/diagnostic"
+    "skibidi/object"
+    "skibidi/token"
+    "sort"
+)
+
+// the vm package reuses these so that both backends agree on exactly what every operator and builtin does
+
+func EvalInfix(operator string, left object.Object, right object.Object, overflow Overflow) object.Object {
+    return evalInfixExpression(operator, left, right, overflow)
+}
+
+func EvalPrefix(operator string, right object.Object, overflow Overflow) object.Object {
+    return evalPrefixExpression(operator, right, overflow)
+}
+
+func EvalIndex(left object.Object, index object.Object) object.Object {
+    return evalIndexExpression(left, index)
+}
+
+// the elements a for loop goes through, or an error if the object can't be looped over
+func IterableElements(obj object.Object) ([]object.Object, *object.Error) {
+    return iterableElements(obj)
+}
+
+// stores val at left[index], the array or hash is changed in place
+func EvalSetIndex(left object.Object, index object.Object, val object.Object) object.Object {
+    return evalSetIndex(left, index, val)
+}
+
+// the error for calling a function with the wrong number of arguments, max is -1 if there's no upper limit
+func ArityError(name string, got int, required int, max int) *object.Error {
+    return arityError(name, got, required, max)
+}
+
+// the error for nesting calls deeper than max
+func CallDepthError(max int) *object.Error {
+    return newLimitError(diagnostic.CallDepthExceeded, "maximum call depth exceeded (%d calls)", max)
+}
+
+// the vm runs with the same Context as the evaluator, so the limits (and the context.Context) are checked the same way
+// for the vm a step is an instruction rather than a node
+
+func (c *Context) Step() *object.Error {
+    return c.step()
+}
+
+func (c *Context) EnterCall() *object.Error {
+    return c.enterCall()
+}
+
+func (c *Context) LeaveCall() {
+    c.leaveCall()
+}
+
+// counts a new object the program made against the allocation limit
+func (c *Context) Allocate(obj object.Object) *object.Error {
+    return c.allocate(obj)
+}
+
+func IsTruthy(obj object.Object) bool {
+    return isTruthy(obj)
+}
+
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+    builtin, ok := builtins[name]
+    return builtin, ok
+}
+
+// the names of all the builtins, sorted
+func BuiltinNames() []string {
+    names := make([]string, 0, len(builtins))
+    for name := range builtins {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// calls a function or builtin from Go, for embedding (see the interp package)
+// there's no call site in the source, so the stack trace of an error shows the call as coming from nowhere
+func Apply(fn object.Object, args []object.Object) object.Object {
+    return ApplyContext(NewContext(context.Background(), DefaultLimits), fn, args)
+}
+
+// Apply with the limits of the given context
+func ApplyContext(c *Context, fn object.Object, args []object.Object) object.Object {
+    return applyFunction(c, fn, args, token.Position{})
+}
@@ -0,0 +1,16 @@
+package evaluator_test
+
+import (
+    "context"
+    "skibidi/ast"
+    "skibidi/evaluator"
+    "skibidi/object"
+    "skibidi/vm"
+)
+
+// runs every evaluator test against the bytecode vm as well
+func init() {
+    evaluator.RegisterTestBackend("vm", func(program *ast.Program) object.Object {
+        return vm.EvalContext(evaluator.NewContext(context.Background(), evaluator.DefaultLimits), program, object.NewEnvironment())
+    })
+}
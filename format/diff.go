@@ -0,0 +1,166 @@
+package format
+
+import (
+    "fmt"
+    "strings"
+)
+
+// lines of unchanged source shown around every change
+const diffContext = 3
+
+// a line of the diff, ' ' for one both sides have, '-' for one only before has and '+' for one only after has
+type diffLine struct {
+    kind    byte
+    text    string
+}
+
+// a unified diff of before and after (the same format as diff -u), empty if they are the same
+// name is the file both sides are labelled with
+func Diff(name string, before string, after string) string {
+    if before == after {
+        return ""
+    }
+
+    lines := diffLines(splitLines(before), splitLines(after))
+
+    var out strings.Builder
+    fmt.Fprintf(&out, "--- %s\n+++ %s\n", name+".orig", name)
+
+    // each hunk covers a run of changes that are less than two contexts apart, plus the context on either side
+    for start := 0; start < len(lines); {
+        if lines[start].kind == ' ' {
+            start++
+            continue
+        }
+        end := start
+        for i := start; i < len(lines) && i <= end+2*diffContext; i++ {
+            if lines[i].kind != ' ' {
+                end = i
+            }
+        }
+        from, to := max(start-diffContext, 0), min(end+diffContext+1, len(lines))
+        writeHunk(&out, lines, from, to)
+        start = to
+    }
+
+    return out.String()
+}
+
+func writeHunk(out *strings.Builder, lines []diffLine, from int, to int) {
+    // where the hunk starts on each side, counting the lines before it
+    oldStart, newStart := 0, 0
+    for _, l := range lines[:from] {
+        if l.kind != '+' {
+            oldStart++
+        }
+        if l.kind != '-' {
+            newStart++
+        }
+    }
+    oldCount, newCount := 0, 0
+    for _, l := range lines[from:to] {
+        if l.kind != '+' {
+            oldCount++
+        }
+        if l.kind != '-' {
+            newCount++
+        }
+    }
+
+    fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+    for _, l := range lines[from:to] {
+        out.WriteByte(l.kind)
+        if text, ok := strings.CutSuffix(l.text, "\n"); ok {
+            out.WriteString(text + "\n")
+        } else {
+            out.WriteString(text + "\n\\ No newline at end of file\n")
+        }
+    }
+}
+
+// lines are counted from 1, an empty range gives the line before it instead
+func hunkRange(start int, count int) string {
+    if count == 0 {
+        return fmt.Sprintf("%d,0", start)
+    }
+    if count == 1 {
+        return fmt.Sprintf("%d", start+1)
+    }
+    return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// the lines keep their newline, so a last line without one is different from the same line with one
+func splitLines(s string) []string {
+    lines := strings.SplitAfter(s, "\n")
+    if lines[len(lines)-1] == "" {
+        lines = lines[:len(lines)-1]
+    }
+    return lines
+}
+
+// the shortest way of turning a into b, using Myers' algorithm
+// v[k] is how far along a the furthest path on diagonal k (x - y) got, a copy is kept after every step to walk back through at the end
+func diffLines(a []string, b []string) []diffLine {
+    n, m := len(a), len(b)
+    offset := n + m + 1
+    v := make([]int, 2*offset+1)
+    var trace [][]int
+
+search:
+    for d := 0; d <= n+m; d++ {
+        trace = append(trace, append([]int(nil), v...))
+        for k := -d; k <= d; k += 2 {
+            var x int
+            if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+                x = v[offset+k+1] // down, a line of b is inserted
+            } else {
+                x = v[offset+k-1] + 1 // right, a line of a is deleted
+            }
+            y := x - k
+            for x < n && y < m && a[x] == b[y] {
+                x++
+                y++
+            }
+            v[offset+k] = x
+            if x >= n && y >= m {
+                break search
+            }
+        }
+    }
+
+    // walking back from the end gives the lines in reverse
+    var lines []diffLine
+    x, y := n, m
+    for d := len(trace) - 1; d >= 0; d-- {
+        v := trace[d]
+        k := x - y
+        var prevK int
+        if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+            prevK = k + 1
+        } else {
+            prevK = k - 1
+        }
+        prevX := v[offset+prevK]
+        prevY := prevX - prevK
+
+        for x > prevX && y > prevY {
+            x--
+            y--
+            lines = append(lines, diffLine{' ', a[x]})
+        }
+        if d == 0 {
+            break
+        }
+        if x == prevX {
+            lines = append(lines, diffLine{'+', b[prevY]})
+        } else {
+            lines = append(lines, diffLine{'-', a[prevX]})
+        }
+        x, y = prevX, prevY
+    }
+
+    for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+        lines[i], lines[j] = lines[j], lines[i]
+    }
+    return lines
+}
@@ -0,0 +1,447 @@
+// the format package prints skibidi source in one canonical layout, it's what `skibidi fmt` runs
+// the program is parsed and printed again from the ast, so the layout of the original doesn't matter except for a few things
+// that are kept on purpose: comments, single blank lines between statements, and whether a list or block was written on one line
+// formatting already formatted source gives back exactly the same source
+package format
+
+import (
+    "skibidi/ast"
+    "skibidi/diagnostic"
+    "skibidi/lexer"
+    "skibidi/parser"
+    "skibidi/token"
+    "strings"
+)
+
+// one level of indentation
+const indentation = "    "
+
+// binds tighter than any operator, for literals, identifiers and anything else that never needs parentheses around it
+const atom = parser.INDEX + 1
+
+// returned by Source when the source doesn't parse, there's no formatting a program that can't be read
+type ParseError struct {
+    Diagnostics []diagnostic.Diagnostic
+}
+
+func (e *ParseError) Error() string {
+    messages := make([]string, len(e.Diagnostics))
+    for i, d := range e.Diagnostics {
+        messages[i] = d.Error()
+    }
+    return strings.Join(messages, "\n")
+}
+
+// formats a whole file, filename only shows up in the positions of parse errors
+func Source(filename string, src string) (string, error) {
+    p := parser.New(lexer.NewFile(filename, src))
+    program := p.ParseProgram()
+    if len(p.Errors()) != 0 {
+        return "", &ParseError{Diagnostics: p.Errors()}
+    }
+
+    pr := &printer{src: src, comments: collectComments(src)}
+
+    var out strings.Builder
+    line := 0
+    // the lexer skips a shebang line, so it has to be put back by hand
+    if strings.HasPrefix(src, "#!") {
+        shebang, _, _ := strings.Cut(src, "\n")
+        out.WriteString(strings.TrimRight(shebang, " \t\r") + "\n")
+        line = 1
+    }
+    out.WriteString(pr.statements(program.Statements, line, line > 0, len(src)+1, 0, false))
+
+    return out.String(), nil
+}
+
+// every comment in the source in order, the parser never sees them
+func collectComments(src string) []token.Token {
+    l := lexer.New(src)
+    l.KeepComments()
+
+    var comments []token.Token
+    for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+        if tok.Type == token.COMMENT {
+            comments = append(comments, tok)
+        }
+    }
+    return comments
+}
+
+// the comments that haven't been printed yet are put back in whenever a new line starts, before whatever comes after them in the source
+// the printing methods only ever take comments off the front, so a copy of the printer can try something out without changing the original
+type printer struct {
+    src         string
+    comments    []token.Token
+}
+
+// takes the comments that start before offset
+func (p *printer) commentsBefore(offset int) []token.Token {
+    i := 0
+    for i < len(p.comments) && p.comments[i].Pos.Offset < offset {
+        i++
+    }
+    taken := p.comments[:i]
+    p.comments = p.comments[i:]
+    return taken
+}
+
+// the comments that go at the end of a line that ends the source line given, like `let x = 1; // why`
+// a line comment inside an expression printed on one line ends up here as well, since nothing can come after it on its line
+// the line the last of them ends on is given back, a block comment can go over several
+func (p *printer) trailing(line int, end int) (string, int) {
+    var out strings.Builder
+    for len(p.comments) > 0 && p.comments[0].Pos.Line <= line && p.comments[0].Pos.Offset < end {
+        out.WriteString(" " + commentText(p.comments[0]))
+        line = max(line, p.comments[0].End.Line)
+        p.comments = p.comments[1:]
+    }
+    return out.String(), line
+}
+
+// the block comments that start before offset, each followed by a space so they stay right in front of what comes after them
+// stops at a line comment (or a block comment over several lines), that one has to wait for the end of the line
+func (p *printer) inline(offset int) string {
+    var out strings.Builder
+    for len(p.comments) > 0 && p.comments[0].Pos.Offset < offset && isInline(p.comments[0]) {
+        out.WriteString(commentText(p.comments[0]) + " ")
+        p.comments = p.comments[1:]
+    }
+    return out.String()
+}
+
+func isInline(c token.Token) bool {
+    return strings.HasPrefix(c.Literal, "/*") && c.Pos.Line == c.End.Line
+}
+
+// where the comma after a list item is, there's nothing but whitespace and comments between the end of the item and it
+func (p *printer) commaAfter(offset int) int {
+    comments := p.comments
+    for i := offset; i < len(p.src); i++ {
+        for len(comments) > 0 && comments[0].Pos.Offset < i {
+            comments = comments[1:]
+        }
+        if len(comments) > 0 && comments[0].Pos.Offset == i {
+            i = comments[0].End.Offset - 1
+            continue
+        }
+        if p.src[i] == ',' {
+            return i
+        }
+    }
+    return len(p.src)
+}
+
+// whether any comment that's still to be printed is between start and end
+func (p *printer) commentsWithin(start int, end int) bool {
+    for _, c := range p.comments {
+        if c.Pos.Offset >= end {
+            return false
+        }
+        if c.Pos.Offset > start {
+            return true
+        }
+    }
+    return false
+}
+
+func commentText(c token.Token) string {
+    return strings.TrimRight(c.Literal, " \t\r")
+}
+
+// prints statements one per line, along with the comments in between them
+// line is the source line the enclosing block starts on and end is where it ends, the comments before end belong to these statements
+// a blank line in the source between two statements is kept (several become one), printed says whether something came before
+func (p *printer) statements(stmts []ast.Statement, line int, printed bool, end int, depth int, inBlock bool) string {
+    var out strings.Builder
+    prefix := strings.Repeat(indentation, depth)
+
+    startLine := func(l int) {
+        if printed && l > line+1 {
+            out.WriteString("\n")
+        }
+        printed = true
+    }
+    leading := func(offset int) {
+        for _, c := range p.commentsBefore(offset) {
+            startLine(c.Pos.Line)
+            out.WriteString(prefix + commentText(c) + "\n")
+            line = c.End.Line
+        }
+    }
+
+    for i, stmt := range stmts {
+        leading(stmt.Pos().Offset)
+        startLine(stmt.Pos().Line)
+
+        var next ast.Statement
+        if i+1 < len(stmts) {
+            next = stmts[i+1]
+        }
+        text := p.statement(stmt, depth)
+        if p.needsSemicolon(stmt, next, inBlock) {
+            text += ";"
+        }
+
+        comments, last := p.trailing(stmt.End().Line, end)
+        out.WriteString(prefix + text + comments + "\n")
+        line = last
+    }
+    leading(end)
+
+    return out.String()
+}
+
+// let, return, break and continue always end with a semicolon, and so does an expression statement unless it's the value of its block
+// an if expression doesn't need one, except when the next statement starts with something that would carry it on,
+// like the ( in `if (x) { a }; (f)(1)` which would call the if expression otherwise
+func (p *printer) needsSemicolon(stmt ast.Statement, next ast.Statement, inBlock bool) bool {
+    switch stmt := stmt.(type) {
+    case *ast.WhileStatement, *ast.ForStatement:
+        return false
+    case *ast.ExpressionStatement:
+        if next == nil {
+            return !inBlock && !isIf(stmt.Expression)
+        }
+        if isIf(stmt.Expression) {
+            return p.continuesExpression(next)
+        }
+    }
+    return true
+}
+
+func isIf(e ast.Expression) bool {
+    _, ok := e.(*ast.IfExpression)
+    return ok
+}
+
+// whether the statement starts with a token that could also come after an expression
+func (p *printer) continuesExpression(stmt ast.Statement) bool {
+    es, ok := stmt.(*ast.ExpressionStatement)
+    if !ok {
+        return false
+    }
+    // printed by a copy so the comments stay where they are, the ones before the statement go on lines of their own
+    peek := *p
+    peek.commentsBefore(es.Pos().Offset)
+    text := peek.expression(es.Expression, 0)
+    return strings.HasPrefix(text, "(") || strings.HasPrefix(text, "[") || strings.HasPrefix(text, "-")
+}
+
+func (p *printer) statement(stmt ast.Statement, depth int) string {
+    switch stmt := stmt.(type) {
+    case *ast.LetStatement:
+        return "let " + stmt.Name.Value + " = " + p.expression(stmt.Value, depth)
+    case *ast.ReturnStatement:
+        if stmt.ReturnValue == nil {
+            return "return"
+        }
+        return "return " + p.expression(stmt.ReturnValue, depth)
+    case *ast.BreakStatement:
+        return "break"
+    case *ast.ContinueStatement:
+        return "continue"
+    case *ast.WhileStatement:
+        return "while (" + p.expression(stmt.Condition, depth) + ") " + p.block(stmt.Body, depth)
+    case *ast.ForStatement:
+        return "for (" + stmt.Variable.Value + " in " + p.expression(stmt.Iterable, depth) + ") " + p.block(stmt.Body, depth)
+    case *ast.ExpressionStatement:
+        return p.expression(stmt.Expression, depth)
+    }
+    return stmt.String()
+}
+
+// a block stays on one line if it was written on one line and holds at most one statement, otherwise it's spread out
+func (p *printer) block(b *ast.BlockStatement, depth int) string {
+    start, end := b.Token.Pos.Offset, b.Rbrace.Pos.Offset
+    if p.commentsWithin(start, end) {
+        return "{\n" + p.statements(b.Statements, b.Token.Pos.Line, false, end, depth+1, true) + strings.Repeat(indentation, depth) + "}"
+    }
+    if len(b.Statements) == 0 {
+        return "{}"
+    }
+
+    if len(b.Statements) == 1 && b.Token.Pos.Line == b.Rbrace.Pos.Line {
+        text := p.statement(b.Statements[0], depth)
+        if p.needsSemicolon(b.Statements[0], nil, true) {
+            text += ";"
+        }
+        if !strings.Contains(text, "\n") {
+            return "{ " + text + " }"
+        }
+    }
+
+    return "{\n" + p.statements(b.Statements, b.Token.Pos.Line, false, end, depth+1, true) + strings.Repeat(indentation, depth) + "}"
+}
+
+// how tightly the expression holds together, the same scale as the parser's precedences
+func precedence(e ast.Expression) int {
+    switch e := e.(type) {
+    case *ast.InfixExpression:
+        return parser.Precedence(e.Token.Type)
+    case *ast.AssignExpression:
+        return parser.ASSIGN
+    case *ast.PrefixExpression:
+        return parser.PREFIX
+    case *ast.CallExpression:
+        return parser.CALL
+    case *ast.IndexExpression:
+        return parser.INDEX
+    }
+    return atom
+}
+
+// an expression that is part of a bigger one, in parentheses if it would come apart without them
+// min is the loosest precedence the parser would still read as one piece in this spot
+func (p *printer) operand(e ast.Expression, min int, depth int) string {
+    text := p.expression(e, depth)
+    if precedence(e) < min {
+        return "(" + text + ")"
+    }
+    return text
+}
+
+// a block comment right in front of an expression stays right in front of it, like the one in `1 + /* why */ 2`
+func (p *printer) expression(e ast.Expression, depth int) string {
+    comments := p.inline(e.Pos().Offset)
+    return comments + p.bareExpression(e, depth)
+}
+
+func (p *printer) bareExpression(e ast.Expression, depth int) string {
+    switch e := e.(type) {
+    case *ast.Identifier:
+        return e.Value
+    case *ast.IntegerLiteral:
+        return e.Token.Literal
+    case *ast.FloatLiteral:
+        return e.Token.Literal
+    case *ast.Boolean:
+        return e.Token.Literal
+    case *ast.StringLiteral:
+        // straight from the source, so the escapes are written the way they were
+        return p.src[e.Token.Pos.Offset:e.Token.End.Offset]
+    case *ast.PrefixExpression:
+        return e.Operator + p.operand(e.Right, parser.PREFIX, depth)
+    case *ast.InfixExpression:
+        // the operators are left associative, so the right side needs parentheses at the same precedence and the left side doesn't
+        // a comment before the operator stays with the left side, `1 /* mid */ + 2` doesn't become `1 + /* mid */ 2`
+        prec := parser.Precedence(e.Token.Type)
+        left := p.operand(e.Left, prec, depth)
+        return left + " " + p.inline(e.Token.Pos.Offset) + e.Operator + " " + p.operand(e.Right, prec+1, depth)
+    case *ast.AssignExpression:
+        target := p.expression(e.Target, depth)
+        return target + " " + p.inline(e.Token.Pos.Offset) + e.Operator + " " + p.expression(e.Value, depth)
+    case *ast.CallExpression:
+        // calls and indexes chain, f(1)[0](2) needs no parentheses anywhere
+        items := make([]listItem, len(e.Arguments))
+        for i, arg := range e.Arguments {
+            items[i] = p.expressionItem(arg)
+        }
+        return p.operand(e.Function, parser.CALL, depth) + "(" + p.list(e.Token, e.Rparen, items, depth) + ")"
+    case *ast.IndexExpression:
+        return p.operand(e.Left, parser.CALL, depth) + "[" + p.expression(e.Index, depth) + "]"
+    case *ast.ArrayLiteral:
+        items := make([]listItem, len(e.Elements))
+        for i, el := range e.Elements {
+            items[i] = p.expressionItem(el)
+        }
+        return "[" + p.list(e.Token, e.Rbracket, items, depth) + "]"
+    case *ast.HashLiteral:
+        items := make([]listItem, len(e.Pairs))
+        for i, pair := range e.Pairs {
+            pair := pair
+            items[i] = listItem{pos: pair.Key.Pos(), end: pair.Value.End(), print: func(depth int) string {
+                return p.expression(pair.Key, depth) + ": " + p.expression(pair.Value, depth)
+            }}
+        }
+        return "{" + p.list(e.Token, e.Rbrace, items, depth) + "}"
+    case *ast.IfExpression:
+        text := "if (" + p.expression(e.Condition, depth) + ") " + p.block(e.Consequence, depth)
+        if e.Alternative != nil {
+            text += " else " + p.block(e.Alternative, depth)
+        }
+        return text
+    case *ast.FunctionLiteral:
+        params := []string{}
+        for i, param := range e.Parameters {
+            if i < len(e.Defaults) && e.Defaults[i] != nil {
+                params = append(params, param.Value+" = "+p.expression(e.Defaults[i], depth))
+            } else {
+                params = append(params, param.Value)
+            }
+        }
+        if e.Rest != nil {
+            params = append(params, "..."+e.Rest.Value)
+        }
+        return "fn(" + strings.Join(params, ", ") + ") " + p.block(e.Body, depth)
+    }
+    return e.String()
+}
+
+// an element of an array, an argument of a call or a pair of a hash
+type listItem struct {
+    pos     token.Position
+    end     token.Position
+    print   func(depth int) string
+}
+
+func (p *printer) expressionItem(e ast.Expression) listItem {
+    return listItem{pos: e.Pos(), end: e.End(), print: func(depth int) string { return p.expression(e, depth) }}
+}
+
+// the block comments between an item and the comma after it (or the closing bracket after the last one),
+// with a space in front rather than after them
+func (p *printer) afterItem(item listItem, last bool, close token.Token) string {
+    end := close.Pos.Offset
+    if !last {
+        end = p.commaAfter(item.end.Offset)
+    }
+    comments := p.inline(end)
+    if comments == "" {
+        return ""
+    }
+    return " " + strings.TrimSuffix(comments, " ")
+}
+
+// the items between open and close, all on one line unless the first one was on a new line in the source
+// or the list went over several lines with comments in it, in which case they all get a line of their own
+// a comment before an item's comma stays with that item
+func (p *printer) list(open token.Token, close token.Token, items []listItem, depth int) string {
+    if len(items) == 0 {
+        return ""
+    }
+
+    multiLine := open.Pos.Line != close.Pos.Line && p.commentsWithin(open.Pos.Offset, close.Pos.Offset)
+    if items[0].pos.Line == open.Pos.Line && !multiLine {
+        var out strings.Builder
+        for i, item := range items {
+            out.WriteString(item.print(depth) + p.afterItem(item, i == len(items)-1, close))
+            if i < len(items)-1 {
+                out.WriteString(", ")
+            }
+        }
+        return out.String()
+    }
+
+    var out strings.Builder
+    prefix := strings.Repeat(indentation, depth+1)
+    out.WriteString("\n")
+    for i, item := range items {
+        for _, c := range p.commentsBefore(item.pos.Offset) {
+            out.WriteString(prefix + commentText(c) + "\n")
+        }
+        out.WriteString(prefix + item.print(depth+1) + p.afterItem(item, i == len(items)-1, close))
+        if i < len(items)-1 {
+            out.WriteString(",")
+        }
+        comments, _ := p.trailing(item.end.Line, close.Pos.Offset)
+        out.WriteString(comments + "\n")
+    }
+    for _, c := range p.commentsBefore(close.Pos.Offset) {
+        out.WriteString(prefix + commentText(c) + "\n")
+    }
+    out.WriteString(strings.Repeat(indentation, depth))
+
+    return out.String()
+}
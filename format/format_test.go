@@ -0,0 +1,154 @@
+package format
+
+import (
+    "errors"
+    "skibidi/lexer"
+    "skibidi/parser"
+    "strings"
+    "testing"
+)
+
+func TestSource(t *testing.T) {
+    tests := []struct {
+        input       string
+        expected    string
+    }{
+        {"let x=1\nx", "let x = 1;\nx;\n"},
+        {"", ""},
+        // only the parentheses that change the meaning stay
+        {"((1 + 2) * 3) - (4 - 5)", "(1 + 2) * 3 - (4 - 5);\n"},
+        {"a || (b && c); (a || b) && c", "a || b && c;\n(a || b) && c;\n"},
+        {"(-x)[0]; -(x[0]); -(-x); !(a == b)", "(-x)[0];\n-x[0];\n--x;\n!(a == b);\n"},
+        {"(f(1))[0](2); (a + b)(1)", "f(1)[0](2);\n(a + b)(1);\n"},
+        {"a = (b = 1); x += (1 + 2)", "a = b = 1;\nx += 1 + 2;\n"},
+        {"a + (b = 1)", "a + (b = 1);\n"},
+        // literals are written the way they were
+        {`let s = "tab\té"; let n = .5e3`, "let s = \"tab\\té\";\nlet n = .5e3;\n"},
+        {"let f = fn(a, b=2, ...rest) {a+b}", "let f = fn(a, b = 2, ...rest) { a + b };\n"},
+        // a block on one line stays on one line if it only has one statement
+        {"if (x) { return 1; } else { let y = 2; y }", "if (x) { return 1; } else {\n    let y = 2;\n    y\n}\n"},
+        {"while (x < 3) {\nx += 1;\n}\nfor (i in [1,2]) {}", "while (x < 3) {\n    x += 1\n}\nfor (i in [1, 2]) {}\n"},
+        {"fn() {\n  if (a) {\n    while (b) { break; }\n  }\n}", "fn() {\n    if (a) {\n        while (b) { break; }\n    }\n};\n"},
+        // an if expression only needs a semicolon when the next statement could carry it on
+        {"if (a) { 1 }; (a + b)(2); if (b) { 3 }; (f)(2)", "if (a) { 1 };\n(a + b)(2);\nif (b) { 3 }\nf(2);\n"},
+        {"if (a) { 1 }; [1]", "if (a) { 1 };\n[1];\n"},
+        // a list that starts on a new line gets a line for every item
+        {"let a = [\n1, 2,\n  3]; let h = {\"a\": 1,\n\"b\": 2}", "let a = [\n    1,\n    2,\n    3\n];\nlet h = {\"a\": 1, \"b\": 2};\n"},
+        {"puts(\nfn(x) {\nx\n}, 2)", "puts(\n    fn(x) {\n        x\n    },\n    2\n);\n"},
+        // blank lines are kept, but never more than one
+        {"let a = 1;\n\n\n\nlet b = 2;\nlet c = 3;", "let a = 1;\n\nlet b = 2;\nlet c = 3;\n"},
+        {"#!/usr/bin/env skibidi  \n\nputs(1)", "#!/usr/bin/env skibidi\n\nputs(1);\n"},
+    }
+
+    for _, tt := range tests {
+        got, err := Source("test", tt.input)
+        if err != nil {
+            t.Errorf("unexpected error for %q: %s", tt.input, err)
+            continue
+        }
+        if got != tt.expected {
+            t.Errorf("wrong formatting of %q, expected %q, got %q", tt.input, tt.expected, got)
+        }
+        checkFormatted(t, tt.input, got)
+    }
+}
+
+func TestComments(t *testing.T) {
+    tests := []struct {
+        input       string
+        expected    string
+    }{
+        {"// top\nlet x = 1; // why\n\n/* block */\nx", "// top\nlet x = 1; // why\n\n/* block */\nx;\n"},
+        {"// only a comment   ", "// only a comment\n"},
+        {"let f = fn(x) { // first\n  // second\n\n  x   // the result\n  // last\n}", "let f = fn(x) {\n    // first\n    // second\n\n    x // the result\n    // last\n};\n"},
+        {"if (x) { /* nothing */ }", "if (x) {\n    /* nothing */\n}\n"},
+        {"let a = [\n  1, // one\n  /* two */ 2\n  // end\n];", "let a = [\n    1, // one\n    /* two */\n    2\n    // end\n];\n"},
+        // a block comment in the middle of a line stays next to what it was written after (or in front of)
+        {"let a = 1 + /* odd */ 2;", "let a = 1 + /* odd */ 2;\n"},
+        {"let x = 1 /* mid */ + 2;", "let x = 1 /* mid */ + 2;\n"},
+        {"x /* a */ += /* b */ f(/* c */ 1 /* d */, 2 /* e */)", "x /* a */ += /* b */ f(/* c */ 1 /* d */, 2 /* e */);\n"},
+        {"if (/* yes */ a) { 1 }; /* call */ (a + b)(2)", "if (/* yes */ a) { 1 }; /* call */\n(a + b)(2);\n"},
+        // a line comment can't have anything after it, so it goes to the end of the line
+        {"let a = 1 + // odd\n  2;", "let a = 1 + 2; // odd\n"},
+        // a list written over several lines with comments inside keeps a line for every item
+        {"let a = [1,\n 2, // two\n 3];", "let a = [\n    1,\n    2, // two\n    3\n];\n"},
+        {"let a = f(1, // first\n  2);", "let a = f(\n    1, // first\n    2\n);\n"},
+        {"let h = {\"a\": 1 /* one */,\n  \"b\": 2};", "let h = {\n    \"a\": 1 /* one */,\n    \"b\": 2\n};\n"},
+    }
+
+    for _, tt := range tests {
+        got, err := Source("test", tt.input)
+        if err != nil {
+            t.Errorf("unexpected error for %q: %s", tt.input, err)
+            continue
+        }
+        if got != tt.expected {
+            t.Errorf("wrong formatting of %q, expected %q, got %q", tt.input, tt.expected, got)
+        }
+        checkFormatted(t, tt.input, got)
+    }
+}
+
+// formatted source has to mean the same thing as the original, keep every comment, and come out the same when formatted again
+func checkFormatted(t *testing.T, input string, formatted string) {
+    t.Helper()
+
+    if parse(t, input) != parse(t, formatted) {
+        t.Errorf("formatting %q changed its meaning to %q", input, formatted)
+    }
+    if len(collectComments(input)) != len(collectComments(formatted)) {
+        t.Errorf("formatting %q lost comments: %q", input, formatted)
+    }
+
+    again, err := Source("test", formatted)
+    if err != nil {
+        t.Errorf("formatted source %q doesn't parse: %s", formatted, err)
+    } else if again != formatted {
+        t.Errorf("formatting isn't idempotent, %q became %q", formatted, again)
+    }
+}
+
+func parse(t *testing.T, src string) string {
+    p := parser.New(lexer.New(src))
+    program := p.ParseProgram()
+    if len(p.Errors()) != 0 {
+        t.Fatalf("%q doesn't parse: %s", src, p.Errors()[0].Error())
+    }
+    return program.String()
+}
+
+func TestParseError(t *testing.T) {
+    _, err := Source("bad.skb", "let = 1;")
+
+    var parseErr *ParseError
+    if !errors.As(err, &parseErr) {
+        t.Fatalf("expected a ParseError, got %#v", err)
+    }
+    if !strings.HasPrefix(err.Error(), "bad.skb:1:5:") {
+        t.Errorf("wrong error message: %q", err.Error())
+    }
+}
+
+func TestDiff(t *testing.T) {
+    tests := []struct {
+        before      string
+        after       string
+        expected    string
+    }{
+        {"a\n", "a\n", ""},
+        {"a\nb\nc\n", "a\nB\nc\n", "--- f.orig\n+++ f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"},
+        {"", "a\n", "--- f.orig\n+++ f\n@@ -0,0 +1 @@\n+a\n"},
+        {"a", "a\n", "--- f.orig\n+++ f\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+a\n"},
+        // changes far apart get a hunk each, with three lines around them
+        {"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", "x\n2\n3\n4\n5\n6\n7\n8\n9\ny\n",
+            "--- f.orig\n+++ f\n@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+y\n"},
+        {"1\n2\n3\n4\n5\n", "1\n2\n4\n5\n6\n", "--- f.orig\n+++ f\n@@ -1,5 +1,5 @@\n 1\n 2\n-3\n 4\n 5\n+6\n"},
+    }
+
+    for _, tt := range tests {
+        got := Diff("f", tt.before, tt.after)
+        if got != tt.expected {
+            t.Errorf("wrong diff of %q and %q, expected %q, got %q", tt.before, tt.after, tt.expected, got)
+        }
+    }
+}
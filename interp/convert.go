@@ -0,0 +1,366 @@
+package interp
+
+import (
+    "errors"
+    "fmt"
+    "math"
+    "math/big"
+    "reflect"
+    "skibidi/diagnostic"
+    "skibidi/evaluator"
+    "skibidi/object"
+)
+
+var (
+    objectType  = reflect.TypeOf((*object.Object)(nil)).Elem()
+    errorType   = reflect.TypeOf((*error)(nil)).Elem()
+    bigIntType  = reflect.TypeOf((*big.Int)(nil))
+)
+
+// converts a skibidi object to the closest Go value:
+// integers become int64 (or *big.Int if they don't fit in one), floats float64, strings string, booleans bool and null nil
+// arrays become []any and hashes map[any]any, converting their contents the same way
+// anything else (functions, builtins) comes back as the object itself so it can still be handed back to the interpreter
+// an array or hash that contains itself (after a[0] = a) comes back as the object itself where it repeats,
+// a Go slice or map holding itself would send most Go code (fmt included) around in circles forever
+func ToGo(obj object.Object) any {
+    return toGo(obj, map[object.Object]bool{})
+}
+
+// seen has the arrays and hashes being converted further up, the same way as Array.Inspect keeps track of them
+func toGo(obj object.Object, seen map[object.Object]bool) any {
+    switch obj.(type) {
+    case *object.Array, *object.Hash:
+        if seen[obj] {
+            return obj
+        }
+        seen[obj] = true
+        defer delete(seen, obj)
+    }
+
+    switch obj := obj.(type) {
+    case nil, *object.Null:
+        return nil
+    case *object.Integer:
+        return obj.Value
+    case *object.BigInt:
+        // a copy, the object's value must never change
+        return new(big.Int).Set(obj.Value)
+    case *object.Float:
+        return obj.Value
+    case *object.String:
+        return obj.Value
+    case *object.Boolean:
+        return obj.Value
+    case *object.Array:
+        elements := make([]any, len(obj.Elements))
+        for i, el := range obj.Elements {
+            elements[i] = toGo(el, seen)
+        }
+        return elements
+    case *object.Hash:
+        pairs := make(map[any]any, len(obj.Pairs))
+        for _, pair := range obj.Pairs {
+            pairs[toGo(pair.Key, seen)] = toGo(pair.Value, seen)
+        }
+        return pairs
+    default:
+        return obj
+    }
+}
+
+// converts a Go value to a skibidi object, the reverse of ToGo
+// any integer, float, string or bool type works, and so does a *big.Int
+// slices and arrays become arrays, maps become hashes, functions become builtins (see FuncToBuiltin)
+// nil and nil pointers become null, an object.Object is used as it is
+func ToObject(value any) (object.Object, error) {
+    if value == nil {
+        return evaluator.NULL, nil
+    }
+    if obj, ok := value.(object.Object); ok {
+        return obj, nil
+    }
+    if n, ok := value.(*big.Int); ok && n != nil {
+        return object.IntegerFromBig(new(big.Int).Set(n)), nil
+    }
+    return valueToObject(reflect.ValueOf(value))
+}
+
+func valueToObject(v reflect.Value) (object.Object, error) {
+    switch v.Kind() {
+    case reflect.Bool:
+        if v.Bool() {
+            return evaluator.TRUE, nil
+        }
+        return evaluator.FALSE, nil
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return &object.Integer{Value: v.Int()}, nil
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        if v.Uint() > math.MaxInt64 {
+            return &object.BigInt{Value: new(big.Int).SetUint64(v.Uint())}, nil
+        }
+        return &object.Integer{Value: int64(v.Uint())}, nil
+    case reflect.Float32, reflect.Float64:
+        if math.IsInf(v.Float(), 0) || math.IsNaN(v.Float()) {
+            return nil, fmt.Errorf("cannot convert %v, floats have to be finite", v.Float())
+        }
+        return &object.Float{Value: v.Float()}, nil
+    case reflect.String:
+        return &object.String{Value: v.String()}, nil
+    case reflect.Slice, reflect.Array:
+        if v.Kind() == reflect.Slice && v.IsNil() {
+            return &object.Array{Elements: []object.Object{}}, nil
+        }
+        elements := make([]object.Object, v.Len())
+        for i := range elements {
+            el, err := ToObject(v.Index(i).Interface())
+            if err != nil {
+                return nil, err
+            }
+            elements[i] = el
+        }
+        return &object.Array{Elements: elements}, nil
+    case reflect.Map:
+        pairs := make(map[object.HashKey]object.HashPair, v.Len())
+        iter := v.MapRange()
+        for iter.Next() {
+            key, err := ToObject(iter.Key().Interface())
+            if err != nil {
+                return nil, err
+            }
+            hashable, ok := key.(object.Hashable)
+            if !ok {
+                return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+            }
+            value, err := ToObject(iter.Value().Interface())
+            if err != nil {
+                return nil, err
+            }
+            pairs[hashable.HashKey()] = object.HashPair{Key: key, Value: value}
+        }
+        return &object.Hash{Pairs: pairs}, nil
+    case reflect.Func:
+        builtin, err := funcToBuiltin("", v)
+        if err != nil {
+            return nil, err
+        }
+        return builtin, nil
+    case reflect.Pointer, reflect.Interface:
+        if v.IsNil() {
+            return evaluator.NULL, nil
+        }
+        return ToObject(v.Elem().Interface())
+    default:
+        return nil, fmt.Errorf("can't convert %s to an object", v.Type())
+    }
+}
+
+// wraps a Go function as a builtin, the arguments are converted to the function's parameter types when it's called
+// parameters can be any type ToObject understands (and ToGo for any/interface{} ones), or object.Object to get the object untouched
+// a variadic function takes any number of arguments for its last parameter
+// it can return nothing, a value, an error, or a value and an error, a non nil error becomes a skibidi error
+func FuncToBuiltin(name string, fn any) (*object.Builtin, error) {
+    v := reflect.ValueOf(fn)
+    if v.Kind() != reflect.Func {
+        return nil, fmt.Errorf("%s is not a function: %T", name, fn)
+    }
+    return funcToBuiltin(name, v)
+}
+
+func funcToBuiltin(name string, fn reflect.Value) (*object.Builtin, error) {
+    if fn.IsNil() {
+        return nil, errors.New("nil function")
+    }
+
+    t := fn.Type()
+    switch {
+    case t.NumOut() > 2,
+        t.NumOut() == 2 && t.Out(1) != errorType:
+        return nil, fmt.Errorf("%s has to return nothing, a value, an error or a value and an error, got %s", name, t)
+    }
+
+    builtin := &object.Builtin{Name: name}
+    builtin.Fn = func(args ...object.Object) object.Object {
+        in, errObj := convertArgs(name, t, args)
+        if errObj != nil {
+            return errObj
+        }
+        return convertResults(fn.Call(in))
+    }
+
+    return builtin, nil
+}
+
+func convertArgs(name string, t reflect.Type, args []object.Object) ([]reflect.Value, *object.Error) {
+    required := t.NumIn()
+    max := required
+    if t.IsVariadic() {
+        required--
+        max = -1
+    }
+    if len(args) < required || max >= 0 && len(args) > max {
+        return nil, evaluator.ArityError(name, len(args), required, max)
+    }
+
+    in := make([]reflect.Value, len(args))
+    for i, arg := range args {
+        var paramType reflect.Type
+        if t.IsVariadic() && i >= t.NumIn()-1 {
+            paramType = t.In(t.NumIn() - 1).Elem()
+        } else {
+            paramType = t.In(i)
+        }
+
+        value, err := objectToValue(arg, paramType)
+        if err != nil {
+            return nil, &object.Error{
+                Code:       diagnostic.InvalidArgument,
+                Message:    fmt.Sprintf("argument %d to `%s` not supported: %s", i+1, name, err),
+            }
+        }
+        in[i] = value
+    }
+
+    return in, nil
+}
+
+func convertResults(out []reflect.Value) object.Object {
+    // the error is always last
+    if len(out) > 0 && out[len(out)-1].Type() == errorType {
+        if err, _ := out[len(out)-1].Interface().(error); err != nil {
+            return &object.Error{Code: diagnostic.HostError, Message: err.Error()}
+        }
+        out = out[:len(out)-1]
+    }
+
+    if len(out) == 0 {
+        return evaluator.NULL
+    }
+
+    obj, err := ToObject(out[0].Interface())
+    if err != nil {
+        return &object.Error{Code: diagnostic.HostError, Message: err.Error()}
+    }
+    return obj
+}
+
+// converts an object to a Go value of type t, the other direction from valueToObject
+func objectToValue(obj object.Object, t reflect.Type) (reflect.Value, error) {
+    if t == objectType {
+        return reflect.ValueOf(&obj).Elem(), nil
+    }
+    if t == bigIntType {
+        switch n := obj.(type) {
+        case *object.Integer:
+            return reflect.ValueOf(big.NewInt(n.Value)), nil
+        case *object.BigInt:
+            return reflect.ValueOf(new(big.Int).Set(n.Value)), nil
+        default:
+            return reflect.Value{}, fmt.Errorf("want %s, got %s", t, obj.Type())
+        }
+    }
+    if t.Kind() == reflect.Interface {
+        goValue := ToGo(obj)
+        if goValue == nil {
+            return reflect.Zero(t), nil
+        }
+        v := reflect.ValueOf(goValue)
+        if !v.Type().AssignableTo(t) {
+            return reflect.Value{}, fmt.Errorf("%s can't be used as %s", obj.Type(), t)
+        }
+        return v, nil
+    }
+
+    v := reflect.New(t).Elem()
+    mismatch := fmt.Errorf("want %s, got %s", t, obj.Type())
+
+    switch t.Kind() {
+    case reflect.Bool:
+        b, ok := obj.(*object.Boolean)
+        if !ok {
+            return v, mismatch
+        }
+        v.SetBool(b.Value)
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        if _, isBig := obj.(*object.BigInt); isBig {
+            return v, fmt.Errorf("%s doesn't fit in %s", obj.Inspect(), t)
+        }
+        i, ok := obj.(*object.Integer)
+        if !ok {
+            return v, mismatch
+        }
+        if v.OverflowInt(i.Value) {
+            return v, fmt.Errorf("%d doesn't fit in %s", i.Value, t)
+        }
+        v.SetInt(i.Value)
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        if n, isBig := obj.(*object.BigInt); isBig {
+            if n.Value.Sign() < 0 || !n.Value.IsUint64() || v.OverflowUint(n.Value.Uint64()) {
+                return v, fmt.Errorf("%s doesn't fit in %s", obj.Inspect(), t)
+            }
+            v.SetUint(n.Value.Uint64())
+            return v, nil
+        }
+        i, ok := obj.(*object.Integer)
+        if !ok {
+            return v, mismatch
+        }
+        if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+            return v, fmt.Errorf("%d doesn't fit in %s", i.Value, t)
+        }
+        v.SetUint(uint64(i.Value))
+    case reflect.Float32, reflect.Float64:
+        // an integer is fine where a float is wanted, same as in arithmetic
+        switch n := obj.(type) {
+        case *object.Float:
+            v.SetFloat(n.Value)
+        case *object.Integer:
+            v.SetFloat(float64(n.Value))
+        case *object.BigInt:
+            f, _ := new(big.Float).SetInt(n.Value).Float64()
+            v.SetFloat(f)
+        default:
+            return v, mismatch
+        }
+    case reflect.String:
+        s, ok := obj.(*object.String)
+        if !ok {
+            return v, mismatch
+        }
+        v.SetString(s.Value)
+    case reflect.Slice:
+        array, ok := obj.(*object.Array)
+        if !ok {
+            return v, mismatch
+        }
+        v.Set(reflect.MakeSlice(t, len(array.Elements), len(array.Elements)))
+        for i, el := range array.Elements {
+            elValue, err := objectToValue(el, t.Elem())
+            if err != nil {
+                return v, err
+            }
+            v.Index(i).Set(elValue)
+        }
+    case reflect.Map:
+        hash, ok := obj.(*object.Hash)
+        if !ok {
+            return v, mismatch
+        }
+        v.Set(reflect.MakeMapWithSize(t, len(hash.Pairs)))
+        for _, pair := range hash.Pairs {
+            key, err := objectToValue(pair.Key, t.Key())
+            if err != nil {
+                return v, err
+            }
+            value, err := objectToValue(pair.Value, t.Elem())
+            if err != nil {
+                return v, err
+            }
+            v.SetMapIndex(key, value)
+        }
+    default:
+        return v, fmt.Errorf("parameters of type %s aren't supported", t)
+    }
+
+    return v, nil
+}
@@ -0,0 +1,193 @@
+// the interp package is for embedding skibidi in a Go program
+// an Interpreter keeps its variables between calls to Eval, and Go values are converted to and from skibidi objects automatically
+//
+//  in := interp.New()
+//  in.Set("limit", 10)
+//  in.RegisterFunc("lookup", func(id string) (int, error) { ... })
+//  result, err := in.Eval(`lookup("abc") < limit`)
+package interp
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "reflect"
+    "skibidi/diagnostic"
+    "skibidi/evaluator"
+    "skibidi/lexer"
+    "skibidi/object"
+    "skibidi/parser"
+    "skibidi/vm"
+    "strings"
+)
+
+type Interpreter struct {
+    env *object.Environment
+
+    // what every Eval and Call is limited to, each call gets the full limits to itself
+    // set it to something stricter before running scripts that can't be trusted
+    Limits evaluator.Limits
+
+    // set to evaluator.ErrorOnOverflow to make integer overflow an error instead of switching to a big integer
+    Overflow evaluator.Overflow
+
+    // run with the bytecode vm (see the vm package), which is faster than the tree walking evaluator
+    // the vm can call functions the evaluator made but not the other way around, so set it before the first Eval
+    UseVM bool
+
+    // where the program's puts writes to, os.Stdout when it's nil
+    Stdout io.Writer
+}
+
+func New() *Interpreter {
+    return &Interpreter{env: object.NewEnvironment(), Limits: evaluator.DefaultLimits}
+}
+
+// returned by Eval when the source doesn't parse, there's one diagnostic for every mistake
+type ParseError struct {
+    Diagnostics []diagnostic.Diagnostic
+}
+
+func (e *ParseError) Error() string {
+    messages := make([]string, len(e.Diagnostics))
+    for i, d := range e.Diagnostics {
+        messages[i] = d.Error()
+    }
+    return strings.Join(messages, "\n")
+}
+
+// returned when the program fails while it's running
+type RuntimeError struct {
+    Err *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+    return e.Err.Diagnostic().Error()
+}
+
+// runs src in the interpreter's environment and gives back the value of the last statement converted to Go (see ToGo)
+// anything the source binds with let is still there for the next Eval
+func (in *Interpreter) Eval(src string) (any, error) {
+    return in.EvalContext(context.Background(), src)
+}
+
+// Eval that stops with a RuntimeError (of kind object.LimitError) once ctx is done
+func (in *Interpreter) EvalContext(ctx context.Context, src string) (result any, err error) {
+    defer recoverInternal(&err)
+
+    p := parser.New(lexer.New(src))
+    program := p.ParseProgram()
+    if len(p.Errors()) != 0 {
+        return nil, &ParseError{Diagnostics: p.Errors()}
+    }
+
+    var evaluated object.Object
+    if in.UseVM {
+        evaluated = vm.EvalContext(in.newContext(ctx), program, in.env)
+    } else {
+        evaluated = evaluator.EvalContext(in.newContext(ctx), program, in.env)
+    }
+    if errObj, ok := evaluated.(*object.Error); ok {
+        return nil, &RuntimeError{Err: errObj}
+    }
+
+    return ToGo(evaluated), nil
+}
+
+// a bug in the interpreter (or a panic in a registered Go function) shouldn't take the program embedding it down too
+// so it comes back as a RuntimeError with the internal error code instead
+func recoverInternal(err *error) {
+    if r := recover(); r != nil {
+        *err = &RuntimeError{Err: &object.Error{Code: diagnostic.Internal, Message: fmt.Sprintf("internal error: %v", r)}}
+    }
+}
+
+func (in *Interpreter) newContext(ctx context.Context) *evaluator.Context {
+    c := evaluator.NewContext(ctx, in.Limits)
+    c.Overflow = in.Overflow
+    if in.Stdout != nil {
+        c.Stdout = in.Stdout
+    }
+    return c
+}
+
+// binds name to the value converted to an object (see ToObject), a Go function becomes a builtin
+func (in *Interpreter) Set(name string, value any) error {
+    // going through RegisterFunc gives the builtin a name for its error messages
+    if reflect.ValueOf(value).Kind() == reflect.Func {
+        return in.RegisterFunc(name, value)
+    }
+
+    obj, err := ToObject(value)
+    if err != nil {
+        return fmt.Errorf("can't set %s: %w", name, err)
+    }
+    in.env.Set(name, obj)
+    return nil
+}
+
+// the value bound to name converted to Go, false if nothing is bound to it
+func (in *Interpreter) Get(name string) (any, bool) {
+    obj, ok := in.env.Get(name)
+    if !ok {
+        return nil, false
+    }
+    return ToGo(obj), true
+}
+
+// calls the function bound to fnName with the arguments converted to objects, the result is converted back to Go
+func (in *Interpreter) Call(fnName string, args ...any) (any, error) {
+    return in.CallContext(context.Background(), fnName, args...)
+}
+
+// Call that stops with a RuntimeError (of kind object.LimitError) once ctx is done
+func (in *Interpreter) CallContext(ctx context.Context, fnName string, args ...any) (result any, err error) {
+    defer recoverInternal(&err)
+
+    fn, ok := in.env.Get(fnName)
+    if !ok {
+        // the builtins aren't in the environment, same as when the program looks a name up
+        builtin, isBuiltin := evaluator.LookupBuiltin(fnName)
+        if !isBuiltin {
+            return nil, fmt.Errorf("%s is not defined", fnName)
+        }
+        fn = builtin
+    }
+    switch fn.(type) {
+    case *object.Function, *object.Closure, *object.Builtin:
+    default:
+        return nil, fmt.Errorf("%s is not a function: %s", fnName, fn.Type())
+    }
+
+    objects := make([]object.Object, len(args))
+    for i, arg := range args {
+        obj, err := ToObject(arg)
+        if err != nil {
+            return nil, fmt.Errorf("argument %d to %s: %w", i+1, fnName, err)
+        }
+        objects[i] = obj
+    }
+
+    // a function the vm made can only be run by the vm
+    var applied object.Object
+    if _, isClosure := fn.(*object.Closure); isClosure || in.UseVM {
+        applied = vm.Call(in.newContext(ctx), in.env, fn, objects)
+    } else {
+        applied = evaluator.ApplyContext(in.newContext(ctx), fn, objects)
+    }
+    if errObj, ok := applied.(*object.Error); ok {
+        return nil, &RuntimeError{Err: errObj}
+    }
+
+    return ToGo(applied), nil
+}
+
+// makes a Go function callable from skibidi as name, see FuncToBuiltin for what the function can look like
+func (in *Interpreter) RegisterFunc(name string, fn any) error {
+    builtin, err := FuncToBuiltin(name, fn)
+    if err != nil {
+        return err
+    }
+    in.env.Set(name, builtin)
+    return nil
+}
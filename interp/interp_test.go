@@ -0,0 +1,444 @@
+package interp
+
+import (
+    "context"
+    "errors"
+    "math"
+    "math/big"
+    "reflect"
+    "skibidi/diagnostic"
+    "skibidi/evaluator"
+    "skibidi/object"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestEvalKeepsState(t *testing.T) {
+    in := New()
+
+    if _, err := in.Eval("let x = 2;"); err != nil {
+        t.Fatalf("unexpected error: %s", err)
+    }
+    result, err := in.Eval("x * 21")
+    if err != nil {
+        t.Fatalf("unexpected error: %s", err)
+    }
+    if result != int64(42) {
+        t.Errorf("wrong result, want: 42, got: %#v", result)
+    }
+}
+
+func TestEvalErrors(t *testing.T) {
+    in := New()
+
+    _, err := in.Eval("let = 1; let y = (1;")
+    var parseErr *ParseError
+    if !errors.As(err, &parseErr) {
+        t.Fatalf("expected a ParseError, got: %#v", err)
+    }
+    if len(parseErr.Diagnostics) != 2 {
+        t.Errorf("expected 2 diagnostics, got: %q", parseErr.Diagnostics)
+    }
+
+    _, err = in.Eval("let f = fn() { missing }; f()")
+    var runtimeErr *RuntimeError
+    if !errors.As(err, &runtimeErr) {
+        t.Fatalf("expected a RuntimeError, got: %#v", err)
+    }
+    if runtimeErr.Err.Code != diagnostic.UnknownIdentifier || err.Error() != "1:16: identifier not found: missing" {
+        t.Errorf("wrong runtime error, got: %q (%s)", err, runtimeErr.Err.Code)
+    }
+}
+
+func TestSetAndGet(t *testing.T) {
+    in := New()
+
+    values := map[string]any{
+        "i":    7,
+        "u":    uint8(200),
+        "f":    float32(1.5),
+        "s":    "hi",
+        "b":    true,
+        "n":    nil,
+        "arr":  []int{1, 2, 3},
+        "hash": map[string]any{"a": 1, "b": []string{"x"}},
+    }
+    for name, value := range values {
+        if err := in.Set(name, value); err != nil {
+            t.Fatalf("can't set %s: %s", name, err)
+        }
+    }
+
+    expected := map[string]any{
+        "i":    int64(7),
+        "u":    int64(200),
+        "f":    float64(1.5),
+        "s":    "hi",
+        "b":    true,
+        "n":    nil,
+        "arr":  []any{int64(1), int64(2), int64(3)},
+        "hash": map[any]any{"a": int64(1), "b": []any{"x"}},
+    }
+    for name, want := range expected {
+        got, ok := in.Get(name)
+        if !ok {
+            t.Errorf("%s isn't set", name)
+            continue
+        }
+        if !reflect.DeepEqual(got, want) {
+            t.Errorf("wrong value for %s, want: %#v, got: %#v", name, want, got)
+        }
+    }
+
+    // the values are usable from the program
+    result, err := in.Eval(`if (b) { len(arr) + hash["a"] + i } else { 0 }`)
+    if err != nil || result != int64(11) {
+        t.Errorf("wrong result, want: 11, got: %#v (%v)", result, err)
+    }
+
+    if _, ok := in.Get("missing"); ok {
+        t.Errorf("expected missing not to be set")
+    }
+
+    if err := in.Set("ch", make(chan int)); err == nil {
+        t.Errorf("expected an error for a channel")
+    }
+
+    if err := in.Set("inf", math.Inf(1)); err == nil {
+        t.Errorf("expected an error for an infinite float")
+    }
+}
+
+// the tests of Call and the limits run against both backends
+var backends = []bool{false, true}
+
+func TestCall(t *testing.T) {
+    for _, useVM := range backends {
+        testCall(t, useVM)
+    }
+}
+
+func testCall(t *testing.T, useVM bool) {
+    in := New()
+    in.UseVM = useVM
+    if _, err := in.Eval("let add = fn(a, b = 10) { a + b }; let greet = fn(name) { \"hi \" + name };"); err != nil {
+        t.Fatalf("unexpected error: %s", err)
+    }
+
+    tests := []struct {
+        fn          string
+        args        []any
+        expected    any
+    }{
+        {"add", []any{1, 2}, int64(3)},
+        {"add", []any{1}, int64(11)},
+        {"add", []any{1.5, 2}, 3.5},
+        {"greet", []any{"bob"}, "hi bob"},
+        {"len", []any{[]string{"a", "b"}}, int64(2)},
+    }
+
+    for _, tt := range tests {
+        result, err := in.Call(tt.fn, tt.args...)
+        if err != nil {
+            t.Errorf("unexpected error calling %s: %s", tt.fn, err)
+            continue
+        }
+        if !reflect.DeepEqual(result, tt.expected) {
+            t.Errorf("wrong result for %s%v, want: %#v, got: %#v", tt.fn, tt.args, tt.expected, result)
+        }
+    }
+
+    errorTests := []struct {
+        fn          string
+        args        []any
+        expected    string
+    }{
+        {"missing", nil, "missing is not defined"},
+        {"add", []any{1, 2, 3}, "wrong number of arguments to `add`. got=3, want=1 to 2"},
+        {"add", []any{1, "a"}, "type mismatch: INTEGER + STRING"},
+    }
+
+    for _, tt := range errorTests {
+        _, err := in.Call(tt.fn, tt.args...)
+        if err == nil || !strings.Contains(err.Error(), tt.expected) {
+            t.Errorf("wrong error for %s%v, want: %q, got: %v", tt.fn, tt.args, tt.expected, err)
+        }
+    }
+
+    in.Set("x", 1)
+    if _, err := in.Call("x"); err == nil || err.Error() != "x is not a function: INTEGER" {
+        t.Errorf("wrong error for calling a non function, got: %v", err)
+    }
+}
+
+func TestRegisterFunc(t *testing.T) {
+    in := New()
+
+    funcs := map[string]any{
+        "double":   func(n int) int { return n * 2 },
+        "join":     func(sep string, parts ...string) string { return strings.Join(parts, sep) },
+        "half":     func(f float64) float64 { return f / 2 },
+        "sum":      func(nums []int64) (total int64) { for _, n := range nums { total += n }; return },
+        "keys":     func(m map[string]int) int { return len(m) },
+        "check":    func(n int) (bool, error) { if n < 0 { return false, errors.New("negative") }; return true, nil },
+        "noop":     func() {},
+        "describe": func(v any) string { return reflect.TypeOf(v).String() },
+        "raw":      func(obj object.Object) string { return string(obj.Type()) },
+    }
+    for name, fn := range funcs {
+        if err := in.RegisterFunc(name, fn); err != nil {
+            t.Fatalf("can't register %s: %s", name, err)
+        }
+    }
+
+    tests := []struct {
+        input       string
+        expected    any
+    }{
+        {"double(21)", int64(42)},
+        {`join("-", "a", "b", "c")`, "a-b-c"},
+        {`join(",")`, ""},
+        {"half(3)", 1.5},
+        {"sum([1, 2, 3])", int64(6)},
+        {`keys({"a": 1, "b": 2})`, int64(2)},
+        {"check(1)", true},
+        {"noop()", nil},
+        {"describe([1])", "[]interface {}"},
+        {"raw(fn() {})", "FUNCTION"},
+        // a Go function can be passed around like any other function
+        {"let f = double; f(2)", int64(4)},
+    }
+
+    for _, tt := range tests {
+        result, err := in.Eval(tt.input)
+        if err != nil {
+            t.Errorf("unexpected error for %q: %s", tt.input, err)
+            continue
+        }
+        if !reflect.DeepEqual(result, tt.expected) {
+            t.Errorf("wrong result for %q, want: %#v, got: %#v", tt.input, tt.expected, result)
+        }
+    }
+
+    errorTests := []struct {
+        input       string
+        code        diagnostic.Code
+        expected    string
+    }{
+        {"double()", diagnostic.WrongArgumentCount, "wrong number of arguments to `double`. got=0, want=1"},
+        {`join()`, diagnostic.WrongArgumentCount, "wrong number of arguments to `join`. got=0, want=at least 1"},
+        {`double("a")`, diagnostic.InvalidArgument, "argument 1 to `double` not supported: want int, got STRING"},
+        {"double(1.5)", diagnostic.InvalidArgument, "argument 1 to `double` not supported: want int, got FLOAT"},
+        {"check(-1)", diagnostic.HostError, "negative"},
+    }
+
+    for _, tt := range errorTests {
+        _, err := in.Eval(tt.input)
+        var runtimeErr *RuntimeError
+        if !errors.As(err, &runtimeErr) {
+            t.Errorf("expected a RuntimeError for %q, got: %v", tt.input, err)
+            continue
+        }
+        if runtimeErr.Err.Code != tt.code || runtimeErr.Err.Message != tt.expected {
+            t.Errorf("wrong error for %q, want: %s %q, got: %s %q", tt.input, tt.code, tt.expected, runtimeErr.Err.Code, runtimeErr.Err.Message)
+        }
+    }
+
+    if err := in.RegisterFunc("bad", 5); err == nil {
+        t.Errorf("expected an error registering something that isn't a function")
+    }
+    if err := in.RegisterFunc("bad", func() (int, int) { return 1, 2 }); err == nil {
+        t.Errorf("expected an error for a function with two results")
+    }
+}
+
+func TestLimits(t *testing.T) {
+    for _, useVM := range backends {
+        testLimits(t, useVM)
+    }
+}
+
+func testLimits(t *testing.T, useVM bool) {
+    in := New()
+    in.UseVM = useVM
+    in.Limits = evaluator.Limits{MaxSteps: 10000}
+
+    if _, err := in.Eval("let spin = fn() { while (true) {} };"); err != nil {
+        t.Fatalf("unexpected error: %s", err)
+    }
+
+    _, err := in.Eval("spin()")
+    var runtimeErr *RuntimeError
+    if !errors.As(err, &runtimeErr) || runtimeErr.Err.Kind != object.LimitError || runtimeErr.Err.Code != diagnostic.StepLimitExceeded {
+        t.Errorf("expected a step limit error, got: %v", err)
+    }
+
+    // every call starts counting from zero again
+    if result, err := in.Eval("1 + 1"); err != nil || result != int64(2) {
+        t.Errorf("unexpected result after hitting a limit: %v, %v", result, err)
+    }
+
+    in.Limits = evaluator.Limits{}
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+    defer cancel()
+    _, err = in.CallContext(ctx, "spin")
+    if !errors.As(err, &runtimeErr) || runtimeErr.Err.Code != diagnostic.Cancelled {
+        t.Errorf("expected the call to be cancelled, got: %v", err)
+    }
+}
+
+func TestStdout(t *testing.T) {
+    for _, useVM := range backends {
+        var out strings.Builder
+        in := New()
+        in.UseVM = useVM
+        in.Stdout = &out
+
+        if _, err := in.Eval(`let greet = fn(name) { puts("hi " + name) }; greet("a");`); err != nil {
+            t.Fatalf("unexpected error: %s", err)
+        }
+        if _, err := in.Call("greet", "b"); err != nil {
+            t.Fatalf("unexpected error: %s", err)
+        }
+        if out.String() != "hi a\nhi b\n" {
+            t.Errorf("wrong output (vm: %t), want: %q, got: %q", useVM, "hi a\nhi b\n", out.String())
+        }
+    }
+}
+
+func TestBigIntegers(t *testing.T) {
+    in := New()
+
+    // too big for an int64 but not for a uint64
+    if err := in.Set("big", uint64(1<<63)); err != nil {
+        t.Fatalf("unexpected error: %s", err)
+    }
+    if err := in.RegisterFunc("digits", func(n *big.Int) int { return len(n.String()) }); err != nil {
+        t.Fatalf("unexpected error: %s", err)
+    }
+    if err := in.RegisterFunc("small", func(n int64) int64 { return n }); err != nil {
+        t.Fatalf("unexpected error: %s", err)
+    }
+
+    result, err := in.Eval("big * 2")
+    want, _ := new(big.Int).SetString("18446744073709551616", 10)
+    if n, ok := result.(*big.Int); err != nil || !ok || n.Cmp(want) != 0 {
+        t.Errorf("wrong result, want: %s, got: %#v (%v)", want, result, err)
+    }
+
+    // and back to an int64 once it fits again
+    if result, err := in.Eval("big - 1"); err != nil || result != int64(9223372036854775807) {
+        t.Errorf("wrong result, want: 9223372036854775807, got: %#v (%v)", result, err)
+    }
+
+    if result, err := in.Eval("digits(big * big)"); err != nil || result != int64(38) {
+        t.Errorf("wrong result, want: 38, got: %#v (%v)", result, err)
+    }
+
+    if _, err := in.Eval("small(big)"); err == nil || !strings.Contains(err.Error(), "9223372036854775808 doesn't fit in int64") {
+        t.Errorf("expected an error for an integer that doesn't fit, got: %v", err)
+    }
+}
+
+func TestCyclicValues(t *testing.T) {
+    in := New()
+
+    result, err := in.Eval("let a = [1, 2]; a[0] = a; a")
+    if err != nil {
+        t.Fatalf("unexpected error: %s", err)
+    }
+    elements, ok := result.([]any)
+    if !ok || len(elements) != 2 || elements[1] != int64(2) {
+        t.Fatalf("wrong result, got: %#v", result)
+    }
+    // where the array repeats it's handed back as the object itself, not converted again
+    if inner, ok := elements[0].(*object.Array); !ok || inner.Inspect() != "[[...], 2]" {
+        t.Errorf("expected the array object where it repeats, got: %#v", elements[0])
+    }
+
+    result, err = in.Eval("let h = {}; h[\"self\"] = h; h")
+    if err != nil {
+        t.Fatalf("unexpected error: %s", err)
+    }
+    pairs, ok := result.(map[any]any)
+    if _, isHash := pairs["self"].(*object.Hash); !ok || !isHash {
+        t.Errorf("expected the hash object where it repeats, got: %#v", result)
+    }
+}
+
+func TestEmptyFunctionResult(t *testing.T) {
+    in := New()
+
+    // a function with an empty body gives back null, so the builtins get a value they can work with
+    _, err := in.Eval("let g = fn() {}; len(g())")
+    var runtimeErr *RuntimeError
+    if !errors.As(err, &runtimeErr) || runtimeErr.Err.Code != diagnostic.InvalidArgument {
+        t.Fatalf("expected an invalid argument error, got: %#v", err)
+    }
+
+    result, err := in.Eval("type(g())")
+    if err != nil {
+        t.Fatalf("unexpected error: %s", err)
+    }
+    if result != "NULL" {
+        t.Errorf("wrong result, want: NULL, got: %#v", result)
+    }
+
+    result, err = in.Call("g")
+    if err != nil || result != nil {
+        t.Errorf("wrong result, want: nil, got: %#v (%v)", result, err)
+    }
+}
+
+func TestPanicsAreErrors(t *testing.T) {
+    in := New()
+    in.RegisterFunc("boom", func() int { panic("boom") })
+
+    for _, run := range []func() (any, error){
+        func() (any, error) { return in.Eval("1 + boom()") },
+        func() (any, error) { return in.Call("boom") },
+    } {
+        _, err := run()
+        var runtimeErr *RuntimeError
+        if !errors.As(err, &runtimeErr) {
+            t.Fatalf("expected a RuntimeError, got: %#v", err)
+        }
+        if runtimeErr.Err.Code != diagnostic.Internal || !strings.Contains(err.Error(), "boom") {
+            t.Errorf("wrong runtime error, got: %q (%s)", err, runtimeErr.Err.Code)
+        }
+    }
+}
+
+func TestVM(t *testing.T) {
+    in := New()
+    in.UseVM = true
+    in.RegisterFunc("double", func(n int) int { return n * 2 })
+
+    // the vm's globals are the interpreter's, so they're kept between calls and can be set and read from Go
+    if _, err := in.Eval("let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };"); err != nil {
+        t.Fatalf("unexpected error: %s", err)
+    }
+    in.Set("n", 20)
+    result, err := in.Eval("let x = double(fib(n)); x")
+    if err != nil || result != int64(13530) {
+        t.Errorf("wrong result, want: 13530, got: %#v (%v)", result, err)
+    }
+    if x, ok := in.Get("x"); !ok || x != int64(13530) {
+        t.Errorf("wrong value for x, got: %#v", x)
+    }
+
+    // errors have the same stack trace as the evaluator gives them
+    _, err = in.Eval("let f = fn() { missing };\nlet g = fn() { f() };\ng()")
+    expected := "ERROR: 1:16: identifier not found: missing\nstack trace (most recent call first):\n    in f, called at 2:17\n    in g, called at 3:2"
+    var runtimeErr *RuntimeError
+    if !errors.As(err, &runtimeErr) || runtimeErr.Err.Traceback() != expected {
+        t.Errorf("wrong error, want: %q, got: %v", expected, err)
+    }
+
+    // a function the vm made still runs after it's switched off again
+    in.UseVM = false
+    if result, err := in.Call("fib", 10); err != nil || result != int64(55) {
+        t.Errorf("wrong result, want: 55, got: %#v (%v)", result, err)
+    }
+}
@@ -2,26 +2,90 @@
 // the purpose of a lexer is to convert user written skibidi code into tokens
 package lexer
 
-import "skibidi/token"
+import (
+    "fmt"
+    "skibidi/diagnostic"
+    "skibidi/token"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+)
 
 type Lexer struct {
     input           string
+    filename        string // only used to fill in token positions, can be empty
     position        int // current pos in input (points to current char)
     readPosition    int // current reading position in input (points to after current char)
     ch              byte // current char under examination
+    line            int // line and column of the current char
+    column          int
+    keepComments    bool // comments are normally skipped like whitespace
+    unterminated    bool // the input ended inside a string or block comment
+    errors          []diagnostic.Diagnostic // mistakes inside a token that still let it be read, like a bad escape in a string
 }
 
 func New(input string) *Lexer {
-    l := &Lexer{input: input}
+    return NewFile("", input)
+}
+
+// same as New, but every token position will also carry the name of the file the input came from
+func NewFile(filename string, input string) *Lexer {
+    l := &Lexer{input: input, filename: filename, line: 1}
     l.readChar()
+    l.skipShebang()
     return l
 }
 
+// a script can start with a '#!/usr/bin/env skibidi' line so it can be run directly on unix
+// it's only allowed on the very first line, everywhere else '#' is still illegal
+func (l *Lexer) skipShebang() {
+    if l.ch != '#' || l.peekChar() != '!' {
+        return
+    }
+    for l.ch != '\n' && l.ch != 0 {
+        l.readChar()
+    }
+}
+
+// true once the input has ended in the middle of a string or block comment, which more input could still finish
+// (as opposed to a string with a bad escape in it, which is wrong no matter what comes after)
+func (l *Lexer) Unterminated() bool {
+    return l.unterminated
+}
+
+// the mistakes found so far that didn't stop a token from being read, in the order they were found
+// the token they belong to is handed out as if it were fine, so the parser carries on past it
+func (l *Lexer) Errors() []diagnostic.Diagnostic {
+    return l.errors
+}
+
+// makes NextToken hand out comments as COMMENT tokens instead of skipping them
+// the parser doesn't care about comments, this is for tools like a formatter that need to put them back
+func (l *Lexer) KeepComments() {
+    l.keepComments = true
+}
+
 func (l *Lexer) NextToken() token.Token {
     var tok token.Token
 
     l.skipWhitespace()
 
+    start := l.currentPosition()
+
+    // comments count as whitespace unless someone asked for them
+    for l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*') {
+        text, ok := l.readComment()
+        if !ok {
+            return token.Token{Type: token.ILLEGAL, Literal: text, Pos: start, End: l.currentPosition()}
+        }
+        if l.keepComments {
+            return token.Token{Type: token.COMMENT, Literal: text, Pos: start, End: l.currentPosition()}
+        }
+
+        l.skipWhitespace()
+        start = l.currentPosition()
+    }
+
     switch l.ch{
     case '=':
         if l.peekChar() == '=' {
@@ -35,6 +99,9 @@ func (l *Lexer) NextToken() token.Token {
     case ';':
         tok = newToken(token.SEMICOLON, l.ch)
         l.readChar()
+    case ':':
+        tok = newToken(token.COLON, l.ch)
+        l.readChar()
     case '(':
         tok = newToken(token.LPAREN, l.ch)
         l.readChar()
@@ -47,14 +114,32 @@ func (l *Lexer) NextToken() token.Token {
     case '}':
         tok = newToken(token.RBRACE, l.ch)
         l.readChar()
+    case '[':
+        tok = newToken(token.LBRACKET, l.ch)
+        l.readChar()
+    case ']':
+        tok = newToken(token.RBRACKET, l.ch)
+        l.readChar()
     case ',':
         tok = newToken(token.COMMA, l.ch)
         l.readChar()
     case '+':
-        tok = newToken(token.PLUS, l.ch)
+        if l.peekChar() == '=' {
+            ch := l.ch
+            l.readChar()
+            tok = token.Token{Type: token.PLUS_ASSIGN, Literal: string(ch) + string(l.ch)}
+        } else {
+            tok = newToken(token.PLUS, l.ch)
+        }
         l.readChar()
     case '-':
-        tok = newToken(token.MINUS, l.ch)
+        if l.peekChar() == '=' {
+            ch := l.ch
+            l.readChar()
+            tok = token.Token{Type: token.MINUS_ASSIGN, Literal: string(ch) + string(l.ch)}
+        } else {
+            tok = newToken(token.MINUS, l.ch)
+        }
         l.readChar()
     case '!':
         if l.peekChar() == '=' {
@@ -66,16 +151,80 @@ func (l *Lexer) NextToken() token.Token {
         }
         l.readChar()
     case '/':
-        tok = newToken(token.SLASH, l.ch)
+        if l.peekChar() == '=' {
+            ch := l.ch
+            l.readChar()
+            tok = token.Token{Type: token.SLASH_ASSIGN, Literal: string(ch) + string(l.ch)}
+        } else {
+            tok = newToken(token.SLASH, l.ch)
+        }
         l.readChar()
     case '*':
-        tok = newToken(token.ASTERISK, l.ch)
+        if l.peekChar() == '=' {
+            ch := l.ch
+            l.readChar()
+            tok = token.Token{Type: token.ASTERISK_ASSIGN, Literal: string(ch) + string(l.ch)}
+        } else {
+            tok = newToken(token.ASTERISK, l.ch)
+        }
         l.readChar()
     case '<':
-        tok = newToken(token.LT, l.ch)
+        if l.peekChar() == '=' {
+            ch := l.ch
+            l.readChar()
+            tok = token.Token{Type: token.LT_EQ, Literal: string(ch) + string(l.ch)}
+        } else {
+            tok = newToken(token.LT, l.ch)
+        }
         l.readChar()
     case '>':
-        tok = newToken(token.GT, l.ch)
+        if l.peekChar() == '=' {
+            ch := l.ch
+            l.readChar()
+            tok = token.Token{Type: token.GT_EQ, Literal: string(ch) + string(l.ch)}
+        } else {
+            tok = newToken(token.GT, l.ch)
+        }
+        l.readChar()
+    // a single & or | isn't an operator (yet), so they are only valid doubled up
+    case '&':
+        if l.peekChar() == '&' {
+            ch := l.ch
+            l.readChar()
+            tok = token.Token{Type: token.AND, Literal: string(ch) + string(l.ch)}
+        } else {
+            tok = newToken(token.ILLEGAL, l.ch)
+        }
+        l.readChar()
+    case '|':
+        if l.peekChar() == '|' {
+            ch := l.ch
+            l.readChar()
+            tok = token.Token{Type: token.OR, Literal: string(ch) + string(l.ch)}
+        } else {
+            tok = newToken(token.ILLEGAL, l.ch)
+        }
+        l.readChar()
+    case '.':
+        // a dot on its own isn't anything (yet), .5 is handled with the other numbers below
+        if l.peekChar() == '.' && l.readPosition+1 < len(l.input) && l.input[l.readPosition+1] == '.' {
+            l.readChar()
+            l.readChar()
+            tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+            l.readChar()
+        } else if isDigit(l.peekChar()) {
+            tok.Type, tok.Literal = l.readNumber()
+        } else {
+            tok = newToken(token.ILLEGAL, l.ch)
+            l.readChar()
+        }
+    case '"':
+        // an unterminated string is handed to the parser as an ILLEGAL token, a bad escape sequence only ends up in Errors()
+        if str, ok := l.readString(); ok {
+            tok = token.Token{Type: token.STRING, Literal: str}
+        } else {
+            tok = token.Token{Type: token.ILLEGAL, Literal: str}
+        }
         l.readChar()
     case 0:
         tok.Literal = ""
@@ -88,19 +237,31 @@ func (l *Lexer) NextToken() token.Token {
         if isLetter(l.ch) {
             tok.Literal = l.readIdentifier()
             tok.Type = token.LookupIdent(tok.Literal)
-            return tok
-        } else if isDigit(l.ch){
+        } else if isDigit(l.ch) {
             // should read the entirety of the number and assign it
-            tok.Literal = l.readDigits()
-            tok.Type = token.INT
+            tok.Type, tok.Literal = l.readNumber()
         } else {
             tok = newToken(token.ILLEGAL, l.ch)
+            l.readChar()
         }
     }
 
+    tok.Pos = start
+    tok.End = l.currentPosition()
+
     return tok
 }
 
+// the position of the char currently under examination
+func (l *Lexer) currentPosition() token.Position {
+    return token.Position{
+        Filename:   l.filename,
+        Offset:     l.position,
+        Line:       l.line,
+        Column:     l.column,
+    }
+}
+
 func (l *Lexer) peekChar() byte {
     if l.readPosition >= len(l.input){
         return 0
@@ -118,6 +279,43 @@ func (l *Lexer) skipWhitespace() {
     }
 }
 
+// reads a whole comment starting at the '/', the literal is the source text of the comment including the slashes and stars
+// a line comment runs up to the end of the line (the newline isn't part of it)
+// block comments can be nested, so /* a /* b */ c */ is one comment, which makes commenting out code that already has comments in it work
+// returns false if a block comment is never closed
+func (l *Lexer) readComment() (string, bool) {
+    position := l.position
+
+    if l.peekChar() == '/' {
+        for l.ch != '\n' && l.ch != 0 {
+            l.readChar()
+        }
+        return l.input[position:l.position], true
+    }
+
+    // skip the opening /*
+    l.readChar()
+    l.readChar()
+
+    depth := 1
+    for depth > 0 {
+        switch {
+        case l.ch == 0:
+            l.unterminated = true
+            return l.input[position:l.position], false
+        case l.ch == '/' && l.peekChar() == '*':
+            depth++
+            l.readChar()
+        case l.ch == '*' && l.peekChar() == '/':
+            depth--
+            l.readChar()
+        }
+        l.readChar()
+    }
+
+    return l.input[position:l.position], true
+}
+
 func (l *Lexer) readIdentifier() string {
     position := l.position
     for isLetter(l.ch){
@@ -134,6 +332,130 @@ func (l *Lexer) readDigits() string {
     return l.input[position:l.position]
 }
 
+// reads an integer or a float, a number with a fraction or an exponent is a float (3.14, .5, 1e-9, 2.5E+3)
+// the fraction needs a digit after the dot, so 1. is the integer 1 followed by an illegal '.'
+// an exponent without any digits (1e, 1e+) is illegal
+func (l *Lexer) readNumber() (token.TokenType, string) {
+    position := l.position
+    var tokenType token.TokenType = token.INT
+
+    l.readDigits()
+
+    if l.ch == '.' && isDigit(l.peekChar()) {
+        tokenType = token.FLOAT
+        l.readChar()
+        l.readDigits()
+    }
+
+    if l.ch == 'e' || l.ch == 'E' {
+        tokenType = token.FLOAT
+        l.readChar()
+        if l.ch == '+' || l.ch == '-' {
+            l.readChar()
+        }
+        if l.readDigits() == "" {
+            tokenType = token.ILLEGAL
+        }
+    }
+
+    return tokenType, l.input[position:l.position]
+}
+
+// reads everything up to the closing quote, resolving escape sequences along the way
+// the literal stored in the token is the actual string value, not the raw source text
+// returns false if the string is never closed, an escape we don't know about is kept as it was written and reported in Errors()
+func (l *Lexer) readString() (string, bool) {
+    var out strings.Builder
+
+    for {
+        l.readChar()
+        switch l.ch {
+        case '"':
+            return out.String(), true
+        case 0:
+            l.unterminated = true
+            return out.String(), false
+        case '\\':
+            start := l.currentPosition()
+            l.readChar()
+            switch l.ch {
+            case 0:
+                l.unterminated = true
+                return out.String(), false
+            case 'n':
+                out.WriteByte('\n')
+            case 't':
+                out.WriteByte('\t')
+            case '"':
+                out.WriteByte('"')
+            case '\\':
+                out.WriteByte('\\')
+            case 'u':
+                r, ok := l.readUnicodeEscape()
+                if !ok {
+                    out.WriteString(l.escapeError(start))
+                    continue
+                }
+                out.WriteRune(r)
+            default:
+                out.WriteString(l.escapeError(start))
+            }
+        default:
+            out.WriteByte(l.ch)
+        }
+    }
+}
+
+// records the escape that starts at start and ends with the current char as invalid, and gives back its source text
+func (l *Lexer) escapeError(start token.Position) string {
+    // the char after the backslash might be the first byte of a longer utf-8 character
+    for isContinuationByte(l.peekChar()) {
+        l.readChar()
+    }
+
+    end := l.currentPosition()
+    end.Offset = l.readPosition
+    end.Column++
+
+    text := l.input[start.Offset:l.readPosition]
+    l.errors = append(l.errors, diagnostic.Diagnostic{
+        Severity:   diagnostic.Error,
+        Code:       diagnostic.InvalidEscape,
+        Message:    fmt.Sprintf("invalid escape sequence %s", text),
+        Span:       diagnostic.Span{Start: start, End: end},
+    })
+    return text
+}
+
+// reads the {...} part of a \u{...} escape, the braces hold a hex code point (eg \u{1F480})
+func (l *Lexer) readUnicodeEscape() (rune, bool) {
+    if l.peekChar() != '{' {
+        return 0, false
+    }
+    l.readChar()
+
+    position := l.readPosition
+    for isHexDigit(l.peekChar()) {
+        l.readChar()
+    }
+    digits := l.input[position:l.readPosition]
+
+    if l.peekChar() != '}' || len(digits) == 0 || len(digits) > 6 {
+        return 0, false
+    }
+    l.readChar()
+
+    value, err := strconv.ParseUint(digits, 16, 32)
+    if err != nil || !utf8.ValidRune(rune(value)) {
+        return 0, false
+    }
+    return rune(value), true
+}
+
+func isHexDigit(ch byte) bool {
+    return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
 func isLetter(ch byte) bool {
     return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
 }
@@ -149,6 +471,17 @@ func newToken(tokenType token.TokenType, ch byte) token.Token {
 // just moves the curr and next character along
 // essentially a method for a Lexer, which is the receiver type
 func (l *Lexer) readChar() {
+    // already sitting on EOF, stay there so repeated calls keep giving back the same position
+    if l.readPosition > len(l.input) {
+        return
+    }
+
+    // moving past a newline starts a new line
+    if l.ch == '\n' {
+        l.line++
+        l.column = 0
+    }
+
     if l.readPosition >= len(l.input){
         // 0 is the ascii code for the "NUL" character, signifying EOF
         l.ch = 0
@@ -157,4 +490,13 @@ func (l *Lexer) readChar() {
     }
     l.position = l.readPosition
     l.readPosition++
+
+    // utf-8 continuation bytes belong to the character before them, so they don't get a column of their own
+    if !isContinuationByte(l.ch) {
+        l.column++
+    }
+}
+
+func isContinuationByte(ch byte) bool {
+    return ch&0xC0 == 0x80
 }
@@ -20,7 +20,7 @@ func TestNextToken(t *testing.T) {
         x + y;
         };
         let result = add(five, ten);
-        !-/*5;
+        !-/ *5; // "/*" would start a comment
         5 < 10 > 5;
         
         if (5 < 10) {
@@ -31,6 +31,13 @@ func TestNextToken(t *testing.T) {
 
         10 == 10;
         10 != 9;
+        "foobar"
+        "foo bar"
+        "tab\there \"quoted\" back\\slash\n"
+        "\u{48}\u{1F480}"
+        [1, 2];
+        {"foo": "bar"}
+        1 <= 2 >= 3 && true || false
         `
 
     tests := []struct {
@@ -110,6 +117,30 @@ func TestNextToken(t *testing.T) {
         {token.NOT_EQ, "!="},
         {token.INT, "9"},
         {token.SEMICOLON, ";"},
+        {token.STRING, "foobar"},
+        {token.STRING, "foo bar"},
+        {token.STRING, "tab\there \"quoted\" back\\slash\n"},
+        {token.STRING, "H\U0001F480"},
+        {token.LBRACKET, "["},
+        {token.INT, "1"},
+        {token.COMMA, ","},
+        {token.INT, "2"},
+        {token.RBRACKET, "]"},
+        {token.SEMICOLON, ";"},
+        {token.LBRACE, "{"},
+        {token.STRING, "foo"},
+        {token.COLON, ":"},
+        {token.STRING, "bar"},
+        {token.RBRACE, "}"},
+        {token.INT, "1"},
+        {token.LT_EQ, "<="},
+        {token.INT, "2"},
+        {token.GT_EQ, ">="},
+        {token.INT, "3"},
+        {token.AND, "&&"},
+        {token.TRUE, "true"},
+        {token.OR, "||"},
+        {token.FALSE, "false"},
         {token.EOF, ""},
     }
 
@@ -132,3 +163,227 @@ func TestNextToken(t *testing.T) {
 
 }
 
+
+func TestIllegalStrings(t *testing.T) {
+    tests := []struct {
+        input           string
+        unterminated    bool // only when the input ran out, more of it could still make the string fine
+    }{
+        {`"never closed`, true},
+        {`"never closed \`, true},
+        {`"never closed \q`, true},
+    }
+
+    for _, tt := range tests {
+        l := New(tt.input)
+        tok := l.NextToken()
+        if tok.Type != token.ILLEGAL {
+            t.Errorf("input %q - token type wrong. expected: %q, got: %q", tt.input, token.ILLEGAL, tok.Type)
+        }
+        if l.Unterminated() != tt.unterminated {
+            t.Errorf("input %q - expected Unterminated() to be %t", tt.input, tt.unterminated)
+        }
+    }
+}
+
+// a bad escape is reported, but the string is still read up to its closing quote so lexing carries on after it
+func TestInvalidEscapes(t *testing.T) {
+    tests := []struct {
+        input           string
+        expectedLiteral string
+        expectedError   string
+        expectedEnd     string
+    }{
+        {`"bad \q escape"`, `bad \q escape`, `1:6: invalid escape sequence \q`, "1:8"},
+        {`"\u{}"`, `\u{}`, `1:2: invalid escape sequence \u{`, "1:5"},
+        {`"\u{110000}"`, `\u{110000}`, `1:2: invalid escape sequence \u{110000}`, "1:12"},
+        {`"\u41"`, `\u41`, `1:2: invalid escape sequence \u`, "1:4"},
+        {`"é\é"`, `é\é`, `1:3: invalid escape sequence \é`, "1:5"},
+    }
+
+    for _, tt := range tests {
+        l := New(tt.input + " x")
+        tok := l.NextToken()
+        if tok.Type != token.STRING || tok.Literal != tt.expectedLiteral {
+            t.Errorf("input %q - wrong token. expected: %q %q, got: %q %q", tt.input, token.STRING, tt.expectedLiteral, tok.Type, tok.Literal)
+        }
+        if tok := l.NextToken(); tok.Type != token.IDENT {
+            t.Errorf("input %q - lexing didn't carry on after the string, got: %q %q", tt.input, tok.Type, tok.Literal)
+        }
+
+        if len(l.Errors()) != 1 {
+            t.Errorf("input %q - expected 1 error, got %d", tt.input, len(l.Errors()))
+            continue
+        }
+        err := l.Errors()[0]
+        if err.Error() != tt.expectedError || err.Span.End.String() != tt.expectedEnd {
+            t.Errorf("input %q - wrong error. expected: %s (to %s), got: %s (to %s)", tt.input, tt.expectedError, tt.expectedEnd, err.Error(), err.Span.End)
+        }
+        if l.Unterminated() {
+            t.Errorf("input %q - expected Unterminated() to be false", tt.input)
+        }
+    }
+}
+
+func TestTokenPositions(t *testing.T) {
+    input := "let x = 5;\n  \"héllo\" @\nfoo"
+
+    tests := []struct {
+        expectedType    token.TokenType
+        expectedPos     token.Position
+        expectedEnd     token.Position
+    }{
+        {token.LET, token.Position{Filename: "test.skb", Offset: 0, Line: 1, Column: 1}, token.Position{Filename: "test.skb", Offset: 3, Line: 1, Column: 4}},
+        {token.IDENT, token.Position{Filename: "test.skb", Offset: 4, Line: 1, Column: 5}, token.Position{Filename: "test.skb", Offset: 5, Line: 1, Column: 6}},
+        {token.ASSIGN, token.Position{Filename: "test.skb", Offset: 6, Line: 1, Column: 7}, token.Position{Filename: "test.skb", Offset: 7, Line: 1, Column: 8}},
+        {token.INT, token.Position{Filename: "test.skb", Offset: 8, Line: 1, Column: 9}, token.Position{Filename: "test.skb", Offset: 9, Line: 1, Column: 10}},
+        {token.SEMICOLON, token.Position{Filename: "test.skb", Offset: 9, Line: 1, Column: 10}, token.Position{Filename: "test.skb", Offset: 10, Line: 1, Column: 11}},
+        // the é is two bytes but only one column
+        {token.STRING, token.Position{Filename: "test.skb", Offset: 13, Line: 2, Column: 3}, token.Position{Filename: "test.skb", Offset: 21, Line: 2, Column: 10}},
+        {token.ILLEGAL, token.Position{Filename: "test.skb", Offset: 22, Line: 2, Column: 11}, token.Position{Filename: "test.skb", Offset: 23, Line: 2, Column: 12}},
+        {token.IDENT, token.Position{Filename: "test.skb", Offset: 24, Line: 3, Column: 1}, token.Position{Filename: "test.skb", Offset: 27, Line: 3, Column: 4}},
+        {token.EOF, token.Position{Filename: "test.skb", Offset: 27, Line: 3, Column: 4}, token.Position{Filename: "test.skb", Offset: 27, Line: 3, Column: 4}},
+        {token.EOF, token.Position{Filename: "test.skb", Offset: 27, Line: 3, Column: 4}, token.Position{Filename: "test.skb", Offset: 27, Line: 3, Column: 4}},
+    }
+
+    l := NewFile("test.skb", input)
+
+    for i, tt := range tests {
+        tok := l.NextToken()
+        if tok.Type != tt.expectedType {
+            t.Fatalf("tests[%d] - token type wrong. expected: %q, got: %q", i, tt.expectedType, tok.Type)
+        }
+
+        if tok.Pos != tt.expectedPos {
+            t.Errorf("tests[%d] - token position wrong. expected: %+v, got: %+v", i, tt.expectedPos, tok.Pos)
+        }
+
+        if tok.End != tt.expectedEnd {
+            t.Errorf("tests[%d] - token end wrong. expected: %+v, got: %+v", i, tt.expectedEnd, tok.End)
+        }
+    }
+}
+
+func TestShebangLine(t *testing.T) {
+    input := "#!/usr/bin/env skibidi run\nlet x = 1;"
+
+    l := New(input)
+
+    tok := l.NextToken()
+    if tok.Type != token.LET {
+        t.Fatalf("token type wrong. expected: %q, got: %q", token.LET, tok.Type)
+    }
+
+    if tok.Pos.Line != 2 || tok.Pos.Column != 1 {
+        t.Errorf("token position wrong. expected: 2:1, got: %s", tok.Pos)
+    }
+
+    // anywhere but the start, '#!' is not a shebang
+    l = New("1 #!")
+    l.NextToken()
+    if tok := l.NextToken(); tok.Type != token.ILLEGAL {
+        t.Errorf("token type wrong. expected: %q, got: %q", token.ILLEGAL, tok.Type)
+    }
+}
+
+func TestComments(t *testing.T) {
+    input := `// a line comment
+let x = 5; // after some code
+/* a block
+   comment */ x / 2;
+/* outer /* nested */ still a comment */ x`
+
+    tests := []struct {
+        expectedType    token.TokenType
+        expectedLiteral string
+    }{
+        {token.LET, "let"},
+        {token.IDENT, "x"},
+        {token.ASSIGN, "="},
+        {token.INT, "5"},
+        {token.SEMICOLON, ";"},
+        {token.IDENT, "x"},
+        {token.SLASH, "/"},
+        {token.INT, "2"},
+        {token.SEMICOLON, ";"},
+        {token.IDENT, "x"},
+        {token.EOF, ""},
+    }
+
+    l := New(input)
+    for i, tt := range tests {
+        tok := l.NextToken()
+        if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+            t.Fatalf("tests[%d] - wrong token. expected: %q %q, got: %q %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+        }
+    }
+}
+
+func TestKeepComments(t *testing.T) {
+    input := "// one\nx /* two /* three */ */\n"
+
+    tests := []struct {
+        expectedType    token.TokenType
+        expectedLiteral string
+        expectedPos     string
+    }{
+        {token.COMMENT, "// one", "1:1"},
+        {token.IDENT, "x", "2:1"},
+        {token.COMMENT, "/* two /* three */ */", "2:3"},
+        {token.EOF, "", "3:1"},
+    }
+
+    l := New(input)
+    l.KeepComments()
+    for i, tt := range tests {
+        tok := l.NextToken()
+        if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+            t.Fatalf("tests[%d] - wrong token. expected: %q %q, got: %q %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+        }
+        if tok.Pos.String() != tt.expectedPos {
+            t.Errorf("tests[%d] - wrong position. expected: %s, got: %s", i, tt.expectedPos, tok.Pos)
+        }
+    }
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+    l := New("1 /* never /* closed */")
+    l.NextToken()
+
+    tok := l.NextToken()
+    if tok.Type != token.ILLEGAL {
+        t.Fatalf("token type wrong. expected: %q, got: %q", token.ILLEGAL, tok.Type)
+    }
+    if !l.Unterminated() {
+        t.Errorf("expected the lexer to know the input ended inside the comment")
+    }
+    if tok := l.NextToken(); tok.Type != token.EOF {
+        t.Errorf("token type wrong. expected: %q, got: %q", token.EOF, tok.Type)
+    }
+}
+
+func TestNumbers(t *testing.T) {
+    tests := []struct {
+        input           string
+        expectedType    token.TokenType
+        expectedLiteral string
+    }{
+        {"42", token.INT, "42"},
+        {"3.14", token.FLOAT, "3.14"},
+        {".5", token.FLOAT, ".5"},
+        {"1e-9", token.FLOAT, "1e-9"},
+        {"2.5E+3", token.FLOAT, "2.5E+3"},
+        {"7e2", token.FLOAT, "7e2"},
+        {"1e", token.ILLEGAL, "1e"},
+        {"1e+", token.ILLEGAL, "1e+"},
+        // a dot needs a digit after it to be part of the number
+        {"1.", token.INT, "1"},
+    }
+
+    for _, tt := range tests {
+        tok := New(tt.input).NextToken()
+        if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+            t.Errorf("wrong token for %q. expected: %q %q, got: %q %q", tt.input, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+        }
+    }
+}
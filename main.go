@@ -1,18 +1,278 @@
 package main
 
 import (
+    "context"
     "fmt"
+    "io"
     "os"
     "os/user"
+    "skibidi/evaluator"
+    "skibidi/format"
+    "skibidi/lexer"
+    "skibidi/object"
+    "skibidi/parser"
     "skibidi/repl"
+    "skibidi/vm"
 )
 
+// exit codes, so build steps and cron jobs can tell what went wrong
+const (
+    exitOK = 0
+    exitNotFormatted = 1 // fmt -d found a file that isn't formatted
+    exitRuntimeError = 1
+    exitParseError = 2
+    exitUsageError = 64
+    exitInputError = 66 // the script couldn't be read
+)
+
+const usage = `usage:
+    skibidi                         start the REPL (or run stdin if it is piped in)
+    skibidi repl                    start the REPL
+    skibidi run <file> [args...]    run a script, use - to read it from stdin
+    skibidi <file> [args...]        same as run, this is what a shebang line ends up calling
+    skibidi -e <source> [args...]   run the given source and print its result
+    skibidi fmt [-w] [-d] [files]   format the files (or stdin) and print them, -w writes them back instead
+                                    and -d shows a diff, exiting with 1 if anything isn't formatted
+
+flags (before the command):
+    --checked                       integer overflow is an error instead of switching to a big integer
+    --no-color                      don't color the REPL, also turned off by setting NO_COLOR or when the output isn't a terminal
+    --vm                            run with the bytecode vm, which is faster than the default tree walking evaluator
+`
+
 func main() {
+    os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// everything the flags can change about how a program is run
+type options struct {
+    overflow    evaluator.Overflow
+    noColor     bool
+    vm          bool
+}
+
+func run(args []string, stdin *os.File, stdout io.Writer, stderr io.Writer) int {
+    var opts options
+    // only the flags before the command are ours, anything after a script's name belongs to the script
+    for len(args) > 0 && (args[0] == "--checked" || args[0] == "--no-color" || args[0] == "--vm") {
+        switch args[0] {
+        case "--checked":
+            opts.overflow = evaluator.ErrorOnOverflow
+        case "--no-color":
+            opts.noColor = true
+        case "--vm":
+            opts.vm = true
+        }
+        args = args[1:]
+    }
+
+    if len(args) == 0 {
+        if isTerminal(stdin) {
+            startRepl(stdin, stdout, opts)
+            return exitOK
+        }
+        return runReader("<stdin>", stdin, nil, opts, stdout, stderr)
+    }
+
+    switch args[0] {
+    case "repl":
+        startRepl(stdin, stdout, opts)
+        return exitOK
+    case "run":
+        if len(args) < 2 {
+            fmt.Fprint(stderr, usage)
+            return exitUsageError
+        }
+        return runFile(args[1], args[2:], opts, stdin, stdout, stderr)
+    case "fmt":
+        return runFmt(args[1:], stdin, stdout, stderr)
+    case "-e":
+        if len(args) < 2 {
+            fmt.Fprint(stderr, usage)
+            return exitUsageError
+        }
+        return execute("<cmdline>", args[1], args[2:], true, opts, stdout, stderr)
+    case "-h", "--help", "help":
+        fmt.Fprint(stdout, usage)
+        return exitOK
+    default:
+        if len(args[0]) > 1 && args[0][0] == '-' {
+            fmt.Fprintf(stderr, "unknown flag: %s\n", args[0])
+            fmt.Fprint(stderr, usage)
+            return exitUsageError
+        }
+        return runFile(args[0], args[1:], opts, stdin, stdout, stderr)
+    }
+}
+
+func startRepl(in io.Reader, out io.Writer, opts options) {
     user, err := user.Current()
     if err != nil {
         panic(err)
     }
     fmt.Printf("Hello %s! This is the skibidi programming language!\n", user.Username)
     fmt.Printf("Feel free to type in commands:\n")
-    repl.Start(os.Stdin, os.Stdout)
+    repl.Start(in, out, repl.Options{Overflow: opts.overflow, VM: opts.vm, HistoryFile: repl.DefaultHistoryFile(), Color: useColor(out, opts)})
+}
+
+// colors are only for a person looking at a terminal, see https://no-color.org for NO_COLOR
+func useColor(out io.Writer, opts options) bool {
+    if opts.noColor || os.Getenv("NO_COLOR") != "" {
+        return false
+    }
+    f, ok := out.(*os.File)
+    return ok && isTerminal(f)
+}
+
+func runFile(path string, scriptArgs []string, opts options, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+    if path == "-" {
+        return runReader("<stdin>", stdin, scriptArgs, opts, stdout, stderr)
+    }
+
+    src, err := os.ReadFile(path)
+    if err != nil {
+        fmt.Fprintf(stderr, "skibidi: %s\n", err)
+        return exitInputError
+    }
+    return execute(path, string(src), scriptArgs, false, opts, stdout, stderr)
+}
+
+func runReader(name string, r io.Reader, scriptArgs []string, opts options, stdout io.Writer, stderr io.Writer) int {
+    src, err := io.ReadAll(r)
+    if err != nil {
+        fmt.Fprintf(stderr, "skibidi: %s\n", err)
+        return exitInputError
+    }
+    return execute(name, string(src), scriptArgs, false, opts, stdout, stderr)
+}
+
+// skibidi fmt, the flags come first and then the files
+func runFmt(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+    var write, diff bool
+    for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+        switch args[0] {
+        case "-w":
+            write = true
+        case "-d":
+            diff = true
+        default:
+            fmt.Fprintf(stderr, "unknown flag: %s\n", args[0])
+            fmt.Fprint(stderr, usage)
+            return exitUsageError
+        }
+        args = args[1:]
+    }
+
+    if len(args) == 0 {
+        if write {
+            fmt.Fprintln(stderr, "skibidi: -w needs files to write to")
+            return exitUsageError
+        }
+        src, err := io.ReadAll(stdin)
+        if err != nil {
+            fmt.Fprintf(stderr, "skibidi: %s\n", err)
+            return exitInputError
+        }
+        return formatSource("<stdin>", string(src), false, diff, stdout, stderr)
+    }
+
+    // every file is done even if an earlier one fails, the exit code is the worst of them
+    status := exitOK
+    for _, path := range args {
+        src, err := os.ReadFile(path)
+        if err != nil {
+            fmt.Fprintf(stderr, "skibidi: %s\n", err)
+            status = max(status, exitInputError)
+            continue
+        }
+        status = max(status, formatSource(path, string(src), write, diff, stdout, stderr))
+    }
+    return status
+}
+
+func formatSource(path string, src string, write bool, diff bool, stdout io.Writer, stderr io.Writer) int {
+    formatted, err := format.Source(path, src)
+    if err != nil {
+        fmt.Fprintln(stderr, err)
+        return exitParseError
+    }
+
+    if !write && !diff {
+        io.WriteString(stdout, formatted)
+        return exitOK
+    }
+
+    status := exitOK
+    if diff {
+        if d := format.Diff(path, src, formatted); d != "" {
+            io.WriteString(stdout, d)
+            status = exitNotFormatted
+        }
+    }
+    if write && formatted != src {
+        info, err := os.Stat(path)
+        if err == nil {
+            err = os.WriteFile(path, []byte(formatted), info.Mode().Perm())
+        }
+        if err != nil {
+            fmt.Fprintf(stderr, "skibidi: %s\n", err)
+            return exitInputError
+        }
+    }
+    return status
+}
+
+// parses and evaluates a whole script, the script arguments are bound to 'args' as an array of strings
+// printResult is used by -e, where the value of the last expression is the whole point of running it
+func execute(filename string, src string, scriptArgs []string, printResult bool, opts options, stdout io.Writer, stderr io.Writer) int {
+    l := lexer.NewFile(filename, src)
+    p := parser.New(l)
+
+    program := p.ParseProgram()
+    if len(p.Errors()) != 0 {
+        for _, d := range p.Errors() {
+            fmt.Fprintln(stderr, d.Error())
+        }
+        return exitParseError
+    }
+
+    env := object.NewEnvironment()
+    env.Set("args", stringArray(scriptArgs))
+
+    c := evaluator.NewContext(context.Background(), evaluator.DefaultLimits)
+    c.Overflow = opts.overflow
+    c.Stdout = stdout
+    var evaluated object.Object
+    if opts.vm {
+        evaluated = vm.EvalContext(c, program, env)
+    } else {
+        evaluated = evaluator.EvalContext(c, program, env)
+    }
+    if errObj, ok := evaluated.(*object.Error); ok {
+        fmt.Fprintln(stderr, errObj.Traceback())
+        return exitRuntimeError
+    }
+
+    if printResult && evaluated != nil && evaluated != evaluator.NULL {
+        fmt.Fprintln(stdout, evaluated.Inspect())
+    }
+
+    return exitOK
+}
+
+func stringArray(values []string) *object.Array {
+    elements := make([]object.Object, len(values))
+    for i, v := range values {
+        elements[i] = &object.String{Value: v}
+    }
+    return &object.Array{Elements: elements}
+}
+
+// a character device means a person is typing, anything else (a pipe, a file) means input is being fed to us
+func isTerminal(f *os.File) bool {
+    info, err := f.Stat()
+    if err != nil {
+        return false
+    }
+    return info.Mode()&os.ModeCharDevice != 0
 }
@@ -0,0 +1,85 @@
+package main
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// stdin as a pipe with src written into it, the way it is for `echo ... | skibidi`
+func pipeStdin(t *testing.T, src string) *os.File {
+    t.Helper()
+
+    r, w, err := os.Pipe()
+    if err != nil {
+        t.Fatal(err)
+    }
+    go func() {
+        w.WriteString(src)
+        w.Close()
+    }()
+    t.Cleanup(func() { r.Close() })
+    return r
+}
+
+func TestRun(t *testing.T) {
+    dir := t.TempDir()
+    script := filepath.Join(dir, "script.skb")
+    if err := os.WriteFile(script, []byte("puts(len(args));\nargs[0]"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    failing := filepath.Join(dir, "div.skb")
+    if err := os.WriteFile(failing, []byte("let half = fn(x) { x / 0 };\nhalf(1);"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        args        []string
+        stdin       string
+        code        int
+        stdout      string
+        stderr      string // only has to be the start of what's printed
+    }{
+        // -e prints the result, unless there isn't one
+        {[]string{"-e", "1 + 2"}, "", exitOK, "3\n", ""},
+        {[]string{"-e", "let x = 1;"}, "", exitOK, "", ""},
+        {[]string{"-e", "puts(\"a\")"}, "", exitOK, "a\n", ""},
+        {[]string{"--vm", "-e", "1 + 2"}, "", exitOK, "3\n", ""},
+        // everything after the source or the script belongs to the program as args
+        {[]string{"-e", "puts(args); len(args)", "a", "--vm"}, "", exitOK, "[a, --vm]\n2\n", ""},
+        {[]string{"-e", "args"}, "", exitOK, "[]\n", ""},
+        {[]string{"run", script, "x", "y"}, "", exitOK, "2\n", ""},
+        {[]string{script, "x"}, "", exitOK, "1\n", ""},
+        // piped in with no command, or as - with one
+        {nil, "puts(\"piped\"); 5", exitOK, "piped\n", ""},
+        {[]string{"run", "-", "x"}, "puts(args[0])", exitOK, "x\n", ""},
+        {nil, "let = 1", exitParseError, "", "<stdin>:1:5: expected next token to be IDENT, got: ="},
+        // what went wrong decides the exit code
+        {[]string{"-e", "1 / 0"}, "", exitRuntimeError, "", "ERROR: <cmdline>:1:3: division by zero: 1 / 0"},
+        {[]string{"--checked", "-e", "9223372036854775807 + 1"}, "", exitRuntimeError, "", "ERROR: <cmdline>:1:21: integer overflow"},
+        {[]string{"run", failing}, "", exitRuntimeError, "", "ERROR: " + failing + ":1:22: division by zero: 1 / 0\nstack trace (most recent call first):\n    in half, called at " + failing + ":2:5\n"},
+        {[]string{"-e", "let = 1"}, "", exitParseError, "", "<cmdline>:1:5: expected next token to be IDENT, got: ="},
+        {[]string{"run", filepath.Join(dir, "missing.skb")}, "", exitInputError, "", "skibidi: open " + filepath.Join(dir, "missing.skb")},
+        {[]string{"-e"}, "", exitUsageError, "", "usage:"},
+        {[]string{"run"}, "", exitUsageError, "", "usage:"},
+        {[]string{"--bogus"}, "", exitUsageError, "", "unknown flag: --bogus\nusage:"},
+        {[]string{"help"}, "", exitOK, usage, ""},
+        {[]string{"fmt", "-d"}, "let x=1;\n", exitNotFormatted, "--- <stdin>.orig\n+++ <stdin>\n@@ -1 +1 @@\n-let x=1;\n+let x = 1;\n", ""},
+    }
+
+    for _, tt := range tests {
+        var stdout, stderr strings.Builder
+        code := run(tt.args, pipeStdin(t, tt.stdin), &stdout, &stderr)
+
+        if code != tt.code {
+            t.Errorf("wrong exit code for %q, want: %d, got: %d (stderr: %q)", tt.args, tt.code, code, stderr.String())
+        }
+        if stdout.String() != tt.stdout {
+            t.Errorf("wrong output for %q, want: %q, got: %q", tt.args, tt.stdout, stdout.String())
+        }
+        if !strings.HasPrefix(stderr.String(), tt.stderr) || tt.stderr == "" && stderr.String() != "" {
+            t.Errorf("wrong errors for %q, want: %q, got: %q", tt.args, tt.stderr, stderr.String())
+        }
+    }
+}
@@ -1,5 +1,7 @@
 package object
 
+import "sort"
+
 /*
 the environment is used to keep track of values by associating them with a name
 in this example, the environment is just a wrapper of a standard Go hashmap
@@ -23,6 +25,21 @@ type Environment struct {
     outer *Environment
 }
 
+// the names bound directly in this environment, sorted, the ones in outer environments aren't included
+func (e *Environment) Names() []string {
+    names := make([]string, 0, len(e.store))
+    for name := range e.store {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// the environment this one is enclosed in, nil for the outermost one
+func (e *Environment) Outer() *Environment {
+    return e.outer
+}
+
 func (e *Environment) Get(name string) (Object, bool) {
     obj, ok := e.store[name]
     if !ok && e.outer != nil {
@@ -36,3 +53,15 @@ func (e *Environment) Set(name string, val Object) Object {
     return val
 }
 
+// changes an existing binding, in whichever environment it was made (which can be an outer one, eg a closure updating a counter)
+// unlike Set this never creates a binding, false means the name isn't bound anywhere
+func (e *Environment) Assign(name string, val Object) bool {
+    for env := e; env != nil; env = env.outer {
+        if _, ok := env.store[name]; ok {
+            env.store[name] = val
+            return true
+        }
+    }
+    return false
+}
+
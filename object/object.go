@@ -3,7 +3,14 @@ package object
 import (
     "fmt"
     "skibidi/ast"
+    "skibidi/code"
+    "skibidi/diagnostic"
+    "skibidi/token"
     "bytes"
+    "hash/fnv"
+    "math/big"
+    "sort"
+    "strconv"
     "strings"
 )
 
@@ -11,11 +18,19 @@ type ObjectType string
 
 const (
     INTEGER_OBJ = "INTEGER"
+    FLOAT_OBJ = "FLOAT"
     BOOLEAN_OBJ = "BOOLEAN"
     NULL_OBJ    = "NULL"
     RETURN_VALUE_OBJ = "RETURN_VALUE"
     ERROR_OBJ = "ERROR"
     FUNCTION_OBJ = "FUNCTION"
+    STRING_OBJ = "STRING"
+    ARRAY_OBJ = "ARRAY"
+    HASH_OBJ = "HASH"
+    BUILTIN_OBJ = "BUILTIN"
+    COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+    BREAK_OBJ = "BREAK"
+    CONTINUE_OBJ = "CONTINUE"
 )
 
 // every value in the source code will be represented as an object for simplicity
@@ -38,6 +53,79 @@ func (i *Integer) Type() ObjectType {
     return INTEGER_OBJ
 }
 
+func (i *Integer) HashKey() HashKey {
+    return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+// an integer too big (or small) for an int64, arithmetic on integers switches to one of these when it overflows
+// and switches back as soon as the result fits again, so a BigInt never holds a value an Integer could
+// to the program it's just an integer, the two only differ in how they're stored
+// the value is never changed after the BigInt is made, operations always create a new big.Int
+type BigInt struct {
+    Value *big.Int
+}
+
+// an Integer if v fits in an int64, a BigInt otherwise
+func IntegerFromBig(v *big.Int) Object {
+    if v.IsInt64() {
+        return &Integer{Value: v.Int64()}
+    }
+    return &BigInt{Value: v}
+}
+
+func (b *BigInt) Inspect() string {
+    return b.Value.String()
+}
+
+func (b *BigInt) Type() ObjectType {
+    return INTEGER_OBJ
+}
+
+// an Integer can never be equal to a BigInt, but they share a type, so a hash of the value could land on some Integer's key
+// the whole value goes into Big instead, which an Integer always leaves empty
+func (b *BigInt) HashKey() HashKey {
+    return HashKey{Type: b.Type(), Big: b.Value.String()}
+}
+
+type Float struct {
+    Value float64
+}
+
+func (f *Float) Type() ObjectType {
+    return FLOAT_OBJ
+}
+
+// prints the shortest text that reads back as the exact same float
+// a whole number still gets a .0 on the end, otherwise it would read back as an integer
+// the evaluator never makes an infinite or NaN float, so there's no spelling for those
+func (f *Float) Inspect() string {
+    s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+    if !strings.ContainsAny(s, ".e") {
+        s += ".0"
+    }
+    return s
+}
+
+// Inspect() gives back the raw value, so printing a string doesn't wrap it in quotes
+type String struct {
+    Value string
+}
+
+func (s *String) Type() ObjectType {
+    return STRING_OBJ
+}
+
+func (s *String) Inspect() string {
+    return s.Value
+}
+
+func (s *String) HashKey() HashKey {
+    h := fnv.New64a()
+    h.Write([]byte(s.Value))
+
+    return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
 type Boolean struct {
     Value bool
 }
@@ -50,6 +138,18 @@ func (b *Boolean) Inspect() string {
     return fmt.Sprintf("%t", b.Value)
 }
 
+func (b *Boolean) HashKey() HashKey {
+    var value uint64
+
+    if b.Value {
+        value = 1
+    } else {
+        value = 0
+    }
+
+    return HashKey{Type: b.Type(), Value: value}
+}
+
 type Null struct{}
 
 func (n *Null) Type() ObjectType {
@@ -72,23 +172,141 @@ func (rv *ReturnValue) Inspect() string {
     return rv.Value.Inspect()
 }
 
+// break and continue work the same way as return, the signal is passed up through the blocks until the loop sees it
+type Break struct{}
+
+func (b *Break) Type() ObjectType {
+    return BREAK_OBJ
+}
+
+func (b *Break) Inspect() string {
+    return "break"
+}
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType {
+    return CONTINUE_OBJ
+}
+
+func (c *Continue) Inspect() string {
+    return "continue"
+}
+
+type ErrorKind int
+
+const (
+    RuntimeError ErrorKind = iota // a mistake in the program
+    LimitError // the program was stopped for going over one of the limits it was run with, the program itself might be fine
+)
+
+// Pos is where in the source the error came from, it is left as the zero value when that isn't known
+// Stack is the function calls that were still running when it happened, innermost first
 type Error struct {
+    Kind    ErrorKind
+    Code    diagnostic.Code
     Message string
+    Pos     token.Position
+    Stack   []StackFrame
+}
+
+// one function call that an error came out of
+type StackFrame struct {
+    Function    string // the name the function was bound to with let, empty when it doesn't have one
+    CallPos     token.Position // where the call was made from
+}
+
+func (f StackFrame) String() string {
+    function := "anonymous function"
+    if f.Function != "" {
+        function = f.Function
+    }
+    return "in " + function + ", called at " + f.CallPos.String()
 }
 
 func (e *Error) Type() ObjectType {
     return ERROR_OBJ
 }
 
+// also the first line of Traceback, so the REPL and skibidi run start an error the same way
 func (e *Error) Inspect() string {
+    if e.Pos.IsValid() {
+        return "ERROR: " + e.Pos.String() + ": " + e.Message
+    }
     return "ERROR: " + e.Message
 }
 
+// the error as a diagnostic, each call in the stack trace becomes a note pointing at where the call was made
+func (e *Error) Diagnostic() diagnostic.Diagnostic {
+    d := diagnostic.Diagnostic{
+        Severity:   diagnostic.Error,
+        Code:       e.Code,
+        Message:    e.Message,
+        Span:       diagnostic.PosSpan(e.Pos),
+    }
+
+    for _, run := range collapseFrames(e.Stack) {
+        function := "anonymous function"
+        if run.frame.Function != "" {
+            function = run.frame.Function
+        }
+        d.Notes = append(d.Notes, diagnostic.Note{Message: "called " + function + " from here", Span: diagnostic.PosSpan(run.frame.CallPos)})
+        if run.repeats > 0 {
+            d.Notes = append(d.Notes, diagnostic.Note{Message: fmt.Sprintf("the call above is repeated %d more times", run.repeats)})
+        }
+    }
+
+    return d
+}
+
+// the error followed by the calls it came out of, recursion that fails deep down would print thousands of the
+// same line so a run of identical frames is collapsed into one
+func (e *Error) Traceback() string {
+    var out bytes.Buffer
+
+    out.WriteString(e.Inspect())
+    if len(e.Stack) == 0 {
+        return out.String()
+    }
+
+    out.WriteString("\nstack trace (most recent call first):")
+    for _, run := range collapseFrames(e.Stack) {
+        out.WriteString("\n    " + run.frame.String())
+        if run.repeats > 0 {
+            out.WriteString(fmt.Sprintf("\n    [the line above is repeated %d more times]", run.repeats))
+        }
+    }
+
+    return out.String()
+}
+
+// a frame and how many times in a row it shows up again right after itself
+type frameRun struct {
+    frame   StackFrame
+    repeats int
+}
+
+func collapseFrames(stack []StackFrame) []frameRun {
+    runs := []frameRun{}
+    for i := 0; i < len(stack); {
+        run := frameRun{frame: stack[i]}
+        for i+1+run.repeats < len(stack) && stack[i+1+run.repeats] == run.frame {
+            run.repeats++
+        }
+        runs = append(runs, run)
+        i += 1 + run.repeats
+    }
+    return runs
+}
+
 // self explanatory, the parts that make up a functions structure (at least the parts we care about)
 type Function struct {
     Parameters  []*ast.Identifier
+    Defaults    []ast.Expression // same as in ast.FunctionLiteral, nil for a parameter without a default
+    Rest        *ast.Identifier
     Body        *ast.BlockStatement
     Env         *Environment
+    Name        string
 }
 
 func (f *Function) Type() ObjectType {
@@ -96,21 +314,251 @@ func (f *Function) Type() ObjectType {
 }
 
 func (f *Function) Inspect() string {
+    return inspectFunction(f.Parameters, f.Defaults, f.Rest, f.Body)
+}
+
+// shared by functions and compiled functions so both print the same way
+func inspectFunction(parameters []*ast.Identifier, defaults []ast.Expression, rest *ast.Identifier, body *ast.BlockStatement) string {
     var out bytes.Buffer // basically just a string variable
 
-    params := []string{}
-    for _, p := range f.Parameters {
-        params = append(params, p.String())
-    }
+    params := ast.ParameterStrings(parameters, defaults, rest)
 
     out.WriteString("fn")
     out.WriteString("(")
     out.WriteString(strings.Join(params, ", "))
     out.WriteString(") {\n")
-    out.WriteString(f.Body.String())
+    out.WriteString(body.String())
     out.WriteString("\n}")
 
     return out.String()
 
 }
 
+// the signature every builtin function implemented in Go has to follow
+// errors are reported by returning an *Error, same as anything else in the evaluator
+type BuiltinFunction func(args ...Object) Object
+
+type Builtin struct {
+    Name    string
+    Fn      BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType {
+    return BUILTIN_OBJ
+}
+
+func (b *Builtin) Inspect() string {
+    return "builtin function " + b.Name
+}
+
+type Array struct {
+    Elements []Object
+}
+
+func (a *Array) Type() ObjectType {
+    return ARRAY_OBJ
+}
+
+func (a *Array) Inspect() string {
+    return a.inspect(map[Object]bool{})
+}
+
+// arrays and hashes can hold themselves (after a[0] = a), seen has the ones that are being printed further up
+// so a cycle comes out as [...] or {...} instead of going around until the stack runs out
+// a value that's only in there twice without a cycle (like [b, b]) is printed in full both times
+func inspectIn(obj Object, seen map[Object]bool) string {
+    switch obj := obj.(type) {
+    case *Array:
+        return obj.inspect(seen)
+    case *Hash:
+        return obj.inspect(seen)
+    }
+    return obj.Inspect()
+}
+
+func (a *Array) inspect(seen map[Object]bool) string {
+    if seen[a] {
+        return "[...]"
+    }
+    seen[a] = true
+    defer delete(seen, a)
+
+    var out bytes.Buffer
+
+    elements := []string{}
+    for _, e := range a.Elements {
+        elements = append(elements, inspectIn(e, seen))
+    }
+
+    out.WriteString("[")
+    out.WriteString(strings.Join(elements, ", "))
+    out.WriteString("]")
+
+    return out.String()
+}
+
+// the key a value is stored under in a hash
+// the type is part of the key so that 1 and true (which would both have a Value of 1) don't collide
+type HashKey struct {
+    Type    ObjectType
+    Value   uint64
+    Big     string // only set for a BigInt, its digits
+}
+
+// any object that can be used as a key in a hash literal implements this
+type Hashable interface {
+    HashKey() HashKey
+}
+
+// both the original key and the value are kept, otherwise there would be no way to print or iterate over the keys
+type HashPair struct {
+    Key     Object
+    Value   Object
+}
+
+type Hash struct {
+    Pairs map[HashKey]HashPair
+}
+
+func (h *Hash) Type() ObjectType {
+    return HASH_OBJ
+}
+
+func (h *Hash) Inspect() string {
+    return h.inspect(map[Object]bool{})
+}
+
+func (h *Hash) inspect(seen map[Object]bool) string {
+    if seen[h] {
+        return "{...}"
+    }
+    seen[h] = true
+    defer delete(seen, h)
+
+    var out bytes.Buffer
+
+    pairs := []string{}
+    for _, pair := range h.Pairs {
+        pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), inspectIn(pair.Value, seen)))
+    }
+    // go maps have no order, so sort the pairs to get the same output every time
+    sort.Strings(pairs)
+
+    out.WriteString("{")
+    out.WriteString(strings.Join(pairs, ", "))
+    out.WriteString("}")
+
+    return out.String()
+}
+
+// the pairs ordered by their keys, anything that walks over a hash uses this so it happens in the same order every time
+func (h *Hash) SortedPairs() []HashPair {
+    pairs := make([]HashPair, 0, len(h.Pairs))
+    for _, pair := range h.Pairs {
+        pairs = append(pairs, pair)
+    }
+
+    sort.Slice(pairs, func(i, j int) bool {
+        return keyLess(pairs[i].Key, pairs[j].Key)
+    })
+
+    return pairs
+}
+
+// integers are put in numeric order (9 before 10), any other key by how it's printed
+func keyLess(a Object, b Object) bool {
+    if a.Type() != b.Type() {
+        return a.Type() < b.Type()
+    }
+    if a.Type() == INTEGER_OBJ {
+        return integerValue(a).Cmp(integerValue(b)) < 0
+    }
+    return a.Inspect() < b.Inspect()
+}
+
+func integerValue(obj Object) *big.Int {
+    if b, ok := obj.(*BigInt); ok {
+        return b.Value
+    }
+    return big.NewInt(obj.(*Integer).Value)
+}
+
+// a function after it has been through the compiler, only ever found in the constant pool
+// the parameters and body are kept around so that the function can still be printed like a regular one
+type CompiledFunction struct {
+    Instructions    code.Instructions
+    NumLocals       int
+    NumParameters   int // not counting the rest parameter
+    NumRequired     int // the parameters without a default value
+    HasRest         bool // the rest parameter sits in the slot right after the other parameters
+    Name            string
+    LocalNames      []string // the name of each local slot, used when a lookup has to fall back to searching by name
+    Positions       []SourcePosition // where in the source each instruction came from, sorted by offset
+    CallPositions   []SourcePosition // the ( of each call, which is where a stack trace says the call was made from
+    // the constant pool and global names of the program it was compiled in, which its instructions index into
+    Constants       []Object
+    GlobalNames     []string
+    Parameters      []*ast.Identifier
+    Defaults        []ast.Expression
+    Rest            *ast.Identifier
+    Body            *ast.BlockStatement
+}
+
+func (cf *CompiledFunction) Type() ObjectType {
+    return COMPILED_FUNCTION_OBJ
+}
+
+func (cf *CompiledFunction) Inspect() string {
+    if cf.Body == nil {
+        return fmt.Sprintf("CompiledFunction[%p]", cf)
+    }
+    return inspectFunction(cf.Parameters, cf.Defaults, cf.Rest, cf.Body)
+}
+
+// the source position an instruction starting at Offset was compiled from
+type SourcePosition struct {
+    Offset  int
+    Pos     token.Position
+}
+
+// finds the position of the instruction at the given offset
+func (cf *CompiledFunction) PositionAt(offset int) token.Position {
+    return positionAt(cf.Positions, offset)
+}
+
+// same as PositionAt for the call instruction at the given offset, but gives back where its ( is
+func (cf *CompiledFunction) CallPositionAt(offset int) token.Position {
+    return positionAt(cf.CallPositions, offset)
+}
+
+func positionAt(positions []SourcePosition, offset int) token.Position {
+    i := sort.Search(len(positions), func(i int) bool {
+        return positions[i].Offset > offset
+    })
+    if i == 0 {
+        return token.Position{}
+    }
+    return positions[i-1].Pos
+}
+
+// the local variables of one call to a compiled function
+// same idea as the environment, a closure holds on to the locals it was created in and can reach outwards through Outer
+type Locals struct {
+    Slots   []Object
+    Fn      *CompiledFunction
+    Outer   *Locals
+}
+
+// to the user a closure is just a function, so it reports the same type and prints the same way
+type Closure struct {
+    Fn      *CompiledFunction
+    Env     *Locals // nil for functions defined at the top level
+}
+
+func (c *Closure) Type() ObjectType {
+    return FUNCTION_OBJ
+}
+
+func (c *Closure) Inspect() string {
+    return c.Fn.Inspect()
+}
@@ -0,0 +1,121 @@
+package object
+
+import (
+    "math/big"
+    "strconv"
+    "strings"
+    "testing"
+)
+
+func TestStringHashKey(t *testing.T) {
+    hello1 := &String{Value: "Hello World"}
+    hello2 := &String{Value: "Hello World"}
+    diff := &String{Value: "My name is johnny"}
+
+    if hello1.HashKey() != hello2.HashKey() {
+        t.Errorf("strings with same content have different hash keys")
+    }
+
+    if hello1.HashKey() == diff.HashKey() {
+        t.Errorf("strings with different content have same hash keys")
+    }
+}
+
+// whatever Inspect prints has to read back as the same float (and not as an integer)
+func TestFloatInspect(t *testing.T) {
+    tests := []struct {
+        value       float64
+        expected    string
+    }{
+        {3.14, "3.14"},
+        {2, "2.0"},
+        {-0.5, "-0.5"},
+        {0.30000000000000004, "0.30000000000000004"},
+        {1e-9, "1e-09"},
+        {1e21, "1e+21"},
+        {123456, "123456.0"},
+    }
+
+    for _, tt := range tests {
+        f := &Float{Value: tt.value}
+        if f.Inspect() != tt.expected {
+            t.Errorf("wrong output for %v, want: %q, got: %q", tt.value, tt.expected, f.Inspect())
+            continue
+        }
+
+        back, err := strconv.ParseFloat(f.Inspect(), 64)
+        if err != nil || back != tt.value {
+            t.Errorf("%q doesn't read back as %v", f.Inspect(), tt.value)
+        }
+    }
+}
+
+func TestBigIntHashKey(t *testing.T) {
+    a, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+    b, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+    neg := new(big.Int).Neg(a)
+
+    if (&BigInt{Value: a}).HashKey() != (&BigInt{Value: b}).HashKey() {
+        t.Errorf("big integers with the same value have different hash keys")
+    }
+    if (&BigInt{Value: a}).HashKey() == (&BigInt{Value: neg}).HashKey() {
+        t.Errorf("a big integer and its negation have the same hash key")
+    }
+    // 2**64 used to hash to the same key as this ordinary integer
+    two64 := new(big.Int).Lsh(big.NewInt(1), 64)
+    if (&BigInt{Value: two64}).HashKey() == (&Integer{Value: 554774489934347788}).HashKey() {
+        t.Errorf("a big integer has the same hash key as an integer")
+    }
+}
+
+func TestIntegerFromBig(t *testing.T) {
+    small := IntegerFromBig(big.NewInt(-42))
+    if i, ok := small.(*Integer); !ok || i.Value != -42 {
+        t.Errorf("expected an Integer, got: %T (%+v)", small, small)
+    }
+
+    huge := IntegerFromBig(new(big.Int).Lsh(big.NewInt(1), 63))
+    if b, ok := huge.(*BigInt); !ok || b.Inspect() != "9223372036854775808" {
+        t.Errorf("expected a BigInt, got: %T (%+v)", huge, huge)
+    }
+}
+
+func TestEnvironmentNames(t *testing.T) {
+    outer := NewEnvironment()
+    outer.Set("b", &Integer{Value: 1})
+    outer.Set("a", &Integer{Value: 2})
+    inner := NewEnclosedEnvironment(outer)
+    inner.Set("c", &Integer{Value: 3})
+
+    if names := outer.Names(); strings.Join(names, ",") != "a,b" {
+        t.Errorf("wrong names for the outer environment, got: %q", names)
+    }
+    if names := inner.Names(); strings.Join(names, ",") != "c" {
+        t.Errorf("wrong names for the inner environment, got: %q", names)
+    }
+    if inner.Outer() != outer || outer.Outer() != nil {
+        t.Errorf("wrong outer environments")
+    }
+}
+
+func TestCyclicInspect(t *testing.T) {
+    array := &Array{Elements: []Object{&Integer{Value: 1}}}
+    array.Elements = append(array.Elements, array)
+    if got := array.Inspect(); got != "[1, [...]]" {
+        t.Errorf("wrong Inspect for an array that holds itself, got: %q", got)
+    }
+
+    key := &String{Value: "self"}
+    hash := &Hash{Pairs: map[HashKey]HashPair{}}
+    hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: hash}
+    if got := hash.Inspect(); got != "{self: {...}}" {
+        t.Errorf("wrong Inspect for a hash that holds itself, got: %q", got)
+    }
+
+    // the same array twice without a cycle is printed in full both times
+    shared := &Array{Elements: []Object{&Integer{Value: 2}}}
+    outer := &Array{Elements: []Object{shared, shared}}
+    if got := outer.Inspect(); got != "[[2], [2]]" {
+        t.Errorf("wrong Inspect for a shared array, got: %q", got)
+    }
+}
@@ -6,9 +6,12 @@ package parser
 
 import (
     "skibidi/ast"
+    "skibidi/diagnostic"
     "skibidi/lexer"
     "skibidi/token"
+    "errors"
     "fmt"
+    "math/big"
     "strconv"
 )
 
@@ -17,12 +20,16 @@ const (
     // iota gives numbers to these values (think enum in c)
     _ int = iota
     LOWEST
+    ASSIGN // = += -= *= /=, the loosest of them all so x = a || b assigns the whole thing
+    LOGICAL_OR // ||
+    LOGICAL_AND // &&, binds tighter than || so a || b && c is a || (b && c)
     EQUALS
     LESSGREATER
     SUM
     PRODUCT
     PREFIX
     CALL
+    INDEX
 )
 
 // seperate into prefix and infix operators because they are treated completely differently
@@ -36,7 +43,14 @@ type Parser struct {
     l           *lexer.Lexer // a pointer to an instance of the lexer (where we call nextToken())
     curToken    token.Token // these two act like two 'pointers' to the curr and upcoming tokens
     peekToken   token.Token
-    errors []string
+    peekErrors  []diagnostic.Diagnostic // what the lexer found wrong inside peekToken, reported once it's the current token
+    errors []diagnostic.Diagnostic
+    loopDepth   int // how many loops the current token is inside of (within the current function), break and continue need at least one
+
+    // error recovery, see synchronize
+    depth       int // how many { are open at the current token, counting the current token itself
+    panicking   bool // the statement being parsed has already failed, any errors after the first one are just fallout from it
+    unfinished  int // how many of the errors are only there because the input ended too early, see Incomplete
 
     prefixParseFns  map[token.TokenType]prefixParseFn
     infixParseFns  map[token.TokenType]infixParseFn
@@ -53,12 +67,14 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 func New(l *lexer.Lexer) *Parser {
     p := &Parser{
         l: l,
-        errors: []string{},
+        errors: []diagnostic.Diagnostic{},
     }
 
     p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
     p.registerPrefix(token.IDENT, p.parseIdentifier)
     p.registerPrefix(token.INT, p.parseIntegerLiteral)
+    p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+    p.registerPrefix(token.STRING, p.parseStringLiteral)
     p.registerPrefix(token.BANG, p.parsePrefixExpression)
     p.registerPrefix(token.MINUS, p.parsePrefixExpression)
     p.registerPrefix(token.TRUE, p.parseBoolean)
@@ -66,6 +82,8 @@ func New(l *lexer.Lexer) *Parser {
     p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
     p.registerPrefix(token.IF, p.parseIfExpression)
     p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+    p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+    p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 
     p.infixParseFns = make(map[token.TokenType]infixParseFn)
     p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -76,7 +94,17 @@ func New(l *lexer.Lexer) *Parser {
     p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
     p.registerInfix(token.LT, p.parseInfixExpression)
     p.registerInfix(token.GT, p.parseInfixExpression)
+    p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+    p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+    p.registerInfix(token.AND, p.parseInfixExpression)
+    p.registerInfix(token.OR, p.parseInfixExpression)
     p.registerInfix(token.LPAREN, p.parseCallExpression)
+    p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+    p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+    p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+    p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+    p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
+    p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 
     // read two tokens, so curToken and peekToken are both set
     p.nextToken()
@@ -108,17 +136,132 @@ func (p *Parser) parseIdentifier() ast.Expression {
 
 func (p *Parser) nextToken() {
     p.curToken = p.peekToken
+
+    // these don't go through report, the lexer finds them no matter what state the parser is in
+    p.errors = append(p.errors, p.peekErrors...)
+    seen := len(p.l.Errors())
+
     p.peekToken = p.l.NextToken()
+
+    // only braces are counted, they're what statements live inside of
+    // a missing ) or ] is a common mistake and shouldn't throw off where the next statement starts
+    switch p.curToken.Type {
+    case token.LBRACE:
+        p.depth++
+    case token.RBRACE:
+        p.depth--
+    }
+
+    // the lexer might have been told to keep comments (eg it's shared with a formatter), they mean nothing to the grammar
+    for p.peekToken.Type == token.COMMENT {
+        p.peekToken = p.l.NextToken()
+    }
+    p.peekErrors = p.l.Errors()[seen:]
 }
 
-func (p *Parser) Errors() []string {
+func (p *Parser) Errors() []diagnostic.Diagnostic {
     return p.errors
 }
 
+// true when everything wrong with the input is that it stopped too early: a (, [ or { that isn't closed yet,
+// an operator or keyword with nothing after it, a string or block comment that runs to the end
+// the REPL uses this to ask for another line instead of reporting the errors
+func (p *Parser) Incomplete() bool {
+    return len(p.errors) > 0 && p.unfinished == len(p.errors)
+}
+
+func newDiagnostic(code diagnostic.Code, span diagnostic.Span, format string, a ...interface{}) diagnostic.Diagnostic {
+    return diagnostic.Diagnostic{
+        Severity:   diagnostic.Error,
+        Code:       code,
+        Message:    fmt.Sprintf(format, a...),
+        Span:       span,
+    }
+}
+
+func (p *Parser) errorAt(code diagnostic.Code, span diagnostic.Span, format string, a ...interface{}) {
+    p.fail(newDiagnostic(code, span, format, a...))
+}
+
+// the parser is left not knowing where it is in the statement, so the statement is thrown away and
+// nothing else is reported until it has found the start of the next one
+func (p *Parser) fail(d diagnostic.Diagnostic) {
+    p.report(d)
+    p.panicking = true
+}
+
+// same as fail, for a mistake that more input could have fixed (see Incomplete)
+func (p *Parser) failAtEnd(d diagnostic.Diagnostic) {
+    if !p.panicking {
+        p.unfinished++
+    }
+    p.fail(d)
+}
+
+// for mistakes that still leave a statement that parsed fine (like a break outside of a loop), there's nothing to recover from
+func (p *Parser) report(d diagnostic.Diagnostic) {
+    if p.panicking {
+        return
+    }
+    p.errors = append(p.errors, d)
+}
+
+// skips the rest of a statement that failed to parse, leaving the current token on the start of the next statement
+// depth is how many braces were open when the statement started, a ';' or keyword only ends the statement at that same depth
+// so a mistake inside of a function body doesn't stop at the first ';' in the body
+// inside a block, a '}' that closes more than was opened is the end of the block and is left for the block to see
+// parsing always moves forward from here, at worst all the way to EOF
+func (p *Parser) synchronize(depth int, inBlock bool) {
+    p.panicking = false
+
+    for {
+        switch {
+        case p.curTokenIs(token.EOF):
+            return
+        case inBlock && p.curTokenIs(token.RBRACE) && p.depth < depth:
+            return
+        case p.curTokenIs(token.SEMICOLON) && p.depth == depth:
+            p.nextToken()
+            return
+        }
+
+        p.nextToken()
+
+        if p.depth == depth && statementKeywords[p.curToken.Type] {
+            return
+        }
+    }
+}
+
+// tokens that can only ever start a statement, so they're a safe place to pick parsing back up
+var statementKeywords = map[token.TokenType]bool{
+    token.LET:      true,
+    token.RETURN:   true,
+    token.WHILE:    true,
+    token.FOR:      true,
+    token.BREAK:    true,
+    token.CONTINUE: true,
+}
+
 // works with the expectPeek function to make debugging easier in cases of errors
+// a missing closing bracket is by far the most common case, so that gets a fix to insert it
 func (p *Parser) peekError(t token.TokenType) {
-    msg := fmt.Sprintf("expected next token to be %s, got: %s", t, p.peekToken.Type)
-    p.errors = append(p.errors, msg)
+    d := newDiagnostic(diagnostic.UnexpectedToken, diagnostic.TokenSpan(p.peekToken), "expected next token to be %s, got: %s", t, p.peekToken.Type)
+
+    switch t {
+    case token.RPAREN, token.RBRACKET, token.RBRACE:
+        d.Fix = &diagnostic.Fix{
+            Message:        fmt.Sprintf("insert the missing %s", t),
+            Span:           diagnostic.PosSpan(p.curToken.End),
+            Replacement:    string(t),
+        }
+    }
+
+    if p.peekTokenIs(token.EOF) {
+        p.failAtEnd(d)
+        return
+    }
+    p.fail(d)
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -126,7 +269,12 @@ func (p *Parser) ParseProgram() *ast.Program {
     program.Statements = []ast.Statement{}
 
     for !p.curTokenIs(token.EOF){
+        depth := p.depth
         stmt := p.parseStatement()
+        if p.panicking {
+            p.synchronize(depth, false)
+            continue
+        }
         if stmt != nil {
             program.Statements = append(program.Statements, stmt)
         }
@@ -141,6 +289,12 @@ func (p *Parser) parseStatement() ast.Statement {
         return p.parseLetStatement()
     case token.RETURN:
         return p.parseReturnStatement()
+    case token.WHILE:
+        return p.parseWhileStatement()
+    case token.FOR:
+        return p.parseForStatement()
+    case token.BREAK, token.CONTINUE:
+        return p.parseLoopControlStatement()
     default:
         return p.parseExpressionStatement()
     }
@@ -153,13 +307,19 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 
     stmt.Expression = p.parseExpression(LOWEST)
 
-    if p.peekTokenIs(token.SEMICOLON) {
+    p.skipSemicolon()
+    return stmt
+}
+
+// the ';' after a statement is optional
+// a statement that failed leaves it for synchronize, it might come after a '}' that closes the block the statement was in
+func (p *Parser) skipSemicolon() {
+    if !p.panicking && p.peekTokenIs(token.SEMICOLON) {
         p.nextToken()
     }
-    return stmt
 }
 
-func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
+func (p *Parser) parseReturnStatement() ast.Statement {
 
     stmt := &ast.ReturnStatement{ 
         Token: p.curToken,
@@ -169,14 +329,13 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
     stmt.ReturnValue = p.parseExpression(LOWEST)
 
-    for !p.curTokenIs(token.SEMICOLON){
-        p.nextToken()
-    }
+    p.skipSemicolon()
 
     return stmt
 }
 
-func (p *Parser) parseLetStatement() *ast.LetStatement {
+// returns an ast.Statement rather than *ast.LetStatement so a failed let is a real nil and not a nil pointer inside an interface
+func (p *Parser) parseLetStatement() ast.Statement {
     stmt := &ast.LetStatement{Token: p.curToken}
 
     if !p.expectPeek(token.IDENT) {
@@ -193,10 +352,96 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
     stmt.Value = p.parseExpression(LOWEST)
 
-    for !p.curTokenIs(token.SEMICOLON) {
-        p.nextToken()
+    // the function remembers what it was called, so errors can say which function went wrong
+    if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+        fn.Name = stmt.Name.Value
+    }
+
+    p.skipSemicolon()
+
+    return stmt
+}
+
+// while (condition) { body }
+func (p *Parser) parseWhileStatement() ast.Statement {
+    stmt := &ast.WhileStatement{Token: p.curToken}
+
+    if !p.expectPeek(token.LPAREN) {
+        return nil
+    }
+
+    p.nextToken()
+    stmt.Condition = p.parseExpression(LOWEST)
+
+    if !p.expectPeek(token.RPAREN) {
+        return nil
+    }
+    if !p.expectPeek(token.LBRACE) {
+        return nil
+    }
+
+    stmt.Body = p.parseLoopBody()
+    p.skipSemicolon()
+
+    return stmt
+}
+
+// for (name in iterable) { body }
+func (p *Parser) parseForStatement() ast.Statement {
+    stmt := &ast.ForStatement{Token: p.curToken}
+
+    if !p.expectPeek(token.LPAREN) {
+        return nil
+    }
+    if !p.expectPeek(token.IDENT) {
+        return nil
+    }
+    stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+    if !p.expectPeek(token.IN) {
+        return nil
+    }
+
+    p.nextToken()
+    stmt.Iterable = p.parseExpression(LOWEST)
+
+    if !p.expectPeek(token.RPAREN) {
+        return nil
+    }
+    if !p.expectPeek(token.LBRACE) {
+        return nil
+    }
+
+    stmt.Body = p.parseLoopBody()
+    p.skipSemicolon()
+
+    return stmt
+}
+
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+    p.loopDepth++
+    defer func() { p.loopDepth-- }()
+
+    return p.parseBlockStatement()
+}
+
+// break and continue, both only make sense inside a loop
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+    var stmt ast.Statement
+    if p.curTokenIs(token.BREAK) {
+        stmt = &ast.BreakStatement{Token: p.curToken}
+    } else {
+        stmt = &ast.ContinueStatement{Token: p.curToken}
+    }
+
+    if p.loopDepth == 0 {
+        d := newDiagnostic(diagnostic.LoopControlOutsideLoop, diagnostic.TokenSpan(p.curToken), "%s outside of a loop", p.curToken.Literal)
+        d.Notes = []diagnostic.Note{{Message: "break and continue only work inside a while or for loop (a function defined in a loop doesn't count)"}}
+        p.report(d)
     }
 
+    p.skipSemicolon()
+
     return stmt
 }
 
@@ -230,9 +475,16 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
     // third parameter is the bitSize (integer type that is returned) - 64 for int64, etc
     value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 
+    if errors.Is(err, strconv.ErrRange) {
+        // too big for an int64, which is fine, it just has to be stored differently
+        if n, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+            lit.Big = n
+            return lit
+        }
+    }
+
     if err != nil {
-        msg := fmt.Sprintf("Could not parse %q as integer", p.curToken.Literal)
-        p.errors = append(p.errors, msg)
+        p.errorAt(diagnostic.InvalidNumber, diagnostic.TokenSpan(p.curToken), "Could not parse %q as integer", p.curToken.Literal)
         return nil
     }
 
@@ -242,13 +494,33 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+    value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+    if err != nil {
+        p.errorAt(diagnostic.InvalidNumber, diagnostic.TokenSpan(p.curToken), "Could not parse %q as float", p.curToken.Literal)
+        return nil
+    }
+
+    return &ast.FloatLiteral{Token: p.curToken, Value: value}
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+    return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
     return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-    msg := fmt.Sprintf("no prefix parse function for %s found", t)
-    p.errors = append(p.errors, msg)
+    d := newDiagnostic(diagnostic.MissingExpression, diagnostic.TokenSpan(p.curToken), "no prefix parse function for %s found", t)
+
+    // an unterminated string is always the last token, the lexer has run out of input by the time it hands it out
+    if t == token.EOF || t == token.ILLEGAL && p.peekTokenIs(token.EOF) && p.l.Unterminated() {
+        p.failAtEnd(d)
+        return
+    }
+    p.fail(d)
 }
 
 // this function is the heart of the Pratt parser
@@ -283,15 +555,34 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 
 // look up table for precedences that references the previously defined const
 var precedences = map[token.TokenType] int{
+    token.ASSIGN:           ASSIGN,
+    token.PLUS_ASSIGN:      ASSIGN,
+    token.MINUS_ASSIGN:     ASSIGN,
+    token.ASTERISK_ASSIGN:  ASSIGN,
+    token.SLASH_ASSIGN:     ASSIGN,
+    token.OR:       LOGICAL_OR,
+    token.AND:      LOGICAL_AND,
     token.EQ:       EQUALS,
     token.NOT_EQ:   EQUALS,
     token.LT:       LESSGREATER,
     token.GT:       LESSGREATER,
+    token.LT_EQ:    LESSGREATER,
+    token.GT_EQ:    LESSGREATER,
     token.PLUS:     SUM,
     token.MINUS:    SUM,
     token.SLASH:    PRODUCT,
     token.ASTERISK: PRODUCT,
     token.LPAREN:   CALL,
+    token.LBRACKET: INDEX,
+}
+
+// how tightly an operator binds, LOWEST for a token that isn't one
+// the formatter uses this to work out which parentheses it can leave out
+func Precedence(t token.TokenType) int {
+    if p, ok := precedences[t]; ok {
+        return p
+    }
+    return LOWEST
 }
 
 func (p *Parser) peekPrecedence() int {
@@ -332,6 +623,35 @@ func (p *Parser) parseInfixExpression (left ast.Expression) ast.Expression {
 
 }
 
+// assignment is right associative, a = b = 1 is a = (b = 1), so the right side is parsed one level looser than ASSIGN
+func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
+    expression := &ast.AssignExpression{
+        Token:      p.curToken,
+        Target:     left,
+        Operator:   p.curToken.Literal,
+    }
+
+    switch left.(type) {
+    case *ast.Identifier, *ast.IndexExpression:
+    case nil:
+        // whatever was on the left already failed to parse and said so
+        return nil
+    default:
+        // after an earlier mistake the left side can be missing pieces, and there'd be nothing to report anyway
+        if !p.panicking {
+            d := newDiagnostic(diagnostic.InvalidAssignTarget, diagnostic.TokenSpan(p.curToken), "cannot assign to %s", left.String())
+            d.Notes = []diagnostic.Note{{Message: "only a variable or an element like a[0] can be assigned to"}}
+            p.fail(d)
+        }
+        return nil
+    }
+
+    p.nextToken()
+    expression.Value = p.parseExpression(ASSIGN - 1)
+
+    return expression
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
     p.nextToken()
     
@@ -392,14 +712,30 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 
     p.nextToken()
 
+    // if the statement around the block already failed, the block is only being parsed to get past it
+    outerPanicking := p.panicking
+
     for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+        depth := p.depth
         stmt := p.parseStatement()
+        if p.panicking && !outerPanicking {
+            p.synchronize(depth, true)
+            continue
+        }
         if stmt != nil {
             block.Statements = append(block.Statements, stmt)
         }
         p.nextToken()
     }
 
+    if p.curTokenIs(token.EOF) {
+        d := newDiagnostic(diagnostic.UnclosedBlock, diagnostic.TokenSpan(block.Token), "this { is never closed")
+        d.Fix = &diagnostic.Fix{Message: "insert the missing }", Span: diagnostic.PosSpan(p.curToken.Pos), Replacement: "}"}
+        p.failAtEnd(d)
+    }
+
+    block.Rbrace = p.curToken
+
     return block
 }
 
@@ -412,47 +748,82 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
         return nil
     }
 
-    lit.Parameters = p.parseFunctionParameters()
+    if !p.parseFunctionParameters(lit) {
+        return nil
+    }
 
     if !p.expectPeek(token.LBRACE){
         return nil
     }
 
     // the .Body contains the actual content of the function (the stuff inside the curly braces) which is why its parsed like any other block statement
+    // a loop around the function literal doesn't count, break can't jump out of a function
+    outerLoopDepth := p.loopDepth
+    p.loopDepth = 0
     lit.Body = p.parseBlockStatement()
+    p.loopDepth = outerLoopDepth
 
     return lit
 
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-    identifiers := []*ast.Identifier{}
+// fills in the parameters, default values and rest parameter of the function literal
+// parameters with a default have to come after the ones without, and the rest parameter has to be last
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
+    lit.Parameters = []*ast.Identifier{}
+    lit.Defaults = []ast.Expression{}
 
     // this is the case where there is no function parameters
     if p.peekTokenIs(token.RPAREN) {
         p.nextToken()
-        return identifiers
+        return true
     }
 
-    p.nextToken()
+    // as long as there is more parameters to parse, continue
+    for {
+        if lit.Rest != nil {
+            d := newDiagnostic(diagnostic.InvalidParameters, diagnostic.TokenSpan(p.peekToken), "the rest parameter has to be the last parameter")
+            d.Notes = []diagnostic.Note{{Message: "the rest parameter is " + lit.Rest.Value, Span: diagnostic.TokenSpan(lit.Rest.Token)}}
+            p.fail(d)
+            return false
+        }
 
-    ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-    identifiers = append(identifiers, ident)
+        if p.peekTokenIs(token.ELLIPSIS) {
+            p.nextToken()
+            if !p.expectPeek(token.IDENT) {
+                return false
+            }
+            lit.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+        } else {
+            if !p.expectPeek(token.IDENT) {
+                return false
+            }
+            ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+            var defaultValue ast.Expression
+            if p.peekTokenIs(token.ASSIGN) {
+                p.nextToken()
+                p.nextToken()
+                defaultValue = p.parseExpression(LOWEST)
+            } else if len(lit.Defaults) > 0 && lit.Defaults[len(lit.Defaults)-1] != nil {
+                previous := lit.Parameters[len(lit.Parameters)-1]
+                d := newDiagnostic(diagnostic.InvalidParameters, diagnostic.TokenSpan(ident.Token), "parameter %s needs a default value since the one before it has one", ident.Value)
+                d.Notes = []diagnostic.Note{{Message: previous.Value + " has a default value", Span: diagnostic.TokenSpan(previous.Token)}}
+                p.fail(d)
+                return false
+            }
+
+            lit.Parameters = append(lit.Parameters, ident)
+            lit.Defaults = append(lit.Defaults, defaultValue)
+        }
 
-    // as long as there is more parameters to parse, continue
-    for p.peekTokenIs(token.COMMA){
-        p.nextToken()
+        if !p.peekTokenIs(token.COMMA) {
+            break
+        }
         p.nextToken()
-        ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-        identifiers = append(identifiers, ident)
-    }
-
-    if !p.expectPeek(token.RPAREN) {
-        return nil
     }
 
-    return identifiers
-
+    return p.expectPeek(token.RPAREN)
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
@@ -461,35 +832,99 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
         Function: function,
     }
 
-    exp.Arguments = p.parseCallArguments()
+    exp.Arguments = p.parseExpressionList(token.RPAREN)
+    exp.Rparen = p.curToken
 
     return exp
 
 }
 
-func (p *Parser) parseCallArguments() []ast.Expression {
-    args := []ast.Expression{}
+// parses a comma separated list of expressions up until the given end token
+// used for both call arguments '(a, b)' and array elements '[a, b]'
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+    list := []ast.Expression{}
 
-    if p.peekTokenIs(token.RPAREN) {
+    if p.peekTokenIs(end) {
         p.nextToken()
-        return args
+        return list
     }
 
     p.nextToken()
 
-    args = append(args, p.parseExpression(LOWEST))
+    list = append(list, p.parseExpression(LOWEST))
 
     for p.peekTokenIs(token.COMMA) {
         p.nextToken()
         p.nextToken()
-        args = append(args, p.parseExpression(LOWEST))
+        list = append(list, p.parseExpression(LOWEST))
     }
 
-    if !p.expectPeek(token.RPAREN) {
+    if !p.expectPeek(end) {
         return nil
     }
 
-    return args
+    return list
+
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+    array := &ast.ArrayLiteral{
+        Token: p.curToken,
+    }
+
+    array.Elements = p.parseExpressionList(token.RBRACKET)
+    array.Rbracket = p.curToken
+
+    return array
+}
+
+// a '{' in expression position is always a hash literal, blocks only show up after if/else/fn where the parser expects them
+func (p *Parser) parseHashLiteral() ast.Expression {
+    hash := &ast.HashLiteral{
+        Token: p.curToken,
+        Pairs: []ast.HashPair{},
+    }
+
+    for !p.peekTokenIs(token.RBRACE) {
+        p.nextToken()
+        key := p.parseExpression(LOWEST)
+
+        if !p.expectPeek(token.COLON) {
+            return nil
+        }
+
+        p.nextToken()
+        value := p.parseExpression(LOWEST)
+
+        hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+        // either another pair follows, or the hash has to be closed
+        if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+            return nil
+        }
+    }
+
+    if !p.expectPeek(token.RBRACE) {
+        return nil
+    }
+    hash.Rbrace = p.curToken
 
+    return hash
 }
 
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+    exp := &ast.IndexExpression{
+        Token: p.curToken,
+        Left: left,
+    }
+
+    p.nextToken()
+    exp.Index = p.parseExpression(LOWEST)
+
+    if !p.expectPeek(token.RBRACKET) {
+        return nil
+    }
+    exp.Rbracket = p.curToken
+
+    return exp
+}
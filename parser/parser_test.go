@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"skibidi/ast"
+	"skibidi/diagnostic"
 	"skibidi/lexer"
 	"testing"
 )
@@ -139,6 +140,33 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{".5;", 0.5},
+		{"1e-9;", 1e-9},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -210,6 +238,10 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"true == true", true, "==", true},
 		{"true != false", true, "!=", false},
 		{"false == false", false, "==", false},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"true && false", true, "&&", false},
+		{"true || false", true, "||", false},
 	}
 
 	for _, tt := range infixTests {
@@ -341,6 +373,54 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
 		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		},
+		{
+			"-a[0]",
+			"(-(a[0]))",
+		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"a == b && c != d",
+			"((a == b) && (c != d))",
+		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"!a && b",
+			"((!a) && b)",
+		},
+		{
+			"x = a || b",
+			"(x = (a || b))",
+		},
+		{
+			"a = b = c + 1",
+			"(a = (b = (c + 1)))",
+		},
+		{
+			"arr[i + 1] *= 2",
+			"((arr[(i + 1)]) *= 2)",
+		},
+		{
+			"x += f(y -= 1)",
+			"(x += f((y -= 1)))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -501,6 +581,177 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < 10) { if (x == 5) { break; } continue }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T", program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("body is not 2 statements. got=%d\n", len(stmt.Body.Statements))
+	}
+
+	if _, ok := stmt.Body.Statements[1].(*ast.ContinueStatement); !ok {
+		t.Errorf("stmt.Body.Statements[1] is not ast.ContinueStatement. got=%T", stmt.Body.Statements[1])
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	input := `for (x in [1, 2]) { puts(x); }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T", program.Statements[0])
+	}
+
+	if !testIdentifier(t, stmt.Variable, "x") {
+		return
+	}
+
+	if stmt.Iterable.String() != "[1, 2]" {
+		t.Errorf("iterable wrong. got=%q", stmt.Iterable.String())
+	}
+
+	if len(stmt.Body.Statements) != 1 {
+		t.Fatalf("body is not 1 statement. got=%d\n", len(stmt.Body.Statements))
+	}
+
+	if program.String() != "for (x in [1, 2]) puts(x)" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+// like after any other statement, a ';' after a loop is allowed
+func TestLoopSemicolon(t *testing.T) {
+	tests := []string{
+		"while (false) { };",
+		"for (x in xs) { };",
+		"while (false) { }; let a = 1;",
+		"let f = fn() { for (x in xs) { }; 1 };",
+	}
+
+	for _, input := range tests {
+		l := lexer.New(input)
+		p := New(l)
+		p.ParseProgram()
+		checkParserErrors(t, p)
+	}
+}
+
+func TestAssignExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		target   string
+		operator string
+		value    string
+	}{
+		{"x = 5;", "x", "=", "5"},
+		{"x += 1;", "x", "+=", "1"},
+		{"x -= y;", "x", "-=", "y"},
+		{"x *= 2 + 3;", "x", "*=", "(2 + 3)"},
+		{"x /= 2;", "x", "/=", "2"},
+		{"arr[0] = true;", "(arr[0])", "=", "true"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.AssignExpression. got=%T", stmt.Expression)
+		}
+
+		if exp.Target.String() != tt.target {
+			t.Errorf("exp.Target wrong. want=%q, got=%q", tt.target, exp.Target.String())
+		}
+		if exp.Operator != tt.operator {
+			t.Errorf("exp.Operator wrong. want=%q, got=%q", tt.operator, exp.Operator)
+		}
+		if exp.Value.String() != tt.value {
+			t.Errorf("exp.Value wrong. want=%q, got=%q", tt.value, exp.Value.String())
+		}
+	}
+}
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 = 2", "1:3: cannot assign to 1"},
+		{"a + b = 2", "1:7: cannot assign to (a + b)"},
+		{"f() += 1", "1:5: cannot assign to f()"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
+		}
+
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.expected, errors[0].Error())
+		}
+	}
+}
+
+// break and continue have to be inside a loop, and a function in a loop starts over with no loop around it
+func TestLoopControlOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "1:1: break outside of a loop"},
+		{"if (true) { continue }", "1:13: continue outside of a loop"},
+		{"while (true) { fn() { break; } }", "1:23: break outside of a loop"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
+		}
+
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.expected, errors[0].Error())
+		}
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 
@@ -578,6 +829,70 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a, b = 2) {}", "fn(a, b = 2)"},
+		{"fn(a = 1 + 2, b = a) {}", "fn(a = (1 + 2), b = a)"},
+		{"fn(...rest) {}", "fn(...rest)"},
+		{"fn(a, b = 2, ...rest) {}", "fn(a, b = 2, ...rest)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program for %q. want=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	program := New(lexer.New("fn(a, ...rest) {}")).ParseProgram()
+	function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if len(function.Parameters) != 1 || function.Rest == nil || function.Rest.Value != "rest" {
+		t.Errorf("rest parameter not parsed separately, got: %s", function.String())
+	}
+}
+
+func TestInvalidParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(...rest, a) {}", "1:13: the rest parameter has to be the last parameter"},
+		{"fn(a = 1, b) {}", "1:11: parameter b needs a default value since the one before it has one"},
+		{"fn(...) {}", "1:7: expected next token to be IDENT, got: )"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
+		}
+
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestFunctionNameFromLet(t *testing.T) {
+	program := New(lexer.New("let add = fn(a, b) { a + b };")).ParseProgram()
+	function := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if function.Name != "add" {
+		t.Errorf("function name wrong. want=%q, got=%q", "add", function.Name)
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
@@ -670,6 +985,261 @@ func TestCallExpressionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello \"world\"\n";`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+
+	if literal.Value != "hello \"world\"\n" {
+		t.Errorf("literal.Value not %q. got=%q", "hello \"world\"\n", literal.Value)
+	}
+
+	if literal.String() != `"hello \"world\"\n"` {
+		t.Errorf("literal.String() not %q. got=%q", `"hello \"world\"\n"`, literal.String())
+	}
+}
+
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestParsingEmptyArrayLiteral(t *testing.T) {
+	input := "[]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 0 {
+		t.Errorf("len(array.Elements) not 0. got=%d", len(array.Elements))
+	}
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	input := `{"one": 1, true: 2, 3: "three"}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 3 {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	expectedKeys := []string{`"one"`, "true", "3"}
+	for i, key := range expectedKeys {
+		if hash.Pairs[i].Key.String() != key {
+			t.Errorf("key %d wrong. want=%s, got=%s", i, key, hash.Pairs[i].Key.String())
+		}
+	}
+
+	testIntegerLiteral(t, hash.Pairs[0].Value, 1)
+	testIntegerLiteral(t, hash.Pairs[1].Value, 2)
+
+	if hash.String() != `{"one": 1, true: 2, 3: "three"}` {
+		t.Errorf("hash.String() wrong. got=%q", hash.String())
+	}
+}
+
+func TestParsingEmptyHashLiteral(t *testing.T) {
+	input := "{}"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 0 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+}
+
+func TestParsingHashLiteralsWithExpressions(t *testing.T) {
+	input := `{"one": 0 + 1, "two": 10 - 8, "three": 15 / 5}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 3 {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	testInfixExpression(t, hash.Pairs[0].Value, 0, "+", 1)
+	testInfixExpression(t, hash.Pairs[1].Value, 10, "-", 8)
+	testInfixExpression(t, hash.Pairs[2].Value, 15, "/", 5)
+}
+
+func TestCommentsAreIgnored(t *testing.T) {
+	input := `// leading comment
+let x = /* inline */ 5; // trailing
+/* a /* nested */ block */
+add(x, /* arg */ 2);`
+
+	// the parser has to skip comments even when the lexer was told to keep them
+	l := lexer.New(input)
+	l.KeepComments()
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := "let x = 5;add(x, 2)"
+	if program.String() != expected {
+		t.Errorf("program wrong. want=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestParserErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x 5;", "test.skb:1:7: expected next token to be =, got: INT"},
+		{"let x = 5;\nlet = 10;", "test.skb:2:5: expected next token to be IDENT, got: ="},
+		{"1 + ;", "test.skb:1:5: no prefix parse function for ; found"},
+		{"add(1, 2", "test.skb:1:9: expected next token to be ), got: EOF"},
+		{"089", `test.skb:1:1: Could not parse "089" as integer`},
+		{"1e999", `test.skb:1:1: Could not parse "1e999" as float`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewFile("test.skb", tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q, got none", tt.input)
+			continue
+		}
+
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	tests := []struct {
+		input string
+		start string
+		end   string
+	}{
+		{"foobar;", "1:1", "1:7"},
+		{"-a * b", "1:1", "1:7"},
+		{"add(1, 2)", "1:1", "1:10"},
+		{"[1, 2][0]", "1:1", "1:10"},
+		{`{"a": 1}`, "1:1", "1:9"},
+		{"let x = \"hi\";", "1:1", "1:13"},
+		{"return x;", "1:1", "1:9"},
+		{"if (x) {\n  y\n} else {\n  z\n}", "1:1", "5:2"},
+		{"fn(x) {\n  x\n}", "1:1", "3:2"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.Pos().String() != tt.start {
+			t.Errorf("wrong start for %q. want=%s, got=%s", tt.input, tt.start, program.Pos())
+		}
+
+		if program.End().String() != tt.end {
+			t.Errorf("wrong end for %q. want=%s, got=%s", tt.input, tt.end, program.End())
+		}
+	}
+}
+
 func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	if s.TokenLiteral() != "let" {
 		t.Errorf("s.TokenLiteral not 'let'. got=%q", s.TokenLiteral())
@@ -814,3 +1384,175 @@ func checkParserErrors(t *testing.T, p *Parser) {
 	}
 	t.FailNow()
 }
+
+// every mistake is reported once, no matter how much of the statement around it had to be skipped
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input      string
+		expected   []string
+		statements int // how many statements survive
+	}{
+		{
+			"let = 5;\nlet y = 10 +;\nlet f = fn(x { x };\nlet ok = 1;",
+			[]string{
+				"1:5: expected next token to be IDENT, got: =",
+				"2:13: no prefix parse function for ; found",
+				"3:14: expected next token to be ), got: {",
+			},
+			1,
+		},
+		// a mistake inside a function body doesn't throw away the rest of the body or what comes after it
+		{
+			"let f = fn() {\n  let a = ;\n  let b = 2;\n  b\n};\nf();",
+			[]string{"2:11: no prefix parse function for ; found"},
+			2,
+		},
+		{
+			"if (x) { let = 1 }\nlet y = 2;",
+			[]string{"1:14: expected next token to be IDENT, got: ="},
+			2,
+		},
+		{
+			"while (true) { let h = {1: }; h }",
+			[]string{"1:28: no prefix parse function for } found"},
+			1,
+		},
+		// these used to loop forever looking for a ';'
+		{"let x = 1", []string{}, 1},
+		{"return 5", []string{}, 1},
+		{"let x = ", []string{"1:9: no prefix parse function for EOF found"}, 0},
+		{"return", []string{"1:7: no prefix parse function for EOF found"}, 0},
+		{"5 }\nlet x = 1;", []string{"1:3: no prefix parse function for } found"}, 2},
+		{"fn() { 1", []string{"1:6: this { is never closed"}, 0},
+		{"let x = [1, 2;\nlet y = 3;", []string{"1:14: expected next token to be ], got: ;"}, 1},
+		// the ';' after the body belongs to the let, the body's mistake doesn't take it (and everything after it) with it
+		{
+			"let f = fn(a) {\n  a +\n};\nlet x = ;\nlet y = 1 +;\n",
+			[]string{
+				"3:1: no prefix parse function for } found",
+				"4:9: no prefix parse function for ; found",
+				"5:12: no prefix parse function for ; found",
+			},
+			1,
+		},
+		// not being in a loop is reported, but there's nothing to recover from
+		{"break; let x = 1; continue", []string{"1:1: break outside of a loop", "1:19: continue outside of a loop"}, 3},
+		// the lexer reads past a bad escape, so it's the only error and the rest of the string isn't parsed as code
+		{`let s = "a\qb c";` + "\nlet y = 1;", []string{`1:11: invalid escape sequence \q`}, 2},
+		// it's reported in order with the parser's own errors, even while the parser is skipping a broken statement
+		{`let = "\q"; let x = ; "\z"`, []string{
+			"1:5: expected next token to be IDENT, got: =",
+			`1:8: invalid escape sequence \q`,
+			"1:21: no prefix parse function for ; found",
+			`1:24: invalid escape sequence \z`,
+		}, 1},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("wrong number of errors for %q. want=%d, got=%d: %q", tt.input, len(tt.expected), len(errors), errors)
+			continue
+		}
+		for i, msg := range tt.expected {
+			if errors[i].Error() != msg {
+				t.Errorf("wrong error %d for %q. want=%q, got=%q", i, tt.input, msg, errors[i].Error())
+			}
+		}
+
+		if len(program.Statements) != tt.statements {
+			t.Errorf("wrong number of statements for %q. want=%d, got=%d", tt.input, tt.statements, len(program.Statements))
+		}
+		for _, stmt := range program.Statements {
+			if stmt == nil {
+				t.Errorf("nil statement in program for %q", tt.input)
+			}
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input       string
+		code        diagnostic.Code
+		start, end  string
+		fix         string // the replacement of the suggested fix, if there should be one
+	}{
+		{"let x = (1 + 2;", diagnostic.UnexpectedToken, "1:15", "1:16", ")"},
+		{"let = 1;", diagnostic.UnexpectedToken, "1:5", "1:6", ""},
+		{"let x = 1 + ;", diagnostic.MissingExpression, "1:13", "1:14", ""},
+		{"089", diagnostic.InvalidNumber, "1:1", "1:4", ""},
+		{"1 = 2", diagnostic.InvalidAssignTarget, "1:3", "1:4", ""},
+		{"continue", diagnostic.LoopControlOutsideLoop, "1:1", "1:9", ""},
+		{"fn(a = 1, b) {}", diagnostic.InvalidParameters, "1:11", "1:12", ""},
+		{"if (x) { 1", diagnostic.UnclosedBlock, "1:8", "1:9", "}"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("expected 1 diagnostic for %q, got: %q", tt.input, errors)
+			continue
+		}
+
+		d := errors[0]
+		if d.Severity != diagnostic.Error || d.Code != tt.code {
+			t.Errorf("wrong diagnostic for %q. want=%s %s, got=%s %s", tt.input, diagnostic.Error, tt.code, d.Severity, d.Code)
+		}
+		if d.Span.Start.String() != tt.start || d.Span.End.String() != tt.end {
+			t.Errorf("wrong span for %q. want=%s-%s, got=%s-%s", tt.input, tt.start, tt.end, d.Span.Start, d.Span.End)
+		}
+
+		switch {
+		case tt.fix == "" && d.Fix != nil:
+			t.Errorf("unexpected fix for %q: %+v", tt.input, d.Fix)
+		case tt.fix != "" && (d.Fix == nil || d.Fix.Replacement != tt.fix):
+			t.Errorf("wrong fix for %q. want=%q, got=%+v", tt.input, tt.fix, d.Fix)
+		}
+	}
+}
+
+func TestIncompleteInput(t *testing.T) {
+	tests := []struct {
+		input      string
+		incomplete bool
+	}{
+		{"let f = fn(x) {", true},
+		{"let f = fn(x) {\n  let y = x;\n", true},
+		{"1 +", true},
+		{"let x =", true},
+		{"add(1, 2", true},
+		{"[1, 2", true},
+		{"{\"a\": 1", true},
+		{"if (x) { 1 } else", true},
+		{"for (x in", true},
+		{"fn(a,", true},
+		{`"never closed`, true},
+		{"/* never closed", true},
+		// wrong no matter what comes next
+		{"1 + )", false},
+		{"let x 5", false},
+		{`"bad \q`, false},
+		{"let = 1; fn() {", false},
+		{"while (true) { let = 1;", false},
+		// nothing wrong at all
+		{"let x = 1;", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if p.Incomplete() != tt.incomplete {
+			t.Errorf("wrong Incomplete() for %q. want=%t, got=%t (errors: %q)", tt.input, tt.incomplete, p.Incomplete(), p.Errors())
+		}
+	}
+}
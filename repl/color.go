@@ -0,0 +1,96 @@
+package repl
+
+import (
+    "skibidi/evaluator"
+    "skibidi/lexer"
+    "skibidi/token"
+    "strings"
+)
+
+// the ANSI escape codes used for colors, every colored piece of text ends with colorReset
+const (
+    colorReset      = "\x1b[0m"
+    colorKeyword    = "\x1b[35m" // magenta
+    colorConstant   = "\x1b[34m" // blue, true and false
+    colorNumber     = "\x1b[33m" // yellow
+    colorString     = "\x1b[32m" // green
+    colorBuiltin    = "\x1b[36m" // cyan
+    colorComment    = "\x1b[90m" // gray, also used for notes like how much of a value was left out
+    colorIllegal    = "\x1b[31m" // red
+)
+
+// wraps s in the color, or leaves it alone when colors are off
+func paint(enabled bool, color string, s string) string {
+    if !enabled || color == "" || s == "" {
+        return s
+    }
+    return color + s + colorReset
+}
+
+// the color a token is shown in, "" for the ones that stay plain (identifiers, operators and delimiters)
+func tokenColor(tok token.Token) string {
+    switch tok.Type {
+    case token.INT, token.FLOAT:
+        return colorNumber
+    case token.STRING:
+        return colorString
+    case token.TRUE, token.FALSE:
+        return colorConstant
+    case token.COMMENT:
+        return colorComment
+    case token.ILLEGAL:
+        return colorIllegal
+    case token.IDENT:
+        if _, ok := evaluator.LookupBuiltin(tok.Literal); ok {
+            return colorBuiltin
+        }
+        return ""
+    }
+    if token.LookupIdent(tok.Literal) == tok.Type {
+        return colorKeyword
+    }
+    return ""
+}
+
+// colors a piece of source by running it through the lexer, the text between tokens (whitespace) is copied as is
+// the source doesn't have to parse, or even lex completely, since it's usually a line that's still being typed
+func highlight(src string) string {
+    l := lexer.New(src)
+    l.KeepComments()
+
+    var out strings.Builder
+    done := 0
+    for {
+        tok := l.NextToken()
+        if tok.Type == token.EOF {
+            break
+        }
+        start, end := tok.Pos.Offset, min(tok.End.Offset, len(src))
+        if start < done || end <= start {
+            continue
+        }
+        out.WriteString(src[done:start])
+        out.WriteString(paint(true, tokenColor(tok), src[start:end]))
+        done = end
+    }
+    out.WriteString(src[done:])
+
+    return out.String()
+}
+
+// how many columns s takes up on the terminal, not counting the escape codes
+func visibleWidth(s string) int {
+    width := 0
+    inEscape := false
+    for _, r := range s {
+        switch {
+        case inEscape:
+            inEscape = r != 'm'
+        case r == '\x1b':
+            inEscape = true
+        default:
+            width++
+        }
+    }
+    return width
+}
@@ -0,0 +1,143 @@
+package repl
+
+import (
+    "fmt"
+    "io"
+    "os"
+    "skibidi/ast"
+    "skibidi/lexer"
+    "skibidi/object"
+    "skibidi/token"
+    "strings"
+)
+
+type command struct {
+    name    string
+    args    string // shown in :help, empty if it takes no argument
+    help    string
+    run     func(s *session, arg string) (quit bool)
+}
+
+// a slice rather than a map so :help lists them in this order
+var commands []command
+
+func init() {
+    // set up here instead of where it's declared because :help goes through the list itself
+    commands = []command{
+        {"env", "", "list everything that's bound, with its type", (*session).envCommand},
+        {"type", "<expression>", "evaluate the expression and print the type of its value", (*session).typeCommand},
+        {"ast", "<expression>", "print the tree the parser makes out of the expression", (*session).astCommand},
+        {"tokens", "<expression>", "print the tokens the lexer breaks the expression into", (*session).tokensCommand},
+        {"load", "<file>", "run a file in this session, whatever it binds stays bound", (*session).loadCommand},
+        {"reset", "", "forget everything that's been bound", (*session).resetCommand},
+        {"help", "", "show this list", (*session).helpCommand},
+        {"quit", "", "leave the REPL", func(*session, string) bool { return true }},
+    }
+}
+
+// line is the whole line including the leading :
+func (s *session) command(line string) (quit bool) {
+    name, arg, _ := strings.Cut(strings.TrimPrefix(line, ":"), " ")
+    arg = strings.TrimSpace(arg)
+
+    for _, cmd := range commands {
+        if cmd.name != name {
+            continue
+        }
+        if cmd.args != "" && arg == "" {
+            fmt.Fprintf(s.out, "usage: :%s %s\n", cmd.name, cmd.args)
+            return false
+        }
+        return cmd.run(s, arg)
+    }
+
+    fmt.Fprintf(s.out, "unknown command :%s, :help lists the commands\n", name)
+    return false
+}
+
+func (s *session) envCommand(string) bool {
+    names := s.env.Names()
+    if len(names) == 0 {
+        io.WriteString(s.out, "nothing is bound yet\n")
+        return false
+    }
+
+    width := 0
+    for _, name := range names {
+        width = max(width, len(name))
+    }
+    for _, name := range names {
+        // the evaluator never binds a Go nil, but a Go program can put one in the environment itself
+        value, _ := s.env.Get(name)
+        var typ object.ObjectType = object.NULL_OBJ
+        if value != nil {
+            typ = value.Type()
+        }
+        fmt.Fprintf(s.out, "%-*s  %s\n", width, name, typ)
+    }
+    return false
+}
+
+func (s *session) typeCommand(arg string) bool {
+    program := s.parse(s.nextName(), arg)
+    if program == nil {
+        return false
+    }
+    if evaluated := s.eval(program); evaluated != nil {
+        fmt.Fprintln(s.out, evaluated.Type())
+    }
+    return false
+}
+
+func (s *session) astCommand(arg string) bool {
+    if program := s.parse(s.nextName(), arg); program != nil {
+        io.WriteString(s.out, ast.Dump(program))
+    }
+    return false
+}
+
+// unlike the other commands this works on anything, illegal tokens are just listed and whatever else the lexer found wrong comes after them
+func (s *session) tokensCommand(arg string) bool {
+    l := lexer.NewFile(s.nextName(), arg)
+    for {
+        tok := l.NextToken()
+        fmt.Fprintf(s.out, "%-7s %-10s %q\n", fmt.Sprintf("%d:%d", tok.Pos.Line, tok.Pos.Column), tok.Type, tok.Literal)
+        if tok.Type == token.EOF {
+            break
+        }
+    }
+
+    for _, d := range l.Errors() {
+        fmt.Fprintln(s.out, d.Error())
+    }
+    return false
+}
+
+func (s *session) loadCommand(path string) bool {
+    src, err := os.ReadFile(path)
+    if err != nil {
+        fmt.Fprintf(s.out, "can't load %s: %s\n", path, err)
+        return false
+    }
+
+    if program := s.parse(path, string(src)); program != nil {
+        s.eval(program)
+    }
+    return false
+}
+
+func (s *session) resetCommand(string) bool {
+    s.env = object.NewEnvironment()
+    return false
+}
+
+func (s *session) helpCommand(string) bool {
+    for _, cmd := range commands {
+        usage := ":" + cmd.name
+        if cmd.args != "" {
+            usage += " " + cmd.args
+        }
+        fmt.Fprintf(s.out, "%-22s %s\n", usage, cmd.help)
+    }
+    return false
+}
@@ -0,0 +1,51 @@
+package repl
+
+import (
+    "skibidi/evaluator"
+    "skibidi/token"
+    "sort"
+    "strings"
+)
+
+// the word before the cursor is completed to a keyword, builtin or anything bound in the session
+// (or to a command, if it's the first word after a :)
+func (s *session) complete(line []rune, pos int) (int, []string) {
+    start := pos
+    for start > 0 && isWordRune(line[start-1]) {
+        start--
+    }
+    word := string(line[start:pos])
+
+    var names []string
+    if start == 1 && line[0] == ':' {
+        for _, cmd := range commands {
+            names = append(names, cmd.name)
+        }
+    } else {
+        if word == "" {
+            return start, nil
+        }
+        names = append(names, token.Keywords()...)
+        names = append(names, evaluator.BuiltinNames()...)
+        for env := s.env; env != nil; env = env.Outer() {
+            names = append(names, env.Names()...)
+        }
+    }
+
+    seen := map[string]bool{}
+    candidates := []string{}
+    for _, name := range names {
+        if strings.HasPrefix(name, word) && !seen[name] {
+            seen[name] = true
+            candidates = append(candidates, name)
+        }
+    }
+    sort.Strings(candidates)
+
+    return start, candidates
+}
+
+// the characters an identifier can be made of, same as the lexer
+func isWordRune(r rune) bool {
+    return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '_'
+}
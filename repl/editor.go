@@ -0,0 +1,355 @@
+package repl
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "io"
+    "strings"
+    "unicode"
+)
+
+// returned by readLine when Ctrl-C throws away the line being typed
+var errInterrupted = errors.New("interrupted")
+
+// a key press, either the character it typed or one of the keys below (which are negative so they can't clash with a character)
+// control keys are their ASCII code, see ctrl
+type key rune
+
+const (
+    keyUnknown key = -(iota + 1)
+    keyUp
+    keyDown
+    keyLeft
+    keyRight
+    keyHome
+    keyEnd
+    keyDelete
+)
+
+const (
+    keyTab          key = '\t'
+    keyEnter        key = '\r'
+    keyBackspace    key = 127
+    keyEscape       key = 27
+)
+
+// the key code for Ctrl and the given letter
+func ctrl(c byte) key {
+    return key(c & 0x1f)
+}
+
+// a minimal readline: moving around and editing the line, going through the history with the up and down arrows,
+// searching it with Ctrl-R, and tab completion
+// it reads keys from a terminal that's already in raw mode and redraws the whole line after every key,
+// which keeps it simple but means a line longer than the terminal is wide gets messed up
+type editor struct {
+    in          *bufio.Reader
+    out         io.Writer
+    history     *history
+
+    // the start of the word at the cursor and what it could be completed to
+    complete    func(line []rune, pos int) (start int, candidates []string)
+
+    // colors the line as it's drawn, nil to draw it as is
+    highlight   func(line string) string
+
+    // the line being typed
+    prompt      string
+    buf         []rune
+    pos         int
+}
+
+func newEditor(in io.Reader, out io.Writer, history *history, complete func([]rune, int) (int, []string)) *editor {
+    return &editor{in: bufio.NewReader(in), out: out, history: history, complete: complete}
+}
+
+// reads one line, io.EOF when Ctrl-D is pressed on an empty line and errInterrupted for Ctrl-C
+func (e *editor) readLine(prompt string) (string, error) {
+    e.prompt = prompt
+    e.buf = nil
+    e.pos = 0
+
+    // the line as it was before going back through the history, so going forward again gets it back
+    historyPos := len(e.history.entries)
+    var current []rune
+
+    e.refresh()
+
+    // a key that ended a search is handled as if it was just pressed
+    var pending key
+    for {
+        k := pending
+        pending = 0
+        if k == 0 {
+            var err error
+            if k, err = e.readKey(); err != nil {
+                return "", err
+            }
+        }
+
+        switch k {
+        case keyEnter, '\n':
+            // the line isn't added to the history here, it might only be part of an entry (see lineReader.remember)
+            io.WriteString(e.out, "\r\n")
+            return string(e.buf), nil
+        case ctrl('c'):
+            io.WriteString(e.out, "^C\r\n")
+            return "", errInterrupted
+        case ctrl('d'):
+            if len(e.buf) == 0 {
+                io.WriteString(e.out, "\r\n")
+                return "", io.EOF
+            }
+            e.delete(e.pos)
+        case keyBackspace, ctrl('h'):
+            if e.pos > 0 {
+                e.pos--
+                e.delete(e.pos)
+            }
+        case keyDelete:
+            e.delete(e.pos)
+        case keyLeft, ctrl('b'):
+            e.pos = max(e.pos-1, 0)
+        case keyRight, ctrl('f'):
+            e.pos = min(e.pos+1, len(e.buf))
+        case keyHome, ctrl('a'):
+            e.pos = 0
+        case keyEnd, ctrl('e'):
+            e.pos = len(e.buf)
+        case ctrl('k'):
+            e.buf = e.buf[:e.pos]
+        case ctrl('u'):
+            e.buf = e.buf[e.pos:]
+            e.pos = 0
+        case ctrl('w'):
+            start := e.pos
+            for start > 0 && e.buf[start-1] == ' ' {
+                start--
+            }
+            for start > 0 && e.buf[start-1] != ' ' {
+                start--
+            }
+            e.buf = append(e.buf[:start], e.buf[e.pos:]...)
+            e.pos = start
+        case keyUp, ctrl('p'):
+            if historyPos > 0 {
+                if historyPos == len(e.history.entries) {
+                    current = e.buf
+                }
+                historyPos--
+                e.setLine([]rune(e.history.entries[historyPos]))
+            }
+        case keyDown, ctrl('n'):
+            if historyPos < len(e.history.entries) {
+                historyPos++
+                if historyPos == len(e.history.entries) {
+                    e.setLine(current)
+                } else {
+                    e.setLine([]rune(e.history.entries[historyPos]))
+                }
+            }
+        case keyTab:
+            e.completeWord()
+        case ctrl('r'):
+            var err error
+            if pending, err = e.reverseSearch(); err != nil {
+                return "", err
+            }
+        case ctrl('l'):
+            io.WriteString(e.out, "\x1b[H\x1b[2J")
+        default:
+            if k >= 0 && unicode.IsPrint(rune(k)) {
+                e.insert(rune(k))
+            }
+        }
+
+        e.refresh()
+    }
+}
+
+func (e *editor) readKey() (key, error) {
+    r, _, err := e.in.ReadRune()
+    if err != nil {
+        return 0, err
+    }
+    if key(r) != keyEscape {
+        return key(r), nil
+    }
+
+    // a terminal sends the whole escape sequence at once, so the rest of it is already waiting if there is one
+    // if nothing is, Esc was pressed on its own, and waiting for more would swallow whatever key comes next
+    if e.in.Buffered() == 0 {
+        return keyEscape, nil
+    }
+
+    // the arrow keys and friends send an escape sequence, ESC [ or ESC O followed by some parameters and a final letter
+    r, _, err = e.in.ReadRune()
+    if err != nil {
+        return 0, err
+    }
+    if r != '[' && r != 'O' {
+        return keyUnknown, nil
+    }
+
+    var params strings.Builder
+    for {
+        r, _, err = e.in.ReadRune()
+        if err != nil {
+            return 0, err
+        }
+        if r >= 0x40 && r <= 0x7e {
+            break
+        }
+        params.WriteRune(r)
+    }
+
+    switch r {
+    case 'A':
+        return keyUp, nil
+    case 'B':
+        return keyDown, nil
+    case 'C':
+        return keyRight, nil
+    case 'D':
+        return keyLeft, nil
+    case 'H':
+        return keyHome, nil
+    case 'F':
+        return keyEnd, nil
+    case '~':
+        // different terminals disagree on which numbers home and end are
+        switch params.String() {
+        case "1", "7":
+            return keyHome, nil
+        case "4", "8":
+            return keyEnd, nil
+        case "3":
+            return keyDelete, nil
+        }
+    }
+    return keyUnknown, nil
+}
+
+// draws the prompt and the line over whatever was there and puts the cursor where it belongs
+func (e *editor) refresh() {
+    line := string(e.buf)
+    if e.highlight != nil {
+        line = e.highlight(line)
+    }
+    fmt.Fprintf(e.out, "\r%s%s\x1b[K", e.prompt, oneLine(line))
+    if back := len(e.buf) - e.pos; back > 0 {
+        fmt.Fprintf(e.out, "\x1b[%dD", back)
+    }
+}
+
+// an entry from the history can go over several lines, but the editor only draws one
+// so the newlines are shown as a character that takes up one column, the same as the newline does in the buffer
+func oneLine(s string) string {
+    return strings.ReplaceAll(s, "\n", "↵")
+}
+
+func (e *editor) setLine(line []rune) {
+    e.buf = append([]rune(nil), line...)
+    e.pos = len(e.buf)
+}
+
+func (e *editor) insert(runes ...rune) {
+    e.buf = append(e.buf[:e.pos], append(runes, e.buf[e.pos:]...)...)
+    e.pos += len(runes)
+}
+
+func (e *editor) delete(i int) {
+    if i < len(e.buf) {
+        e.buf = append(e.buf[:i], e.buf[i+1:]...)
+    }
+}
+
+// one candidate is filled in, several are filled in as far as they agree and listed if that doesn't get any further
+func (e *editor) completeWord() {
+    start, candidates := e.complete(e.buf, e.pos)
+    if len(candidates) == 0 {
+        io.WriteString(e.out, "\a")
+        return
+    }
+
+    word := string(e.buf[start:e.pos])
+    prefix := candidates[0]
+    for _, c := range candidates[1:] {
+        for !strings.HasPrefix(c, prefix) {
+            prefix = prefix[:len(prefix)-1]
+        }
+    }
+
+    if len(candidates) == 1 {
+        e.insert([]rune(strings.TrimPrefix(prefix, word) + " ")...)
+        return
+    }
+    if len(prefix) > len(word) {
+        e.insert([]rune(strings.TrimPrefix(prefix, word))...)
+        return
+    }
+
+    // the list goes under the line, refresh draws the line again below it
+    io.WriteString(e.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+}
+
+// Ctrl-R, goes back through the history for entries that contain what's been typed so far
+// pressing Ctrl-R again finds the next older match, Ctrl-G or Ctrl-C gives up and leaves the line as it was
+// any other key takes the match and is handed back to be handled as usual (so Enter runs the match straight away)
+func (e *editor) reverseSearch() (key, error) {
+    entries := e.history.entries
+    var query []rune
+    match := len(entries)
+
+    // looks for the query in the entries before from, leaves match alone if there isn't one
+    find := func(from int) bool {
+        for i := min(from, len(entries)) - 1; i >= 0; i-- {
+            if strings.Contains(entries[i], string(query)) {
+                match = i
+                return true
+            }
+        }
+        return false
+    }
+
+    found := true
+    for {
+        shown := ""
+        if match < len(entries) {
+            shown = entries[match]
+        }
+        status := "reverse-i-search"
+        if !found {
+            status = "failed " + status
+        }
+        fmt.Fprintf(e.out, "\r(%s)`%s': %s\x1b[K", status, string(query), oneLine(shown))
+
+        k, err := e.readKey()
+        if err != nil {
+            return 0, err
+        }
+
+        switch {
+        case k == ctrl('r'):
+            found = find(match)
+        case k == keyBackspace || k == ctrl('h'):
+            if len(query) > 0 {
+                query = query[:len(query)-1]
+                match = len(entries)
+                found = len(query) == 0 || find(len(entries))
+            }
+        case k == ctrl('g') || k == ctrl('c'):
+            return 0, nil
+        case k >= 0 && unicode.IsPrint(rune(k)):
+            query = append(query, rune(k))
+            // a longer query can still match the entry that's showing
+            found = find(match + 1)
+        default:
+            if match < len(entries) {
+                e.setLine([]rune(entries[match]))
+            }
+            return k, nil
+        }
+    }
+}
@@ -0,0 +1,218 @@
+package repl
+
+import (
+    "io"
+    "os"
+    "path/filepath"
+    "skibidi/object"
+    "strings"
+    "testing"
+)
+
+const (
+    up      = "\x1b[A"
+    down    = "\x1b[B"
+    left    = "\x1b[D"
+    right   = "\x1b[C"
+    home    = "\x1b[H"
+    del     = "\x1b[3~"
+)
+
+func testEditor(input string, entries ...string) *editor {
+    s := &session{env: object.NewEnvironment()}
+    return newEditor(strings.NewReader(input), io.Discard, &history{entries: entries}, s.complete)
+}
+
+func TestEditorKeys(t *testing.T) {
+    tests := []struct {
+        keys        string
+        expected    string
+    }{
+        {"let x\r", "let x"},
+        {"abc\x7f\x7fd\r", "ad"},
+        {"world" + home + "hello \r", "hello world"},
+        {"ac" + left + "b\r", "abc"},
+        {"abc" + left + left + right + "X\r", "abXc"},
+        {"abc\x01\x04\r", "bc"},
+        {"abc" + left + left + del + "\r", "ac"},
+        {"hello world\x01\x06\x06\x0b\r", "he"},
+        {"hello world\x02\x02\x15\r", "ld"},
+        {"let x = 1\x17\x17\r", "let x "},
+        {"abc\x01\x05d\r", "abcd"},
+        // the escape sequence for a key we don't know about does nothing
+        {"a\x1b[15~b\r", "ab"},
+    }
+
+    for _, tt := range tests {
+        line, err := testEditor(tt.keys).readLine(">>")
+        if err != nil || line != tt.expected {
+            t.Errorf("wrong line for %q, expected %q, got %q (%v)", tt.keys, tt.expected, line, err)
+        }
+    }
+}
+
+// hands out one key press per Read, the way a terminal in raw mode does
+type keyPresses []string
+
+func (k *keyPresses) Read(p []byte) (int, error) {
+    if len(*k) == 0 {
+        return 0, io.EOF
+    }
+    n := copy(p, (*k)[0])
+    if (*k)[0] = (*k)[0][n:]; (*k)[0] == "" {
+        *k = (*k)[1:]
+    }
+    return n, nil
+}
+
+func TestEditorEscape(t *testing.T) {
+    tests := []struct {
+        keys        keyPresses
+        expected    string
+    }{
+        // Esc on its own doesn't wait for another key (and then eat it)
+        {keyPresses{"ab", "\x1b", "c", "\r"}, "abc"},
+        {keyPresses{"ac", left, "b", "\x1b", "\x1b", right, "d\r"}, "abcd"},
+        // it ends a search like any other key, keeping the match
+        {keyPresses{"\x12", "sec", "\x1b", "!", "\r"}, "second!"},
+    }
+
+    for _, tt := range tests {
+        s := &session{env: object.NewEnvironment()}
+        keys := append(keyPresses{}, tt.keys...)
+        e := newEditor(&keys, io.Discard, &history{entries: []string{"first", "second"}}, s.complete)
+        line, err := e.readLine(">>")
+        if err != nil || line != tt.expected {
+            t.Errorf("wrong line for %q, expected %q, got %q (%v)", tt.keys, tt.expected, line, err)
+        }
+    }
+}
+
+func TestEditorHistory(t *testing.T) {
+    tests := []struct {
+        keys        string
+        expected    string
+    }{
+        {up + "\r", "third"},
+        {up + up + up + up + "\r", "first"},
+        {up + up + down + "\r", "third"},
+        // going back down past the newest entry gets back what was being typed
+        {"unfinished" + up + up + down + down + "\r", "unfinished"},
+        {up + "!\r", "third!"},
+        {"\x12sec\r", "second"},
+        {"\x12i\x12\r", "first"},
+        {"\x12ir\x7f\x7fd\r", "third"},
+        // a key that isn't part of the search takes the match and is then handled as usual
+        {"\x12sec\x01x\r", "xsecond"},
+        {"typed\x12sec\x07\r", "typed"},
+        {"typed\x12zzz\r", "typed"},
+        // once the search fails the last match is kept, same as bash
+        {"\x12secx\r", "second"},
+    }
+
+    for _, tt := range tests {
+        line, err := testEditor(tt.keys, "first", "second", "third").readLine(">>")
+        if err != nil || line != tt.expected {
+            t.Errorf("wrong line for %q, expected %q, got %q (%v)", tt.keys, tt.expected, line, err)
+        }
+    }
+
+    // an entry that went over several lines comes back whole, and the editor leaves adding to the history to the REPL
+    for _, keys := range []string{up + up + "\r", "\x12{\r"} {
+        e := testEditor(keys, "let f = fn() {\n  1\n}", "f()")
+        line, err := e.readLine(">>")
+        if err != nil || line != "let f = fn() {\n  1\n}" {
+            t.Errorf("wrong line for %q, expected the whole entry, got %q (%v)", keys, line, err)
+        }
+        if len(e.history.entries) != 2 {
+            t.Errorf("expected the history to be left alone, got %q", e.history.entries)
+        }
+    }
+}
+
+func TestEditorCompletion(t *testing.T) {
+    tests := []struct {
+        keys        string
+        expected    string
+    }{
+        {"ret\t1\r", "return 1"},
+        {"le\t\r", "le"},
+        {"pu\t\t\r", "pu"},
+        {"pus\t\r", "push "},
+        {":he\t\r", ":help "},
+        {"zzz\t\r", "zzz"},
+    }
+
+    for _, tt := range tests {
+        line, err := testEditor(tt.keys).readLine(">>")
+        if err != nil || line != tt.expected {
+            t.Errorf("wrong line for %q, expected %q, got %q (%v)", tt.keys, tt.expected, line, err)
+        }
+    }
+}
+
+func TestEditorEndsInput(t *testing.T) {
+    e := testEditor("abc\x03\x04")
+    if _, err := e.readLine(">>"); err != errInterrupted {
+        t.Errorf("expected Ctrl-C to interrupt, got: %v", err)
+    }
+    if _, err := e.readLine(">>"); err != io.EOF {
+        t.Errorf("expected Ctrl-D on an empty line to end the input, got: %v", err)
+    }
+}
+
+func TestComplete(t *testing.T) {
+    s := &session{env: object.NewEnvironment()}
+    s.env.Set("length", &object.Integer{Value: 1})
+    s.env.Set("letter", &object.Integer{Value: 2})
+
+    tests := []struct {
+        line        string
+        start       int
+        candidates  string
+    }{
+        {"le", 0, "len length let letter"},
+        {"1 + lengt", 4, "length"},
+        {"fo", 0, "for"},
+        {":lo", 1, "load"},
+        {"x = ", 4, ""},
+    }
+
+    for _, tt := range tests {
+        line := []rune(tt.line)
+        start, candidates := s.complete(line, len(line))
+        if start != tt.start || strings.Join(candidates, " ") != tt.candidates {
+            t.Errorf("wrong completion for %q, expected %d %q, got %d %q", tt.line, tt.start, tt.candidates, start, candidates)
+        }
+    }
+}
+
+func TestHistoryFile(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "skibidi", "history")
+
+    h := loadHistory(path)
+    h.add("let x = 1;")
+    h.add("let x = 1;")
+    h.add("   ")
+    h.add("x")
+    h.add("let f = fn() {\n  \"a\\n\\\\\"\n}")
+
+    h = loadHistory(path)
+    if strings.Join(h.entries, "|") != "let x = 1;|x|let f = fn() {\n  \"a\\n\\\\\"\n}" {
+        t.Errorf("wrong history after loading it again, got: %q", h.entries)
+    }
+
+    // only the newest entries are kept once there are too many
+    lines := make([]string, maxHistory+10)
+    for i := range lines {
+        lines[i] = strings.Repeat("a", i+1)
+    }
+    os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+    h = loadHistory(path)
+    if len(h.entries) != maxHistory || h.entries[0] != lines[10] {
+        t.Errorf("expected the oldest entries to be dropped, got %d entries starting with %q", len(h.entries), h.entries[0])
+    }
+    if h = loadHistory(path); len(h.entries) != maxHistory {
+        t.Errorf("expected the file to be trimmed, got %d entries", len(h.entries))
+    }
+}
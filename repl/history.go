@@ -0,0 +1,104 @@
+package repl
+
+import (
+    "bufio"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// how many entries are kept, older ones are dropped from the file the next time it's loaded
+const maxHistory = 1000
+
+// the inputs typed into the REPL, oldest first, saved to a file so they're still there next time
+// the file is just one entry per line, every new entry is appended to it straight away
+// an entry that went over several lines has its newlines written as \n (and backslashes as \\) to keep it on one
+// history is a nice to have, so a file that can't be read or written is quietly ignored
+type history struct {
+    entries []string
+    path    string // empty when the history isn't saved anywhere
+}
+
+// where the history is saved by default, empty if the system doesn't have a config directory
+func DefaultHistoryFile() string {
+    dir, err := os.UserConfigDir()
+    if err != nil {
+        return ""
+    }
+    return filepath.Join(dir, "skibidi", "history")
+}
+
+func loadHistory(path string) *history {
+    h := &history{path: path}
+    if path == "" {
+        return h
+    }
+
+    f, err := os.Open(path)
+    if err != nil {
+        return h
+    }
+    scanner := bufio.NewScanner(f)
+    for scanner.Scan() {
+        h.entries = append(h.entries, decodeEntry(scanner.Text()))
+    }
+    f.Close()
+
+    if len(h.entries) > maxHistory {
+        h.entries = h.entries[len(h.entries)-maxHistory:]
+        h.save()
+    }
+    return h
+}
+
+// blank lines and the same line twice in a row aren't worth remembering
+func (h *history) add(entry string) {
+    if strings.TrimSpace(entry) == "" || len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry {
+        return
+    }
+    h.entries = append(h.entries, entry)
+
+    if h.path == "" {
+        return
+    }
+    if err := os.MkdirAll(filepath.Dir(h.path), 0o700); err != nil {
+        return
+    }
+    f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+    if err != nil {
+        return
+    }
+    f.WriteString(encodeEntry(entry) + "\n")
+    f.Close()
+}
+
+// writes out the whole history, replacing what's in the file
+func (h *history) save() {
+    var out strings.Builder
+    for _, entry := range h.entries {
+        out.WriteString(encodeEntry(entry) + "\n")
+    }
+    os.WriteFile(h.path, []byte(out.String()), 0o600)
+}
+
+var entryEncoder = strings.NewReplacer("\\", "\\\\", "\n", "\\n")
+
+func encodeEntry(entry string) string {
+    return entryEncoder.Replace(entry)
+}
+
+// a backslash followed by anything other than n or another backslash is left alone
+func decodeEntry(line string) string {
+    var out strings.Builder
+    for i := 0; i < len(line); i++ {
+        if line[i] == '\\' && i+1 < len(line) && (line[i+1] == 'n' || line[i+1] == '\\') {
+            i++
+            if line[i] == 'n' {
+                out.WriteByte('\n')
+                continue
+            }
+        }
+        out.WriteByte(line[i])
+    }
+    return out.String()
+}
@@ -0,0 +1,182 @@
+package repl
+
+import (
+    "fmt"
+    "skibidi/ast"
+    "skibidi/object"
+    "strings"
+    "unicode/utf8"
+)
+
+// how much of a value is printed before the rest is left out, a result can easily be an array of a million elements
+const (
+    maxWidth    = 80   // an array or hash that fits on a line this long stays on one line
+    maxItems    = 100  // elements of an array, pairs of a hash, statements of a function
+    maxString   = 200  // characters of a string inside an array or hash
+    maxDepth    = 6    // arrays and hashes nested deeper than this are shown as [...] and {...}
+)
+
+// prints REPL results: colored, nested arrays and hashes spread over several lines when they don't fit on one,
+// functions with their statements one per line, and anything too big cut short with a note saying how much is missing
+type printer struct {
+    color bool
+
+    // the arrays and hashes being printed further up, an array that holds itself is printed as [...] where it repeats
+    seen  map[object.Object]bool
+}
+
+func (p *printer) print(obj object.Object) string {
+    switch obj := obj.(type) {
+    case *object.String:
+        // a string on its own comes out as is, same as puts, it's only quoted inside an array or hash
+        return obj.Value
+    case *object.Function:
+        return p.function(obj.Name, obj.Parameters, obj.Defaults, obj.Rest, obj.Body)
+    case *object.Closure:
+        return p.function(obj.Fn.Name, obj.Fn.Parameters, obj.Fn.Defaults, obj.Fn.Rest, obj.Fn.Body)
+    }
+    return p.value(obj, "", 0)
+}
+
+// indent is what the line obj starts on is indented by, the lines after the first one are indented to match
+func (p *printer) value(obj object.Object, indent string, depth int) string {
+    switch obj := obj.(type) {
+    case *object.Integer, *object.BigInt, *object.Float:
+        return p.paint(colorNumber, obj.Inspect())
+    case *object.Boolean, *object.Null:
+        return p.paint(colorConstant, obj.Inspect())
+    case *object.String:
+        return p.string(obj.Value)
+    case *object.Builtin:
+        return p.paint(colorBuiltin, obj.Inspect())
+    case *object.Function:
+        return p.signature(obj.Name, obj.Parameters, obj.Defaults, obj.Rest) + " { ... }"
+    case *object.Closure:
+        return p.signature(obj.Fn.Name, obj.Fn.Parameters, obj.Fn.Defaults, obj.Fn.Rest) + " { ... }"
+    case *object.Array:
+        if len(obj.Elements) == 0 {
+            return "[]"
+        }
+        if depth >= maxDepth || p.seen[obj] {
+            return "[" + p.paint(colorComment, "...") + "]"
+        }
+        p.enter(obj)
+        defer delete(p.seen, obj)
+        items := []string{}
+        for i, e := range obj.Elements {
+            if i == maxItems {
+                items = append(items, p.more(len(obj.Elements)-i, "element"))
+                break
+            }
+            items = append(items, p.value(e, indent+"  ", depth+1))
+        }
+        return p.group("[", "]", items, indent)
+    case *object.Hash:
+        if len(obj.Pairs) == 0 {
+            return "{}"
+        }
+        if depth >= maxDepth || p.seen[obj] {
+            return "{" + p.paint(colorComment, "...") + "}"
+        }
+        p.enter(obj)
+        defer delete(p.seen, obj)
+        items := []string{}
+        for i, pair := range obj.SortedPairs() {
+            if i == maxItems {
+                items = append(items, p.more(len(obj.Pairs)-i, "pair"))
+                break
+            }
+            key := p.value(pair.Key, indent+"  ", depth+1)
+            items = append(items, key+": "+p.value(pair.Value, indent+"  ", depth+1))
+        }
+        return p.group("{", "}", items, indent)
+    }
+    return obj.Inspect()
+}
+
+func (p *printer) enter(obj object.Object) {
+    if p.seen == nil {
+        p.seen = map[object.Object]bool{}
+    }
+    p.seen[obj] = true
+}
+
+// the items on one line if they fit, otherwise one per line
+func (p *printer) group(open string, close string, items []string, indent string) string {
+    line := open + strings.Join(items, ", ") + close
+    if !strings.Contains(line, "\n") && len(indent)+visibleWidth(line) <= maxWidth {
+        return line
+    }
+
+    var out strings.Builder
+    out.WriteString(open + "\n")
+    for i, item := range items {
+        out.WriteString(indent + "  " + item)
+        if i < len(items)-1 {
+            out.WriteString(",")
+        }
+        out.WriteString("\n")
+    }
+    out.WriteString(indent + close)
+
+    return out.String()
+}
+
+func (p *printer) string(s string) string {
+    n := utf8.RuneCountInString(s)
+    if n <= maxString {
+        return p.paint(colorString, ast.QuoteString(s))
+    }
+    cut := string([]rune(s)[:maxString])
+    return p.paint(colorString, ast.QuoteString(cut+"...")) + " " + p.paint(colorComment, fmt.Sprintf("(%d more characters)", n-maxString))
+}
+
+func (p *printer) signature(name string, parameters []*ast.Identifier, defaults []ast.Expression, rest *ast.Identifier) string {
+    s := p.paint(colorKeyword, "fn")
+    if name != "" {
+        s += " " + name
+    }
+    params := ast.ParameterStrings(parameters, defaults, rest)
+    return s + "(" + p.source(strings.Join(params, ", ")) + ")"
+}
+
+// a function with its statements one per line, highlighted like the input is
+func (p *printer) function(name string, parameters []*ast.Identifier, defaults []ast.Expression, rest *ast.Identifier, body *ast.BlockStatement) string {
+    var out strings.Builder
+    out.WriteString(p.signature(name, parameters, defaults, rest))
+    if body == nil || len(body.Statements) == 0 {
+        out.WriteString(" {}")
+        return out.String()
+    }
+
+    out.WriteString(" {\n")
+    for i, stmt := range body.Statements {
+        if i == maxItems {
+            out.WriteString("  " + p.more(len(body.Statements)-i, "statement") + "\n")
+            break
+        }
+        out.WriteString("  " + p.source(stmt.String()) + "\n")
+    }
+    out.WriteString("}")
+
+    return out.String()
+}
+
+// the note that takes the place of what was left out, like "... 5 more elements"
+func (p *printer) more(n int, what string) string {
+    if n != 1 {
+        what += "s"
+    }
+    return p.paint(colorComment, fmt.Sprintf("... %d more %s", n, what))
+}
+
+func (p *printer) source(src string) string {
+    if !p.color {
+        return src
+    }
+    return highlight(src)
+}
+
+func (p *printer) paint(color string, s string) string {
+    return paint(p.color, color, s)
+}
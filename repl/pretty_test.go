@@ -0,0 +1,74 @@
+package repl
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+)
+
+func TestPrettyPrint(t *testing.T) {
+    long := "[" + strings.Repeat("1, ", 104) + "1]"
+
+    tests := []struct {
+        input       string
+        expected    string
+    }{
+        {`"a"`, "a"},
+        {`[1, "a", true, [], {}]`, `[1, "a", true, [], {}]`},
+        {`{"b": 2, "a": [1]}`, `{"a": [1], "b": 2}`},
+        // too wide for one line, so one element per line and the nested ones only break if they have to
+        {`[["` + strings.Repeat("x", 40) + `"], ["` + strings.Repeat("y", 40) + `"]]`,
+            "[\n  [\"" + strings.Repeat("x", 40) + "\"],\n  [\"" + strings.Repeat("y", 40) + "\"]\n]"},
+        {long, "[\n" + strings.Repeat("  1,\n", 100) + "  ... 5 more elements\n]"},
+        {`["` + strings.Repeat("z", 205) + `"]`, "[\n  \"" + strings.Repeat("z", 200) + "...\" (5 more characters)\n]"},
+        // strings are quoted the way they'd be written in skibidi
+        {`["é\u{7}\t"]`, `["é\u{7}\t"]`},
+        {"[[[[[[[1]]]]]]]", "[[[[[[[...]]]]]]]"},
+        {"let add = fn(a, b = 2) { let c = a + b; c * 2 }; add", "fn add(a, b = 2) {\n  let c = (a + b);\n  (c * 2)\n}"},
+        {"[fn(x) { x }, len]", "[fn(x) { ... }, builtin function len]"},
+        {"let a = [1]; a[0] = a; let h = {}; h[1] = h; [a, h]", "[[[...]], {1: {...}}]"},
+    }
+
+    for _, tt := range tests {
+        var out bytes.Buffer
+        Start(strings.NewReader(tt.input+"\n"), &out, Options{})
+
+        expected := ">>" + tt.expected + "\n>>"
+        if out.String() != expected {
+            t.Errorf("wrong output for %q, expected %q, got %q", tt.input, expected, out.String())
+        }
+    }
+}
+
+func TestColor(t *testing.T) {
+    var out bytes.Buffer
+    Start(strings.NewReader("[1, \"a\", true]\n"), &out, Options{Color: true})
+
+    expected := ">>[\x1b[33m1\x1b[0m, \x1b[32m\"a\"\x1b[0m, \x1b[34mtrue\x1b[0m]\n>>"
+    if out.String() != expected {
+        t.Errorf("wrong output, expected %q, got %q", expected, out.String())
+    }
+}
+
+func TestHighlight(t *testing.T) {
+    tests := []struct {
+        input       string
+        expected    string
+    }{
+        {"let x = 1.5;", "\x1b[35mlet\x1b[0m x = \x1b[33m1.5\x1b[0m;"},
+        {`len("hi")  // two`, "\x1b[36mlen\x1b[0m(\x1b[32m\"hi\"\x1b[0m)  \x1b[90m// two\x1b[0m"},
+        // a line that's still being typed, the string that isn't closed yet shows up as a mistake until it is
+        {`if (x) { puts("ab`, "\x1b[35mif\x1b[0m (x) { \x1b[36mputs\x1b[0m(\x1b[31m\"ab\x1b[0m"},
+        {"x @ false", "x \x1b[31m@\x1b[0m \x1b[34mfalse\x1b[0m"},
+    }
+
+    for _, tt := range tests {
+        got := highlight(tt.input)
+        if got != tt.expected {
+            t.Errorf("wrong highlighting for %q, expected %q, got %q", tt.input, tt.expected, got)
+        }
+        if visibleWidth(got) != len(tt.input) {
+            t.Errorf("highlighting %q changed its width to %d", tt.input, visibleWidth(got))
+        }
+    }
+}
@@ -2,93 +2,227 @@ package repl
 
 import (
     "bufio"
+    "context"
     "fmt"
     "io"
+    "skibidi/ast"
+    "skibidi/diagnostic"
     "skibidi/lexer"
     "skibidi/parser"
     "skibidi/evaluator"
     "skibidi/object"
+    "skibidi/vm"
+    "os"
+    "strings"
 )
 
-const SKIBIDI_ASCII = `⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⡀⠄⠒⠒⠀⠀⠒⠂⠠⢀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀
-⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⡠⠂⠁⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠈⠀⢄⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀
-⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⢀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠑⢄⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀
-⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⡠⠀⠀⣐⡄⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⣀⣠⠀⠡⡀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀
-⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⡐⠀⠀⠈⠋⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠉⠁⠀⠀⠐⠄⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀
-⠀⢀⠄⠂⠠⢀⡀⠀⠀⠀⠀⠔⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠨⡄⠀⠀⠀⠀⢀⠠⠐⠒⠐⠄
-⢠⠁⠀⠀⠀⠀⠀⠈⠉⠉⠉⠀⠀⠀⠀⠀⣀⣤⣶⣿⣿⣿⣿⣿⣿⣶⣦⣄⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠈⡤⡀⠁⠉⠀⠀⠀⠀⠀⠈
-⢸⠀⠀⠀⠀⠀⠀⠀⠀⠐⠀⠀⠀⠀⣠⣾⣿⣿⣿⣿⣿⣿⣿⣿⣿⣿⣿⣿⣷⣆⠀⠀⠀⠀⠀⠀⠀⠀⠀⢱⠰⠀⠀⠀⠀⠀⠀⠀⢨
-⠘⠄⠤⠀⠀⠀⠀⠀⠀⡆⠀⠀⢀⣼⣿⣿⠿⠿⠛⠻⠛⠛⠛⠙⠛⠛⠋⠩⠝⣿⣷⡄⠀⠀⠀⠀⠀⠀⠀⢘⣿⡶⣶⠲⢶⣴⣦⡄⠁
-⠀⠀⠇⠀⠀⠀⠠⠔⢈⠁⠁⠀⣾⣿⡏⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⢃⡹⣿⣿⣷⠀⠀⠀⠀⠀⠀⠀⠀⣿⣝⡾⣑⢎⡷⣏⡇⠀
-⠀⠀⠏⠛⠓⠻⠷⠿⢿⠀⠀⠀⣿⣿⠇⠀⠀⠀⠀⣀⣠⣄⣤⣄⣀⣀⣀⠀⠸⣽⣿⣿⠀⠀⠀⠀⠀⠀⠀⠀⣽⡾⣝⣳⢎⡿⡥⠂⠀
-⠀⠀⢰⠀⠀⠀⠀⠀⢘⠀⠀⠀⢿⣿⢰⣤⣴⣶⣿⣿⣿⣿⣿⣿⣿⣿⣿⣿⣿⣿⣿⡿⠀⠀⠀⠀⠀⠀⠀⠀⣿⡽⣯⡽⢾⣹⡓⠀⠀
-⠀⠀⢸⠀⠀⠀⠀⠀⠨⠀⡀⢠⡘⣿⠸⣿⣿⡟⣋⢿⣿⡿⠙⣿⣿⡿⣽⢻⣿⡿⢽⣷⡇⠀⠀⠀⠀⠀⠀⢠⣿⣟⣷⡻⣝⣧⢹⠀⠀
-⠀⠀⠘⡀⠀⠀⠀⠀⠀⡄⠙⠀⣿⣿⠀⠈⠛⠂⠡⠾⠟⠁⠀⡨⠟⠓⣖⣋⡁⣤⣿⢿⡇⠀⠀⠀⠀⠀⠀⢸⣿⣞⣷⡻⣽⡺⡥⠀⠀
-⠀⠀⠀⡄⠀⠀⠀⠀⠀⡇⠀⠀⢹⣯⡦⣤⣦⣤⡄⣃⠀⠀⠀⢴⣛⣶⢏⣾⣿⣿⣿⣻⠁⠀⠀⠀⠀⠀⠀⣸⣿⢾⣳⣟⡷⡽⡁⠀⠀
-⠀⠀⠀⠇⠀⠀⠀⠀⠀⢡⢸⣡⡍⢳⣿⣳⣭⡷⡤⠝⣤⣁⣀⣺⣿⢻⢾⣭⣿⣿⡿⠃⠀⠀⠀⠰⣭⣱⢀⣿⣿⣻⡽⣾⣝⣷⠁⠀⠀
-⠀⠀⠀⢰⠀⠀⢀⢀⠀⢈⡆⠁⠀⠀⠸⣷⣻⢿⣿⣶⣼⣿⣿⣿⣯⣿⣿⣿⣿⣿⠇⠀⠀⠀⠀⠀⠉⠀⣼⣿⡷⣿⣽⣳⢯⠸⠀⠀⠀
-⠀⠀⠀⠈⡄⠀⠀⢢⠳⡠⢼⠀⠀⠀⠀⢿⣶⣂⢿⣿⣏⢙⠈⠙⢻⣿⣿⣿⣿⡟⠀⠀⠀⠀⠀⠀⠀⢰⣿⣿⣽⡷⣯⣟⠯⡆⠀⠀⠀
-⠀⠀⠀⠀⠰⠀⠀⠀⡑⢝⢦⣇⠀⠀⠀⠘⣿⣿⣦⡹⢿⣶⣶⣶⢿⣿⣿⣿⣿⠁⠀⠀⠀⠀⠀⠀⢠⣿⣿⣟⣾⣿⣻⣞⢷⠀⠀⠀⠀
-⠀⠀⠀⠀⠀⠇⠀⠶⡈⡎⣷⡾⣷⡀⠀⠀⣿⣿⣿⣿⣆⠈⣀⣈⣿⣿⣿⣿⡇⠀⠀⠀⠀⠀⠀⣰⣿⣿⣿⣿⡿⣷⣿⡎⡆⠀⠀⠀⠀
-⠀⠀⠀⠀⢀⠈⠮⠵⠵⠎⠵⠛⠛⠛⠛⠻⢻⣿⣿⣿⣿⣿⣿⣿⣿⣿⣿⣿⠷⠶⠶⠶⠾⠿⠿⠿⠿⠿⠿⠽⣿⣟⢗⠜⠀⠀⠀⠀⠀
-⠀⠀⠀⠀⣅⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠈⠉⠉⠉⠉⠉⠉⠉⠉⠉⠉⠁⠀⠀⠀⠀⠀⠀⢀⠀⣀⣀⣀⣀⡈⠎⠁⠀⠀⠀⠀⠀⠀
-⠀⠀⠀⠈⠀⠀⠉⠉⠉⠀⠀⠀⠀⠒⠒⠒⠒⠒⠒⠒⠒⠒⠒⠒⠒⠒⠒⠂⠐⠀⠈⠀⠉⠉⠉⠉⠀⠀⠀⠀⠐⢰⠀⠀⠀⠀⠀⠀⠀
-⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⡀⢄⡰⢠⢒⡰⢂⡖⣐⠺⠀⠀⠀⠀⠀⠀⠀
-⠀⠀⠀⢀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⣀⠠⢄⢢⡱⣘⢦⡜⣣⢮⡵⣫⡼⣧⢹⠀⠀⠀⠀⠀⠀⠀
-⠀⠀⠀⠀⢱⡰⢶⡖⣦⢤⣤⣤⣤⣤⣄⣀⣀⣀⣀⣀⣀⣀⣄⣤⣔⣦⣳⢦⣟⣮⣷⣻⣽⣞⣿⡽⣯⣿⣟⣿⠫⠁⠀⠀⠀⠀⠀⠀⠀
-⠀⠀⠀⠀⠀⢇⠢⡙⢤⠛⣼⢳⣻⢮⣝⡯⣽⣹⢮⡽⣭⣛⢮⣗⡻⣞⡽⣯⣻⠷⣯⢷⣻⢾⣽⣛⣷⣻⣞⢧⠁⠀⠀⠀⠀⠀⠀⠀⠀
-⠀⠀⠀⠀⠀⠘⡵⣹⢦⣛⢦⣏⣷⣻⢮⡽⣶⣛⣮⢗⡷⣫⣟⡼⣝⣧⠿⣵⢯⡿⣭⢿⣭⣟⡾⣽⡞⣷⢏⠆⠀⠀⠀⠀⠀⠀⠀⠀⠀
-⠀⠀⠀⠀⠀⠀⠸⣟⣯⣟⡿⣞⡷⣯⢿⡽⣞⣳⣭⢿⣹⣗⡾⣝⡾⣞⣻⡽⣾⡽⢯⣷⣛⡾⣽⣳⢿⢙⠊⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀
-⠀⠀⠀⠀⠀⠀⠀⠈⠪⠿⣽⣯⢿⡽⣯⢿⣽⣳⢯⣟⡷⡾⣝⣻⡼⣯⢷⣻⣗⣿⣻⢾⣽⣻⠷⡫⠂⠁⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀
-⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠑⠩⢟⢿⣽⣟⣾⣽⣟⣾⣽⡿⣽⣷⣻⣽⣯⣷⣻⣾⡽⡛⠎⠃⠁⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀
-⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⢀⠀⠀⠀⠉⠉⠉⠙⠛⠛⡙⢩⠩⣅⠫⢖⡭⣿⢺⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀
-⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠸⠀⠀⠀⠀⠀⠀⠀⠀⠀⠐⠀⠣⡐⢍⠎⡼⣻⡟⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀
-⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⡆⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠈⠄⠱⡈⢎⡱⣝⣏⡇⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀
-⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⢀⠃⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠈⠠⢑⠢⣑⢮⢿⣠⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀
-⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠸⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠡⠌⡒⡡⢞⣯⢯⠱⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀
-⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⡃⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⡐⢢⠁⢧⡙⣮⣟⣾⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀
-⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠇⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⢂⠍⢦⡙⣮⢿⡈⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀
-⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠘⣀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⡌⡘⢦⣙⣾⣋⠁⠀  ,⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀
-`
-
 const PROMPT = ">>"
 
-func Start(in io.Reader, out io.Writer) {
-    scanner := bufio.NewScanner(in)
-    env := object.NewEnvironment()
+// shown instead of PROMPT while the input so far isn't a complete program yet
+const CONTINUATION_PROMPT = ".."
+
+// how the REPL runs what's typed into it
+type Options struct {
+    Overflow    evaluator.Overflow
+    VM          bool   // run the input with the bytecode vm instead of the tree walking evaluator
+    HistoryFile string // where the line editor keeps its history between sessions, empty to not keep it (see DefaultHistoryFile)
+    Color       bool   // highlight the input and color the results, leave it off when the output isn't a terminal
+}
+
+// where the lines typed into the REPL come from
+type lineReader interface {
+    readLine(prompt string) (string, error)
+
+    // adds a whole entry to the history once it's been read, an entry that went over several lines is still one entry
+    remember(entry string)
+}
+
+// used when the input isn't a terminal (or there's no raw mode on this platform), just reads whole lines
+type plainReader struct {
+    scanner *bufio.Scanner
+    out     io.Writer
+}
+
+func (r *plainReader) readLine(prompt string) (string, error) {
+    io.WriteString(r.out, prompt)
+    if !r.scanner.Scan() {
+        if err := r.scanner.Err(); err != nil {
+            return "", err
+        }
+        return "", io.EOF
+    }
+    return r.scanner.Text(), nil
+}
+
+func (r *plainReader) remember(entry string) {}
+
+// the line editor on a terminal, raw mode is only on while a line is being typed so everything printed in between comes out as usual
+type terminalReader struct {
+    fd      uintptr
+    editor  *editor
+}
+
+func (r *terminalReader) readLine(prompt string) (string, error) {
+    restore, err := makeRaw(r.fd)
+    if err != nil {
+        return "", err
+    }
+    defer restore()
+    return r.editor.readLine(prompt)
+}
+
+func (r *terminalReader) remember(entry string) {
+    r.editor.history.add(entry)
+}
+
+func (s *session) newLineReader(in io.Reader) lineReader {
+    if f, ok := in.(*os.File); ok {
+        // trying raw mode out is the simplest way to find out if f is a terminal
+        if restore, err := makeRaw(f.Fd()); err == nil {
+            restore()
+            e := newEditor(f, s.out, loadHistory(s.opts.HistoryFile), s.complete)
+            if s.opts.Color {
+                e.highlight = highlight
+            }
+            return &terminalReader{fd: f.Fd(), editor: e}
+        }
+    }
+    return &plainReader{scanner: bufio.NewScanner(in), out: s.out}
+}
+
+// everything that's kept between one input and the next
+type session struct {
+    out     io.Writer
+    opts    Options
+    env     *object.Environment
+
+    // every input gets its own name, an error inside a function defined a few inputs ago has to show that input's source
+    sources map[string]string
+    inputs  int
+}
+
+func Start(in io.Reader, out io.Writer, opts Options) {
+    s := &session{out: out, opts: opts, env: object.NewEnvironment(), sources: map[string]string{}}
+    s.run(s.newLineReader(in))
+}
+
+// reads and runs inputs until there aren't any more
+func (s *session) run(lines lineReader) {
+    results := &printer{color: s.opts.Color}
 
     for {
-        fmt.Printf(PROMPT)
-        scanned := scanner.Scan()
-        if !scanned {
+        line, err := lines.readLine(PROMPT)
+        if err == errInterrupted {
+            continue
+        }
+        if err != nil {
             return
         }
-        // read in input line by line
-        line := scanner.Text()
-        // send each line to the lexer to get broken down to Tokens
-        l := lexer.New(line)
-        p := parser.New(l)
 
-        program := p.ParseProgram()
-        if len(p.Errors()) != 0 {
-            printParserErrors(out, p.Errors())
+        // commands are always one line, a : can't start a program so there's no confusing the two
+        if strings.HasPrefix(strings.TrimSpace(line), ":") {
+            lines.remember(line)
+            if quit := s.command(strings.TrimSpace(line)); quit {
+                return
+            }
             continue
         }
 
-        evaluated := evaluator.Eval(program, env)
+        program, errors, ok := s.readProgram(lines, line)
+        if !ok {
+            return
+        }
+        if program == nil {
+            continue
+        }
+        if len(errors) != 0 {
+            s.printDiagnostics(errors)
+            continue
+        }
 
+        evaluated := s.eval(program)
         if evaluated != nil {
-            io.WriteString(out, evaluated.Inspect())
-            io.WriteString(out, "\n")
+            io.WriteString(s.out, results.print(evaluated))
+            io.WriteString(s.out, "\n")
         }
     }
 }
 
-func printParserErrors(out io.Writer, errors []string){
-    io.WriteString(out, SKIBIDI_ASCII)
-    io.WriteString(out, "We ran into an error here!\n")
-    io.WriteString(out, "Parser Errors:\n")
-    for _, msg := range errors {
-        io.WriteString(out, "\t" + msg + "\n")
+// the name the next input's positions are reported under
+func (s *session) nextName() string {
+    s.inputs++
+    return fmt.Sprintf("<input %d>", s.inputs)
+}
+
+// reads lines until they add up to something that parses, or that is wrong in a way more lines can't fix
+// an empty line while the input is still incomplete gives up on it and reports the errors, Ctrl-C just throws it away (program is nil)
+// ok is false once there's no more input
+// the lines read go into the history together as one entry, unless they were thrown away
+func (s *session) readProgram(lines lineReader, line string) (*ast.Program, []diagnostic.Diagnostic, bool) {
+    name := s.nextName()
+    input := line
+
+    for {
+        s.sources[name] = input
+
+        // the whole input so far is parsed again every time, it's only ever a few lines
+        p := parser.New(lexer.NewFile(name, input))
+        program := p.ParseProgram()
+        if !p.Incomplete() {
+            lines.remember(input)
+            return program, p.Errors(), true
+        }
+
+        line, err := lines.readLine(CONTINUATION_PROMPT)
+        if err == errInterrupted {
+            return nil, nil, true
+        }
+        if err != nil {
+            return nil, nil, false
+        }
+        if line == "" {
+            // the empty line isn't added, that way the errors point at where the input actually ends
+            lines.remember(input)
+            return program, p.Errors(), true
+        }
+        input += "\n" + line
+    }
+}
+
+// parses a whole piece of source at once (a file or the argument of a command), the errors are printed and nil given back if it doesn't parse
+func (s *session) parse(name string, src string) *ast.Program {
+    s.sources[name] = src
+
+    p := parser.New(lexer.NewFile(name, src))
+    program := p.ParseProgram()
+    if len(p.Errors()) != 0 {
+        s.printDiagnostics(p.Errors())
+        return nil
+    }
+    return program
+}
+
+// runs the program in the session's environment, an error is printed and nil given back
+func (s *session) eval(program *ast.Program) object.Object {
+    c := evaluator.NewContext(context.Background(), evaluator.DefaultLimits)
+    c.Overflow = s.opts.Overflow
+    c.Stdout = s.out
+
+    var evaluated object.Object
+    if s.opts.VM {
+        evaluated = vm.EvalContext(c, program, s.env)
+    } else {
+        evaluated = evaluator.EvalContext(c, program, s.env)
+    }
+    if errObj, ok := evaluated.(*object.Error); ok {
+        s.printDiagnostics([]diagnostic.Diagnostic{errObj.Diagnostic()})
+        return nil
+    }
+    return evaluated
+}
+
+func (s *session) printDiagnostics(diagnostics []diagnostic.Diagnostic) {
+    for _, d := range diagnostics {
+        io.WriteString(s.out, d.Render(s.sources[d.Span.Start.Filename]))
     }
 }
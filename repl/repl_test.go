@@ -0,0 +1,123 @@
+package repl
+
+import (
+    "bytes"
+    "io"
+    "os"
+    "path/filepath"
+    "skibidi/object"
+    "strings"
+    "testing"
+)
+
+func TestMultiLineInput(t *testing.T) {
+    tests := []struct {
+        input       string
+        expected    string
+    }{
+        {"let f = fn(x) {\n  x * 2\n}\nf(21)\n", ">>....>>42\n>>"},
+        {"[1,\n2]\n", ">>..[1, 2]\n>>"},
+        {"\"a\n b\"\n", ">>..a\n b\n>>"},
+        // a finished statement is run straight away, even if the line ends with a semicolon
+        {"let x = 1;\nx\n", ">>>>1\n>>"},
+        // puts writes to the same place as the prompts and results
+        {"puts(\"hi\");\n", ">>hi\nnull\n>>"},
+    }
+
+    for _, tt := range tests {
+        var out bytes.Buffer
+        Start(strings.NewReader(tt.input), &out, Options{})
+
+        if out.String() != tt.expected {
+            t.Errorf("wrong output for %q, expected %q, got %q", tt.input, tt.expected, out.String())
+        }
+    }
+}
+
+// stands in for the line editor, the lines are read in order and whatever goes into the history is kept
+type scriptedLines struct {
+    lines       []string
+    remembered  []string
+}
+
+func (r *scriptedLines) readLine(prompt string) (string, error) {
+    if len(r.lines) == 0 {
+        return "", io.EOF
+    }
+    line := r.lines[0]
+    r.lines = r.lines[1:]
+    if line == "^C" {
+        return "", errInterrupted
+    }
+    return line, nil
+}
+
+func (r *scriptedLines) remember(entry string) {
+    r.remembered = append(r.remembered, entry)
+}
+
+// an input over several lines is one history entry, not one per line
+func TestHistoryEntries(t *testing.T) {
+    lines := &scriptedLines{lines: []string{"let f = fn(x) {", "  x * 2", "}", ":type f", "f(1", "", "[1,", "^C", "f(2)"}}
+    s := &session{out: io.Discard, env: object.NewEnvironment(), sources: map[string]string{}}
+    s.run(lines)
+
+    expected := []string{"let f = fn(x) {\n  x * 2\n}", ":type f", "f(1", "f(2)"}
+    if strings.Join(lines.remembered, "|") != strings.Join(expected, "|") {
+        t.Errorf("wrong history entries, expected %q, got %q", expected, lines.remembered)
+    }
+}
+
+// an empty line gives up on input that is still incomplete and reports what's wrong with it
+func TestAbandonIncompleteInput(t *testing.T) {
+    var out bytes.Buffer
+    Start(strings.NewReader("1 +\n\n2\n"), &out, Options{})
+
+    got := out.String()
+    if !strings.HasPrefix(got, ">>..error[P002]") || !strings.Contains(got, "<input 1>:1:4") {
+        t.Errorf("expected the error to be reported, got %q", got)
+    }
+    if !strings.HasSuffix(got, ">>2\n>>") {
+        t.Errorf("expected the next input to run normally, got %q", got)
+    }
+}
+
+func TestCommands(t *testing.T) {
+    script := filepath.Join(t.TempDir(), "lib.skb")
+    if err := os.WriteFile(script, []byte("let double = fn(x) { x * 2 };"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        input       string
+        expected    string
+    }{
+        {":env\nlet x = 1;\nlet name = \"a\";\n:env\n", ">>nothing is bound yet\n>>>>>>name  STRING\nx     INTEGER\n>>"},
+        {"let f = fn() {};\nlet x = f();\n:env\n", ">>>>>>f  FUNCTION\nx  NULL\n>>"},
+        {":type 1 + 2.5\n", ">>FLOAT\n>>"},
+        {":type\n", ">>usage: :type <expression>\n>>"},
+        {":ast -x\n", ">>Program\n  Statements[0]: ExpressionStatement\n    Expression: PrefixExpression (Operator: \"-\")\n      Right: Identifier (Value: \"x\")\n>>"},
+        {":tokens 1+\n", ">>1:1     INT        \"1\"\n1:2     +          \"+\"\n1:3     EOF        \"\"\n>>"},
+        {":tokens \"\\q\"\n", ">>1:1     STRING     \"\\\\q\"\n1:5     EOF        \"\"\n<input 1>:1:2: invalid escape sequence \\q\n>>"},
+        {":load " + script + "\ndouble(4)\n", ">>>>8\n>>"},
+        {"let x = 1;\n:reset\n:env\n", ">>>>>>nothing is bound yet\n>>"},
+        {":quit\n1\n", ">>"},
+        {":nope\n", ">>unknown command :nope, :help lists the commands\n>>"},
+    }
+
+    for _, tt := range tests {
+        var out bytes.Buffer
+        Start(strings.NewReader(tt.input), &out, Options{})
+
+        if out.String() != tt.expected {
+            t.Errorf("wrong output for %q, expected %q, got %q", tt.input, tt.expected, out.String())
+        }
+    }
+
+    // a file that can't be read and an expression that doesn't parse are both reported
+    var out bytes.Buffer
+    Start(strings.NewReader(":load missing.skb\n:type 1 +\n"), &out, Options{})
+    if !strings.Contains(out.String(), "can't load missing.skb") || !strings.Contains(out.String(), "error[P002]") {
+        t.Errorf("expected both commands to fail, got %q", out.String())
+    }
+}
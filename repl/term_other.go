@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package repl
+
+import "errors"
+
+// there's no line editor here, the REPL falls back to reading plain lines
+func makeRaw(fd uintptr) (restore func(), err error) {
+    return nil, errors.New("raw mode isn't supported on this platform")
+}
@@ -0,0 +1,47 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package repl
+
+import (
+    "syscall"
+    "unsafe"
+)
+
+func getTermios(fd uintptr) (*syscall.Termios, error) {
+    termios := &syscall.Termios{}
+    if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlGetTermios, uintptr(unsafe.Pointer(termios))); errno != 0 {
+        return nil, errno
+    }
+    return termios, nil
+}
+
+func setTermios(fd uintptr, termios *syscall.Termios) error {
+    if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlSetTermios, uintptr(unsafe.Pointer(termios))); errno != 0 {
+        return errno
+    }
+    return nil
+}
+
+// puts the terminal into raw mode: every key press is handed over as soon as it happens, nothing is echoed
+// and Ctrl-C is just another key, restore puts it back the way it was
+// fails if fd isn't a terminal, which is how the REPL finds out whether it can use the line editor
+func makeRaw(fd uintptr) (restore func(), err error) {
+    old, err := getTermios(fd)
+    if err != nil {
+        return nil, err
+    }
+
+    // the same as cfmakeraw, except output processing is left alone so a \n still goes back to the start of the line
+    raw := *old
+    raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+    raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+    raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+    raw.Cflag |= syscall.CS8
+    raw.Cc[syscall.VMIN] = 1
+    raw.Cc[syscall.VTIME] = 0
+
+    if err := setTermios(fd, &raw); err != nil {
+        return nil, err
+    }
+    return func() { setTermios(fd, old) }, nil
+}
@@ -1,12 +1,22 @@
 package token
 
+import (
+    "fmt"
+    "sort"
+)
+
 const (
     ILLEGAL = "ILLEGAL"
-    EOF     = ""
+    EOF     = "EOF"
+
+    // a // or /* */ comment, the lexer only hands these out when asked to (see lexer.KeepComments)
+    COMMENT = "COMMENT"
 
     // identifiers and literals
     IDENT   = "IDENT"
     INT     = "INT"
+    FLOAT   = "FLOAT"
+    STRING  = "STRING"
     
     // operators
     ASSIGN  = "="
@@ -16,20 +26,36 @@ const (
     ASTERISK= "*"
     SLASH   = "/"
 
+    // compound assignment, x += 1 is the same as x = x + 1
+    PLUS_ASSIGN     = "+="
+    MINUS_ASSIGN    = "-="
+    ASTERISK_ASSIGN = "*="
+    SLASH_ASSIGN    = "/="
+
     // comparisons
     LT = "<"
     GT = ">"
+    LT_EQ = "<="
+    GT_EQ = ">="
     EQ  = "=="
     NOT_EQ  = "!="
 
+    // logical operators, the right side is only evaluated if it's needed
+    AND = "&&"
+    OR  = "||"
+
     // delimiters
     COMMA   = ","
+    ELLIPSIS = "..." // marks the rest parameter of a function, fn(a, ...rest)
     SEMICOLON = ";"
+    COLON   = ":"
 
     LPAREN = "("
     RPAREN = ")"
     LBRACE = "{"
     RBRACE = "}"
+    LBRACKET = "["
+    RBRACKET = "]"
 
     // keywords
     FUNCTION = "FUNCTION"
@@ -39,6 +65,11 @@ const (
     IF = "IF"
     ELSE = "ELSE"
     RETURN = "RETURN"
+    WHILE = "WHILE"
+    FOR = "FOR"
+    IN = "IN"
+    BREAK = "BREAK"
+    CONTINUE = "CONTINUE"
 
 )
 
@@ -49,6 +80,39 @@ type Token struct {
     Type    TokenType
     // holds the literal value of the token (ex 5, 10, etc)
     Literal string
+    // where the token starts in the source, and the position just after its last character
+    // these are kept separately because the literal of a string token is not the same length as its source text
+    Pos     Position
+    End     Position
+}
+
+// a location in a source file, lines and columns start at 1 while the offset is a 0 based byte offset
+// columns count characters rather than bytes, so a caret lines up under the right spot even after some unicode
+type Position struct {
+    Filename    string
+    Offset      int
+    Line        int
+    Column      int
+}
+
+// a position with no line is the zero value, used for things that don't come from source code
+func (p Position) IsValid() bool {
+    return p.Line > 0
+}
+
+// formats the position as file:line:col, leaving out whatever parts are unknown
+func (p Position) String() string {
+    s := p.Filename
+    if p.IsValid() {
+        if s != "" {
+            s += ":"
+        }
+        s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+    }
+    if s == "" {
+        s = "-"
+    }
+    return s
 }
 
 var keywords = map[string]TokenType{
@@ -59,6 +123,21 @@ var keywords = map[string]TokenType{
     "if": IF,
     "else": ELSE,
     "return": RETURN,
+    "while": WHILE,
+    "for": FOR,
+    "in": IN,
+    "break": BREAK,
+    "continue": CONTINUE,
+}
+
+// every keyword, sorted, for things like tab completion in the REPL
+func Keywords() []string {
+    words := make([]string, 0, len(keywords))
+    for word := range keywords {
+        words = append(words, word)
+    }
+    sort.Strings(words)
+    return words
 }
 
 // checks the keywords table to see if the identifier is a known keyword (like var)
@@ -0,0 +1,565 @@
+// a stack based virtual machine that runs the bytecode made by the compiler package
+// operators, indexing and builtins are handed off to the evaluator package, so both backends always agree on the results
+// it's the faster of the two, skibidi --vm and interp.Interpreter.UseVM pick it over the evaluator
+package vm
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "skibidi/ast"
+    "skibidi/code"
+    "skibidi/compiler"
+    "skibidi/diagnostic"
+    "skibidi/evaluator"
+    "skibidi/object"
+)
+
+const initialStackSize = 2048
+
+// one function call that is currently running
+type Frame struct {
+    cl          *object.Closure
+    ip          int // offset of the next instruction to run
+    basePointer int // where the function being called sits on the stack, everything from here up belongs to this call
+    locals      *object.Locals // nil for the top level
+    numArgs     int // how many arguments the call was given, the default values only fill in the rest
+    loopMarks   []int // the stack height when each loop that is running in this call started, innermost last
+    callIP      int // where the call instruction that made this frame starts in the caller, for the stack trace of an error
+}
+
+// walks over whatever a for loop was given, it only ever lives on the stack so the program can't get hold of one
+type iterator struct {
+    elements    []object.Object
+    next        int
+}
+
+func (it *iterator) Type() object.ObjectType {
+    return "ITERATOR"
+}
+
+func (it *iterator) Inspect() string {
+    return "iterator"
+}
+
+type VM struct {
+    // the globals are kept by name, the same way the tree walker keeps them, so they can be shared with it and with the next program
+    env         *object.Environment
+
+    stack       []object.Object
+    sp          int // always points to the next free slot, the top of the stack is stack[sp-1]
+
+    frames      []*Frame
+
+    c           *evaluator.Context // the limits the program is run with, set by RunContext
+}
+
+// a VM with fresh globals
+func New(bytecode *compiler.Bytecode) *VM {
+    return NewWithEnvironment(bytecode, object.NewEnvironment())
+}
+
+// the program's globals are read from and bound in env
+func NewWithEnvironment(bytecode *compiler.Bytecode, env *object.Environment) *VM {
+    return newVM(bytecode.Main, env)
+}
+
+func newVM(main *object.CompiledFunction, env *object.Environment) *VM {
+    return &VM{
+        env:    env,
+        stack:  make([]object.Object, initialStackSize),
+        frames: []*Frame{{cl: &object.Closure{Fn: main}}},
+    }
+}
+
+// compiles and runs program with the limits of c, the vm's version of evaluator.EvalContext
+// a program the compiler can't handle comes back as an *object.Error like anything else that goes wrong
+func EvalContext(c *evaluator.Context, program *ast.Program, env *object.Environment) object.Object {
+    comp := compiler.New()
+    if err := comp.Compile(program); err != nil {
+        var tooBig *compiler.TooBigError
+        if errors.As(err, &tooBig) {
+            return &object.Error{Kind: object.LimitError, Code: diagnostic.ProgramTooBig, Message: tooBig.Message, Pos: tooBig.Pos}
+        }
+        return &object.Error{Code: diagnostic.Internal, Message: err.Error()}
+    }
+    return NewWithEnvironment(comp.Bytecode(), env).RunContext(c)
+}
+
+// calls a function from Go, the vm's version of evaluator.ApplyContext
+// env is where the function finds the globals it uses
+func Call(c *evaluator.Context, env *object.Environment, fn object.Object, args []object.Object) object.Object {
+    vm := newVM(&object.CompiledFunction{}, env)
+    vm.c = c
+
+    vm.push(fn)
+    for _, arg := range args {
+        vm.push(arg)
+    }
+    // anything but a compiled function is done straight away, otherwise its frame runs and hands the result back to the empty top level
+    if result := vm.callFunction(len(args), 0); result != nil {
+        return result
+    }
+    return vm.run()
+}
+
+// runs the program with the limits in evaluator.DefaultLimits
+func (vm *VM) Run() object.Object {
+    return vm.RunContext(evaluator.NewContext(context.Background(), evaluator.DefaultLimits))
+}
+
+// runs the program and gives back its result, same contract as evaluator.EvalContext:
+// the value of the last statement, or the *object.Error that stopped the program
+// the limits of c are checked the same way, and an error gets a stack trace of the calls it came out of
+func (vm *VM) RunContext(c *evaluator.Context) object.Object {
+    vm.c = c
+    return vm.run()
+}
+
+func (vm *VM) run() object.Object {
+    frame := vm.frames[len(vm.frames)-1]
+    ins := frame.cl.Fn.Instructions
+
+    for {
+        // the top level finished without a return, its value is whatever the last statement left behind
+        if frame.ip >= len(ins) {
+            return vm.stack[vm.sp-1]
+        }
+
+        start := frame.ip
+        op := code.Opcode(ins[start])
+        frame.ip++
+
+        var result object.Object
+        if err := vm.c.Step(); err != nil {
+            op, result = opFailed, err
+        }
+
+        switch op {
+        case opFailed:
+            // result already holds the error
+
+        case code.OpConstant:
+            idx := code.ReadUint16(ins[frame.ip:])
+            frame.ip += 2
+            vm.push(frame.cl.Fn.Constants[idx])
+
+        case code.OpPop:
+            vm.sp--
+
+        case code.OpTrue:
+            vm.push(evaluator.TRUE)
+        case code.OpFalse:
+            vm.push(evaluator.FALSE)
+        case code.OpNull:
+            vm.push(evaluator.NULL)
+        case code.OpNothing:
+            vm.push(nil)
+
+        case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv,
+            code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+            code.OpGreaterEqual, code.OpLessEqual:
+            right := vm.pop()
+            left := vm.pop()
+            result = vm.allocated(vm.executeInfix(op, left, right))
+
+        case code.OpMinus:
+            result = vm.allocated(evaluator.EvalPrefix("-", vm.pop(), vm.c.Overflow))
+        case code.OpBang:
+            result = evaluator.EvalPrefix("!", vm.pop(), vm.c.Overflow)
+
+        case code.OpJump:
+            frame.ip = int(code.ReadUint16(ins[frame.ip:]))
+        case code.OpJumpNotTruthy:
+            target := int(code.ReadUint16(ins[frame.ip:]))
+            frame.ip += 2
+            if !evaluator.IsTruthy(vm.pop()) {
+                frame.ip = target
+            }
+        case code.OpJumpIfArgGiven:
+            param := int(code.ReadUint8(ins[frame.ip:]))
+            target := int(code.ReadUint16(ins[frame.ip+1:]))
+            frame.ip += 3
+            if param < frame.numArgs {
+                frame.ip = target
+            }
+
+        case code.OpLoopEnter:
+            frame.loopMarks = append(frame.loopMarks, vm.sp)
+        case code.OpLoopExit:
+            frame.loopMarks = frame.loopMarks[:len(frame.loopMarks)-1]
+        case code.OpUnwind:
+            vm.sp = frame.loopMarks[len(frame.loopMarks)-1]
+        case code.OpIter:
+            elements, err := evaluator.IterableElements(vm.pop())
+            if err != nil {
+                result = err
+            } else {
+                vm.push(&iterator{elements: elements})
+            }
+        case code.OpIterNext:
+            target := int(code.ReadUint16(ins[frame.ip:]))
+            frame.ip += 2
+            it := vm.stack[vm.sp-1].(*iterator)
+            if it.next >= len(it.elements) {
+                frame.ip = target
+            } else {
+                vm.push(it.elements[it.next])
+                it.next++
+            }
+
+        case code.OpGetGlobal:
+            idx := int(code.ReadUint16(ins[frame.ip:]))
+            frame.ip += 2
+            result = vm.getGlobal(frame.cl.Fn.GlobalNames[idx])
+        case code.OpSetGlobal:
+            idx := code.ReadUint16(ins[frame.ip:])
+            frame.ip += 2
+            vm.env.Set(frame.cl.Fn.GlobalNames[idx], vm.pop())
+
+        case code.OpGetLocal:
+            idx := int(code.ReadUint16(ins[frame.ip:]))
+            frame.ip += 2
+            result = vm.getLocal(frame.locals, idx)
+        case code.OpSetLocal:
+            idx := code.ReadUint16(ins[frame.ip:])
+            frame.ip += 2
+            frame.locals.Slots[idx] = vm.pop()
+        case code.OpGetOuter:
+            depth := int(code.ReadUint8(ins[frame.ip:]))
+            idx := int(code.ReadUint16(ins[frame.ip+1:]))
+            frame.ip += 3
+            locals := frame.locals
+            for i := 0; i < depth; i++ {
+                locals = locals.Outer
+            }
+            result = vm.getLocal(locals, idx)
+
+        case code.OpAssignGlobal:
+            idx := int(code.ReadUint16(ins[frame.ip:]))
+            frame.ip += 2
+            result = vm.assignGlobal(frame.cl.Fn.GlobalNames[idx], vm.pop())
+        case code.OpAssignLocal:
+            idx := int(code.ReadUint16(ins[frame.ip:]))
+            frame.ip += 2
+            result = vm.assignLocal(frame.locals, idx, vm.pop())
+        case code.OpAssignOuter:
+            depth := int(code.ReadUint8(ins[frame.ip:]))
+            idx := int(code.ReadUint16(ins[frame.ip+1:]))
+            frame.ip += 3
+            locals := frame.locals
+            for i := 0; i < depth; i++ {
+                locals = locals.Outer
+            }
+            result = vm.assignLocal(locals, idx, vm.pop())
+
+        case code.OpArray:
+            n := int(code.ReadUint16(ins[frame.ip:]))
+            frame.ip += 2
+            elements := make([]object.Object, n)
+            copy(elements, vm.stack[vm.sp-n:vm.sp])
+            vm.sp -= n
+            result = vm.allocated(&object.Array{Elements: elements})
+        case code.OpHash:
+            n := int(code.ReadUint16(ins[frame.ip:]))
+            frame.ip += 2
+            result = vm.allocated(vm.buildHash(vm.stack[vm.sp-2*n : vm.sp]))
+            vm.sp -= 2 * n
+        case code.OpIndex:
+            index := vm.pop()
+            left := vm.pop()
+            result = evaluator.EvalIndex(left, index)
+        case code.OpSetIndex:
+            value := vm.pop()
+            index := vm.pop()
+            left := vm.pop()
+            result = evaluator.EvalSetIndex(left, index, value)
+        case code.OpDup2:
+            vm.push(vm.stack[vm.sp-2])
+            vm.push(vm.stack[vm.sp-2])
+
+        case code.OpClosure:
+            idx := code.ReadUint16(ins[frame.ip:])
+            frame.ip += 2
+            fn := frame.cl.Fn.Constants[idx].(*object.CompiledFunction)
+            result = vm.allocated(&object.Closure{Fn: fn, Env: frame.locals})
+
+        case code.OpCall:
+            numArgs := int(code.ReadUint8(ins[frame.ip:]))
+            frame.ip++
+            result = vm.callFunction(numArgs, start)
+            if result == nil {
+                // a compiled function was entered, carry on running it
+                frame = vm.frames[len(vm.frames)-1]
+                ins = frame.cl.Fn.Instructions
+            }
+
+        case code.OpReturnValue:
+            returnValue := vm.pop()
+            if len(vm.frames) == 1 {
+                // a return at the top level ends the whole program
+                return returnValue
+            }
+            vm.frames = vm.frames[:len(vm.frames)-1]
+            vm.c.LeaveCall()
+            vm.sp = frame.basePointer
+            vm.push(returnValue)
+
+            frame = vm.frames[len(vm.frames)-1]
+            ins = frame.cl.Fn.Instructions
+
+        default:
+            return &object.Error{Code: diagnostic.Internal, Message: fmt.Sprintf("unknown opcode %d", op)}
+        }
+
+        // instructions that can fail hand their result back through result instead of pushing it themselves
+        if result != nil {
+            if err, ok := result.(*object.Error); ok {
+                if !err.Pos.IsValid() {
+                    err.Pos = frame.cl.Fn.PositionAt(start)
+                }
+                vm.unwind(err)
+                return err
+            }
+            vm.push(result)
+        }
+    }
+}
+
+// not a real instruction, run uses it when an error comes up before the instruction even starts
+const opFailed code.Opcode = 255
+
+// the calls that are still running when an error stops the program are left, each one adds itself to the error's stack trace
+// innermost first, the same as the tree walker's
+func (vm *VM) unwind(err *object.Error) {
+    for len(vm.frames) > 1 {
+        frame := vm.frames[len(vm.frames)-1]
+        vm.frames = vm.frames[:len(vm.frames)-1]
+        vm.c.LeaveCall()
+
+        caller := vm.frames[len(vm.frames)-1]
+        err.Stack = append(err.Stack, object.StackFrame{Function: frame.cl.Fn.Name, CallPos: caller.cl.Fn.CallPositionAt(frame.callIP)})
+    }
+}
+
+// counts an object an instruction made against the allocation limit, gives back the error instead if it goes over
+func (vm *VM) allocated(obj object.Object) object.Object {
+    if err := vm.c.Allocate(obj); err != nil {
+        return err
+    }
+    return obj
+}
+
+// arithmetic and comparisons between two integers are by far the most common thing in a hot loop, so they skip the evaluator
+// everything else goes through evaluator.EvalInfix so the rules only live in one place
+func (vm *VM) executeInfix(op code.Opcode, left object.Object, right object.Object) object.Object {
+    leftInt, leftOk := left.(*object.Integer)
+    rightInt, rightOk := right.(*object.Integer)
+    if leftOk && rightOk {
+        a, b := leftInt.Value, rightInt.Value
+        switch op {
+        // when the result overflows it's left to the evaluator, which knows what to do about it
+        case code.OpAdd:
+            if sum := a + b; (sum > a) == (b > 0) {
+                return &object.Integer{Value: sum}
+            }
+        case code.OpSub:
+            if diff := a - b; (diff < a) == (b > 0) {
+                return &object.Integer{Value: diff}
+            }
+        case code.OpLessThan:
+            return boolToBooleanObj(leftInt.Value < rightInt.Value)
+        case code.OpGreaterThan:
+            return boolToBooleanObj(leftInt.Value > rightInt.Value)
+        case code.OpLessEqual:
+            return boolToBooleanObj(leftInt.Value <= rightInt.Value)
+        case code.OpGreaterEqual:
+            return boolToBooleanObj(leftInt.Value >= rightInt.Value)
+        case code.OpEqual:
+            return boolToBooleanObj(leftInt.Value == rightInt.Value)
+        case code.OpNotEqual:
+            return boolToBooleanObj(leftInt.Value != rightInt.Value)
+        }
+    }
+
+    return evaluator.EvalInfix(infixOperators[op], left, right, vm.c.Overflow)
+}
+
+var infixOperators = map[code.Opcode]string{
+    code.OpAdd:            "+",
+    code.OpSub:            "-",
+    code.OpMul:            "*",
+    code.OpDiv:            "/",
+    code.OpEqual:          "==",
+    code.OpNotEqual:       "!=",
+    code.OpGreaterThan:    ">",
+    code.OpLessThan:       "<",
+    code.OpGreaterEqual:   ">=",
+    code.OpLessEqual:      "<=",
+}
+
+func boolToBooleanObj(input bool) *object.Boolean {
+    if input {
+        return evaluator.TRUE
+    }
+    return evaluator.FALSE
+}
+
+// the function sits on the stack below its arguments, callIP is where the call instruction starts
+// gives back nil when a compiled function was entered, otherwise the result of the call (or an error)
+func (vm *VM) callFunction(numArgs int, callIP int) object.Object {
+    basePointer := vm.sp - 1 - numArgs
+    callee := vm.stack[basePointer]
+
+    switch callee := callee.(type) {
+    case *object.Closure:
+        fn := callee.Fn
+        max := fn.NumParameters
+        if fn.HasRest {
+            max = -1
+        }
+        if numArgs < fn.NumRequired || max >= 0 && numArgs > max {
+            return evaluator.ArityError(fn.Name, numArgs, fn.NumRequired, max)
+        }
+        if err := vm.c.EnterCall(); err != nil {
+            return err
+        }
+
+        locals := &object.Locals{
+            Slots:  make([]object.Object, fn.NumLocals),
+            Fn:     fn,
+            Outer:  callee.Env,
+        }
+        args := vm.stack[basePointer+1 : vm.sp]
+        given := copy(locals.Slots[:fn.NumParameters], args)
+        if fn.HasRest {
+            rest := make([]object.Object, len(args)-given)
+            copy(rest, args[given:])
+            locals.Slots[fn.NumParameters] = &object.Array{Elements: rest}
+        }
+
+        vm.pushFrame(Frame{cl: callee, basePointer: basePointer, locals: locals, numArgs: numArgs, callIP: callIP})
+        return nil
+
+    case *object.Builtin, *object.Function:
+        // a function the tree walker made (eg bound in the environment before the vm was used) runs in the tree walker
+        args := make([]object.Object, numArgs)
+        copy(args, vm.stack[basePointer+1:vm.sp])
+        vm.sp = basePointer
+
+        result := evaluator.ApplyContext(vm.c, callee, args)
+        if result == nil {
+            // a Go nil would look like "a function was entered" to the caller
+            return evaluator.NULL
+        }
+        return result
+
+    default:
+        return &object.Error{Code: diagnostic.NotAFunction, Message: fmt.Sprintf("not a function: %s", callee.Type())}
+    }
+}
+
+func (vm *VM) buildHash(keysAndValues []object.Object) object.Object {
+    pairs := make(map[object.HashKey]object.HashPair, len(keysAndValues)/2)
+
+    for i := 0; i < len(keysAndValues); i += 2 {
+        key := keysAndValues[i]
+        value := keysAndValues[i+1]
+
+        hashKey, ok := key.(object.Hashable)
+        if !ok {
+            return &object.Error{Code: diagnostic.UnhashableKey, Message: fmt.Sprintf("unusable as hash key: %s", key.Type())}
+        }
+
+        pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+    }
+
+    return &object.Hash{Pairs: pairs}
+}
+
+// a name that isn't bound falls back to the builtins like evalIdentifier does
+func (vm *VM) getGlobal(name string) object.Object {
+    if val, ok := vm.env.Get(name); ok {
+        return val
+    }
+
+    if builtin, ok := evaluator.LookupBuiltin(name); ok {
+        return builtin
+    }
+
+    return &object.Error{Code: diagnostic.UnknownIdentifier, Message: "identifier not found: " + name}
+}
+
+// a local that hasn't been set yet (its let hasn't run) isn't bound yet either
+// so the search carries on outwards by name, the same way the environment does
+func (vm *VM) getLocal(locals *object.Locals, idx int) object.Object {
+    if val := locals.Slots[idx]; val != nil {
+        return val
+    }
+
+    name := locals.Fn.LocalNames[idx]
+    for outer := locals.Outer; outer != nil; outer = outer.Outer {
+        for i, n := range outer.Fn.LocalNames {
+            if n == name && outer.Slots[i] != nil {
+                return outer.Slots[i]
+            }
+        }
+    }
+
+    return vm.getGlobal(name)
+}
+
+// assignment follows the same rules as reading: an unset slot means the name isn't bound there, so the search carries on outwards
+// the difference is that running out of places to look is an error, assignment never creates a binding
+func (vm *VM) assignGlobal(name string, val object.Object) object.Object {
+    if !vm.env.Assign(name, val) {
+        return &object.Error{Code: diagnostic.UndeclaredAssignment, Message: "assignment to undeclared variable: " + name}
+    }
+    return val
+}
+
+func (vm *VM) assignLocal(locals *object.Locals, idx int, val object.Object) object.Object {
+    if locals.Slots[idx] != nil {
+        locals.Slots[idx] = val
+        return val
+    }
+
+    name := locals.Fn.LocalNames[idx]
+    for outer := locals.Outer; outer != nil; outer = outer.Outer {
+        for i, n := range outer.Fn.LocalNames {
+            if n == name && outer.Slots[i] != nil {
+                outer.Slots[i] = val
+                return val
+            }
+        }
+    }
+
+    return vm.assignGlobal(name, val)
+}
+
+// a call that has returned leaves its Frame behind past the end of vm.frames, the next call reuses it instead of allocating one
+func (vm *VM) pushFrame(frame Frame) {
+    n := len(vm.frames)
+    if n < cap(vm.frames) {
+        if reused := vm.frames[:n+1][n]; reused != nil {
+            frame.loopMarks = reused.loopMarks[:0]
+            *reused = frame
+            vm.frames = vm.frames[:n+1]
+            return
+        }
+    }
+    vm.frames = append(vm.frames, &frame)
+}
+
+func (vm *VM) push(obj object.Object) {
+    if vm.sp >= len(vm.stack) {
+        vm.stack = append(vm.stack, make([]object.Object, len(vm.stack))...)
+    }
+    vm.stack[vm.sp] = obj
+    vm.sp++
+}
+
+func (vm *VM) pop() object.Object {
+    vm.sp--
+    return vm.stack[vm.sp]
+}
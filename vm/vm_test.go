@@ -0,0 +1,261 @@
+package vm
+
+import (
+    "context"
+    "skibidi/diagnostic"
+    "skibidi/compiler"
+    "skibidi/evaluator"
+    "skibidi/lexer"
+    "skibidi/object"
+    "skibidi/parser"
+    "strings"
+    "testing"
+    "time"
+)
+
+// the evaluator tests already run against the vm, these cover the things that are specific to it
+func TestDeepRecursion(t *testing.T) {
+    // deep enough to need the stack to grow
+    input := `
+    let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } };
+    count(5000);
+    `
+
+    result := runVM(t, input)
+    integer, ok := result.(*object.Integer)
+    if !ok || integer.Value != 5000 {
+        t.Fatalf("wrong result, want: 5000, got: %s", describe(result))
+    }
+}
+
+func TestClosuresShareLocals(t *testing.T) {
+    tests := []struct {
+        input       string
+        expected    int64
+    }{
+        {"let adder = fn(x) { fn(y) { x + y } }; let addTwo = adder(2); addTwo(3);", 5},
+        {"let a = fn(x) { fn(y) { fn(z) { x + y + z } } }; a(1)(2)(3);", 6},
+        // the inner function is defined before the name it uses, which is only bound when the let runs
+        {"let f = fn() { let g = fn() { h() }; let h = fn() { 7 }; g() }; f();", 7},
+        // a local that hasn't been bound yet falls back to the global with the same name
+        {"let x = 1; let f = fn() { let y = x; let x = 2; y + x }; f();", 3},
+    }
+
+    for _, tt := range tests {
+        result := runVM(t, tt.input)
+        integer, ok := result.(*object.Integer)
+        if !ok || integer.Value != tt.expected {
+            t.Errorf("wrong result for %q, want: %d, got: %s", tt.input, tt.expected, describe(result))
+        }
+    }
+}
+
+// whatever was half way through being computed when break or continue happened has to be thrown away
+func TestLoopControlUnwindsStack(t *testing.T) {
+    input := `
+    let n = 0;
+    while (true) {
+        let n = n + 1;
+        let x = [1, 2, if (n == 5) { break } else { if (n < 3) { continue } else { 3 } }];
+    }
+    n;
+    `
+
+    bytecode := compile(t, input)
+    machine := New(bytecode)
+    result := machine.Run()
+
+    integer, ok := result.(*object.Integer)
+    if !ok || integer.Value != 5 {
+        t.Fatalf("wrong result, want: 5, got: %s", describe(result))
+    }
+
+    // only the result itself should be left over
+    if machine.sp != 1 {
+        t.Errorf("stack wasn't cleaned up, sp is %d", machine.sp)
+    }
+}
+
+const fibProgram = `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+fib(25);
+`
+
+func BenchmarkFibEvaluator(b *testing.B) {
+    program := parser.New(lexer.New(fibProgram)).ParseProgram()
+    for i := 0; i < b.N; i++ {
+        evaluator.Eval(program, object.NewEnvironment())
+    }
+}
+
+func BenchmarkFibVM(b *testing.B) {
+    program := parser.New(lexer.New(fibProgram)).ParseProgram()
+    comp := compiler.New()
+    if err := comp.Compile(program); err != nil {
+        b.Fatal(err)
+    }
+    bytecode := comp.Bytecode()
+
+    for i := 0; i < b.N; i++ {
+        New(bytecode).Run()
+    }
+}
+
+func runVM(t *testing.T, input string) object.Object {
+    t.Helper()
+    return New(compile(t, input)).Run()
+}
+
+func compile(t *testing.T, input string) *compiler.Bytecode {
+    t.Helper()
+
+    p := parser.New(lexer.New(input))
+    program := p.ParseProgram()
+    if len(p.Errors()) != 0 {
+        t.Fatalf("parser errors: %v", p.Errors())
+    }
+
+    comp := compiler.New()
+    if err := comp.Compile(program); err != nil {
+        t.Fatalf("compiler error: %s", err)
+    }
+
+    return comp.Bytecode()
+}
+
+func describe(obj object.Object) string {
+    if obj == nil {
+        return "nil"
+    }
+    return string(obj.Type()) + " " + obj.Inspect()
+}
+
+func TestCheckedArithmetic(t *testing.T) {
+    c := evaluator.NewContext(context.Background(), evaluator.DefaultLimits)
+    c.Overflow = evaluator.ErrorOnOverflow
+
+    result := New(compile(t, "let x = 9223372036854775807; x += 1")).RunContext(c)
+    errObj, ok := result.(*object.Error)
+    if !ok || errObj.Message != "integer overflow: 9223372036854775807 + 1" {
+        t.Errorf("expected an overflow error, got: %s", describe(result))
+    }
+}
+
+// operands that don't fit in their instruction are an error, instead of being cut off and doing something else than the evaluator
+func TestOperandTooBig(t *testing.T) {
+    tests := []struct {
+        input       string
+        expected    string
+    }{
+        {"let f = fn(...a) { len(a) }; f(" + strings.Repeat("1, ", 255) + "1)",
+            "1:30: program is too big for the vm: OpCall needs 256, but at most 255 fits"},
+        {"let x = 1; [" + strings.Repeat("x, ", 69999) + "x]",
+            "1:12: program is too big for the vm: OpArray needs 70000, but at most 65535 fits"},
+    }
+
+    for _, tt := range tests {
+        program := parser.New(lexer.New(tt.input)).ParseProgram()
+        err := compiler.New().Compile(program)
+        if err == nil || err.Error() != tt.expected {
+            t.Errorf("wrong compiler error, expected %q, got %v", tt.expected, err)
+        }
+
+        // run through EvalContext it's an error like any other
+        result := EvalContext(evaluator.NewContext(context.Background(), evaluator.DefaultLimits), program, object.NewEnvironment())
+        if errObj, ok := result.(*object.Error); !ok || errObj.Code != diagnostic.ProgramTooBig || errObj.Inspect() != "ERROR: "+tt.expected {
+            t.Errorf("wrong error, got: %s", describe(result))
+        }
+    }
+
+    // the most that does fit still works
+    result := runVM(t, "let f = fn(...a) { len(a) }; f("+strings.Repeat("1, ", 254)+"1)")
+    if describe(result) != "INTEGER 255" {
+        t.Errorf("wrong result, got: %s", describe(result))
+    }
+}
+
+// the same limits as the evaluator's, checked with the same Context
+func TestLimits(t *testing.T) {
+    timeout, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+    defer cancel()
+    cancelled, cancelNow := context.WithCancel(context.Background())
+    cancelNow()
+
+    tests := []struct {
+        ctx         context.Context
+        limits      evaluator.Limits
+        input       string
+        code        diagnostic.Code // empty when the program should finish
+    }{
+        {context.Background(), evaluator.Limits{MaxDepth: 3}, "let f = fn(n) { if (n > 0) { f(n - 1) } }; f(2)", ""},
+        {context.Background(), evaluator.Limits{MaxDepth: 3}, "let f = fn(n) { if (n > 0) { f(n - 1) } }; f(3)", diagnostic.CallDepthExceeded},
+        {context.Background(), evaluator.Limits{MaxSteps: 1000}, "let i = 0; while (i < 10) { i += 1 }", ""},
+        {context.Background(), evaluator.Limits{MaxSteps: 1000}, "while (true) {}", diagnostic.StepLimitExceeded},
+        {context.Background(), evaluator.Limits{MaxAllocation: 1000}, "let s = \"\"; for (i in [1, 2, 3]) { s += \"ab\" } s", ""},
+        {context.Background(), evaluator.Limits{MaxAllocation: 1000}, "let s = \"ab\"; while (true) { s += s }", diagnostic.AllocationLimitExceeded},
+        {context.Background(), evaluator.Limits{MaxAllocation: 10000}, "let a = []; while (true) { a = push(a, 1) }", diagnostic.AllocationLimitExceeded},
+        {timeout, evaluator.Limits{}, "while (true) {}", diagnostic.Cancelled},
+        {cancelled, evaluator.Limits{}, "let f = fn() { f() }; f()", diagnostic.Cancelled},
+    }
+
+    for _, tt := range tests {
+        result := New(compile(t, tt.input)).RunContext(evaluator.NewContext(tt.ctx, tt.limits))
+
+        errObj, isErr := result.(*object.Error)
+        if tt.code == "" {
+            if isErr {
+                t.Errorf("unexpected error for %q: %s", tt.input, errObj.Inspect())
+            }
+            continue
+        }
+        if !isErr || errObj.Kind != object.LimitError || errObj.Code != tt.code {
+            t.Errorf("wrong result for %q, want: %s error, got: %s", tt.input, tt.code, describe(result))
+        }
+    }
+}
+
+// the calls an error leaves behind don't count towards the depth limit of the next program run with the same Context
+func TestErrorLeavesCalls(t *testing.T) {
+    c := evaluator.NewContext(context.Background(), evaluator.Limits{MaxDepth: 3})
+
+    result := New(compile(t, "let f = fn(n) { if (n > 0) { f(n - 1) } else { missing } }; f(2)")).RunContext(c)
+    if errObj, ok := result.(*object.Error); !ok || errObj.Code != diagnostic.UnknownIdentifier || len(errObj.Stack) != 3 {
+        t.Fatalf("expected an unknown identifier error from 3 calls, got: %s", describe(result))
+    }
+
+    result = New(compile(t, "let f = fn(n) { if (n > 0) { f(n - 1) } else { n } }; f(2)")).RunContext(c)
+    if describe(result) != "INTEGER 0" {
+        t.Errorf("wrong result, got: %s", describe(result))
+    }
+}
+
+// globals live in the environment, so a later program (or the tree walker) sees what an earlier one bound
+// and a function keeps working after the program that made it is done
+func TestSharedEnvironment(t *testing.T) {
+    env := object.NewEnvironment()
+    env.Set("base", &object.Integer{Value: 10})
+
+    c := evaluator.NewContext(context.Background(), evaluator.DefaultLimits)
+    for _, input := range []string{
+        "let add = fn(x) { x + base + 1 }; let unused = \"a\";",
+        "let twice = fn(x) { add(add(x)) }; base = 100;",
+    } {
+        program := parser.New(lexer.New(input)).ParseProgram()
+        if result, ok := EvalContext(c, program, env).(*object.Error); ok {
+            t.Fatalf("unexpected error for %q: %s", input, result.Inspect())
+        }
+    }
+
+    twice, _ := env.Get("twice")
+    result := Call(c, env, twice, []object.Object{&object.Integer{Value: 1}})
+    if describe(result) != "INTEGER 203" {
+        t.Errorf("wrong result, got: %s", describe(result))
+    }
+
+    // and the other way around, a function made by the tree walker can be called from the vm
+    evaluator.Eval(parser.New(lexer.New("let sub = fn(x) { x - base }")).ParseProgram(), env)
+    result = EvalContext(c, parser.New(lexer.New("sub(twice(0))")).ParseProgram(), env)
+    if describe(result) != "INTEGER 102" {
+        t.Errorf("wrong result, got: %s", describe(result))
+    }
+}